@@ -1,57 +1,186 @@
 package main
 
 import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 
+	"gitlab.com/phamhonganh12062000/smolredis/internal/acl"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/aof"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/command"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/config"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/helpers"
 	"gitlab.com/phamhonganh12062000/smolredis/internal/logger"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/pubsub"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/rdb"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/replication"
 	"gitlab.com/phamhonganh12062000/smolredis/internal/session"
 	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/tlsconfig"
 )
 
 type Cache struct {
-	listener net.Listener
-	logger   *logger.Logger
-	done     chan os.Signal
-	wg       sync.WaitGroup // 	Tracking active connections
-	store    *store.InMemoryStore
+	logger *logger.Logger
+	done   chan os.Signal
+	wg     sync.WaitGroup // 	Tracking active connections
+	store  *store.InMemoryStore
+	rdb    *rdb.Snapshotter
+	aof    *aof.Log        // Nil when appendonly is off
+	server *command.Server // Shared by every session
 }
 
 func main() {
-	loggerConfig := logger.LoggerConfig{MinLevel: logger.LevelInfo, StackDepth: 3, ShowCaller: true}
+	// A config file comes first, flags after it override what it says
+	cfg := config.New(config.Params)
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if err := cfg.LoadFile(args[0]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		args = args[1:]
+	}
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [/path/to/redis.conf] [options]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	cfg.RegisterFlags(flag.CommandLine)
+	flag.CommandLine.Parse(args)
+
+	level, _ := logger.ParseLevel(cfg.Get("loglevel"))
+	loggerConfig := logger.LoggerConfig{MinLevel: level, StackDepth: 3, ShowCaller: true}
 	logger := logger.New(os.Stdout, loggerConfig)
 
-	listener, err := net.Listen("tcp", ":6380")
-	if err != nil {
-		logger.Fatal(err, nil)
-		os.Exit(1)
-	}
+	limit, _ := helpers.ParseMemory(cfg.Get("maxmemory"))
+	policy, _ := store.ParsePolicy(cfg.Get("maxmemory-policy"))
+	store := store.NewInMemoryStore()
+	store.SetMaxMemory(limit, policy)
+	dbFilename := cfg.Get("dbfilename")
+	snapshotter := rdb.NewSnapshotter(dbFilename, store, logger)
 
-	defer listener.Close()
+	c := &Cache{logger: logger, done: make(chan os.Signal, 1), store: store, rdb: snapshotter}
+	c.server = &command.Server{Logger: logger, Store: store, RDB: snapshotter, PubSub: pubsub.NewHub(), Config: cfg}
+	// Followers connect back to the port we announce, so it must be the one they can speak
+	port, tlsPort := cfg.Get("port"), cfg.Get("tls-port")
+	announced := port
+	if cfg.GetBool("tls-replication") {
+		announced = tlsPort
+	}
+	c.server.Replication = replication.New(announced, logger, c)
+	c.server.Replication.SetAuth(cfg.Get("masteruser"), cfg.Get("masterauth"))
 
-	logger.Info("Listening on tcp://0.0.0.0:6380", nil)
+	// Users must exist before the first client connects
+	c.server.ACL = acl.New(command.ACLCatalog)
+	if aclFile := cfg.Get("aclfile"); aclFile != "" {
+		// A missing file starts out empty, ACL SAVE creates it
+		if err := c.server.ACL.LoadFile(aclFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.Fatal(err, map[string]string{"path": aclFile})
+		}
+	}
+	// After the file, which would otherwise reset the default user
+	if requirePass := cfg.Get("requirepass"); requirePass != "" {
+		if err := c.server.ACL.SetRequirePass(requirePass); err != nil {
+			logger.Fatal(err, nil)
+		}
+	}
+	c.watchConfig(cfg)
 
-	store := store.NewInMemoryStore()
+	// Restore the keyspace before serving any client
+	// The AOF is more up to date than the snapshot so it wins when enabled
+	appendFilename := cfg.Get("appendfilename")
+	if cfg.GetBool("appendonly") {
+		policy, err := aof.ParseFsyncPolicy(cfg.Get("appendfsync"))
+		if err != nil {
+			logger.Fatal(err, nil)
+		}
+		replay := func(r io.Reader) (int64, error) { return session.Replay(r, c.server, nil) }
+		c.aof, err = aof.Open(appendFilename, policy, logger, replay)
+		if err != nil {
+			logger.Fatal(err, map[string]string{"path": appendFilename})
+		}
+		// Only attach the log once it has been replayed, otherwise it would append to itself
+		c.server.AOF = c.aof
+	} else if err := snapshotter.Load(); err != nil {
+		logger.Fatal(err, map[string]string{"path": dbFilename})
+	}
 
-	c := &Cache{listener: listener, logger: logger, done: make(chan os.Signal, 1), store: store}
+	go c.server.ActiveExpire()
+	go c.server.ClientsCron()
 
 	// Handle signals concurrently while the main thread listen to new connections
 	go func() {
 		signal.Notify(c.done, syscall.SIGINT, syscall.SIGTERM)
 		s := <-c.done // block until a signal is received
 		logger.Info("caught signal!", map[string]string{"signal": s.String()})
+		// Persist the keyspace so the next boot picks up where we left off
+		// Holding the lock keeps a new BGSAVE from starting, one already running is let finish first
+		c.server.Lock()
+		c.rdb.Wait()
+		if err := c.rdb.Save(); err != nil {
+			logger.Error(err, map[string]string{"path": dbFilename})
+		}
+		if c.aof != nil {
+			if err := c.aof.Close(); err != nil {
+				logger.Error(err, map[string]string{"path": appendFilename})
+			}
+		}
 		os.Exit(0)
 	}()
 
-	c.listen()
+	var listeners []net.Listener
+	if port != "0" {
+		listener, err := net.Listen("tcp", ":"+port)
+		if err != nil {
+			logger.Fatal(err, nil)
+		}
+		defer listener.Close()
+		logger.Info("Listening on tcp://0.0.0.0:"+port, nil)
+		listeners = append(listeners, listener)
+	}
+	if tlsPort != "0" {
+		clientAuth, err := tlsconfig.ParseAuthClients(cfg.Get("tls-auth-clients"))
+		if err != nil {
+			logger.Fatal(err, nil)
+		}
+		files := tlsconfig.Files{CertFile: cfg.Get("tls-cert-file"), KeyFile: cfg.Get("tls-key-file"), CAFile: cfg.Get("tls-ca-cert-file")}
+		certs, err := tlsconfig.NewReloader(files, clientAuth, logger)
+		if err != nil {
+			logger.Fatal(err, nil)
+		}
+		go certs.Watch()
+		if cfg.GetBool("tls-replication") {
+			c.server.Replication.SetTLS(certs.ClientConfig)
+		}
+
+		listener, err := tls.Listen("tcp", ":"+tlsPort, certs.ServerConfig())
+		if err != nil {
+			logger.Fatal(err, nil)
+		}
+		defer listener.Close()
+		logger.Info("Listening on tls://0.0.0.0:"+tlsPort, nil)
+		listeners = append(listeners, listener)
+	}
+	if len(listeners) == 0 {
+		logger.Fatal(errors.New("port and tls-port can't both be 0"), nil)
+	}
+
+	for _, listener := range listeners[1:] {
+		go c.listen(listener)
+	}
+	c.listen(listeners[0])
 }
 
-func (c *Cache) listen() {
+func (c *Cache) listen(listener net.Listener) {
 	for {
-		conn, err := c.listener.Accept()
+		conn, err := listener.Accept()
 		c.logger.Info("New connection", map[string]string{"connection": conn.LocalAddr().String()})
 		if err != nil {
 			select {
@@ -67,7 +196,73 @@ func (c *Cache) listen() {
 		// Alloing multiple clients to be served simultaneously
 		go func(conn net.Conn) {
 			defer c.wg.Done()
-			session.Start(conn, c.logger, c.store)
+			session.Start(conn, c.server)
 		}(conn)
 	}
 }
+
+// Keep what depends on a setting in line when CONFIG SET changes it
+func (c *Cache) watchConfig(cfg *config.Config) {
+	cfg.OnChange("loglevel", func(value string) error {
+		level, err := logger.ParseLevel(value)
+		if err != nil {
+			return err
+		}
+		c.logger.SetMinLevel(level)
+		return nil
+	})
+	// The limit and the policy are set together, each one keeps the current other
+	cfg.OnChange("maxmemory", func(value string) error {
+		limit, _ := helpers.ParseMemory(value)
+		_, policy := c.store.MaxMemory()
+		c.store.SetMaxMemory(limit, policy)
+		return nil
+	})
+	cfg.OnChange("maxmemory-policy", func(value string) error {
+		policy, err := store.ParsePolicy(value)
+		if err != nil {
+			return err
+		}
+		limit, _ := c.store.MaxMemory()
+		c.store.SetMaxMemory(limit, policy)
+		return nil
+	})
+	// Like at startup the password wins over the file, ACL LOAD included
+	cfg.OnChange("requirepass", func(value string) error {
+		return c.server.ACL.SetRequirePass(value)
+	})
+	// Taken into account the next time we connect to our leader
+	user, password := cfg.Get("masteruser"), cfg.Get("masterauth")
+	cfg.OnChange("masteruser", func(value string) error {
+		user = value
+		c.server.Replication.SetAuth(user, password)
+		return nil
+	})
+	cfg.OnChange("masterauth", func(value string) error {
+		password = value
+		c.server.Replication.SetAuth(user, password)
+		return nil
+	})
+}
+
+// Take the snapshot sent by our leader as the new keyspace
+func (c *Cache) Load(snapshot map[string]store.Entry) {
+	// Holding the lock keeps commands from reaching the old log before the rewrite starts
+	c.server.Lock()
+	defer c.server.Unlock()
+	c.store.Replace(snapshot)
+	// The old log describes a keyspace that no longer exists
+	if c.aof != nil {
+		// A rewrite already running is let finish first, ours replaces what it wrote
+		c.aof.Wait()
+		if err := c.aof.BackgroundRewrite(snapshot); err != nil {
+			c.logger.Error(err, nil)
+		}
+	}
+}
+
+// Run the command stream of our leader like an AOF that never ends
+func (c *Cache) Apply(r io.Reader, applied func(n int64)) error {
+	_, err := session.Replay(r, c.server, applied)
+	return err
+}
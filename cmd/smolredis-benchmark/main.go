@@ -0,0 +1,183 @@
+// Load generator measuring the throughput and latency of a smolredis server
+//
+//	smolredis-benchmark -c 50 -n 100000 -P 16 -t set,get -format csv
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/tlsconfig"
+)
+
+type config struct {
+	addr      string
+	clients   int
+	requests  int
+	dataSize  int
+	keyspace  int
+	pipeline  int
+	readRatio float64
+	tls       *tls.Config // Nil to connect in clear text
+}
+
+func main() {
+	host := flag.String("h", "127.0.0.1", "server hostname")
+	port := flag.String("p", "6380", "server port")
+	clients := flag.Int("c", 50, "number of parallel connections")
+	requests := flag.Int("n", 100000, "total number of requests per test")
+	dataSize := flag.Int("d", 3, "size in bytes of the values of SET, LPUSH and HSET")
+	keyspace := flag.Int("r", 0, "use random keys out of this many, 0 to always use the same key")
+	pipeline := flag.Int("P", 1, "number of requests sent at once by each client")
+	readRatio := flag.Float64("ratio", 0.8, "share of GETs in the mixed test")
+	tests := flag.String("t", strings.Join(DEFAULT_TESTS, ","), "comma separated tests to run")
+	format := flag.String("format", "text", "output format: text, csv or json")
+	useTLS := flag.Bool("tls", false, "establish a secure TLS connection")
+	sni := flag.String("sni", "", "server name indication for TLS, the hostname by default")
+	cacert := flag.String("cacert", "", "CA certificate file to verify the server with")
+	cert := flag.String("cert", "", "client certificate to authenticate with")
+	key := flag.String("key", "", "private key file of the client certificate")
+	insecure := flag.Bool("insecure", false, "allow insecure TLS connection by skipping certificate validation")
+	flag.Parse()
+
+	cfg := &config{
+		addr: net.JoinHostPort(*host, *port), clients: *clients, requests: *requests,
+		dataSize: *dataSize, keyspace: *keyspace, pipeline: *pipeline, readRatio: *readRatio,
+	}
+	if cfg.clients < 1 || cfg.requests < 1 || cfg.pipeline < 1 || cfg.dataSize < 0 || cfg.keyspace < 0 {
+		fmt.Fprintln(os.Stderr, "-c, -n and -P must be positive, -d and -r can't be negative")
+		os.Exit(1)
+	}
+	if *format != "text" && *format != "csv" && *format != "json" {
+		fmt.Fprintln(os.Stderr, "Unknown output format:", *format)
+		os.Exit(1)
+	}
+	if *useTLS {
+		if *sni == "" {
+			*sni = *host
+		}
+		var err error
+		cfg.tls, err = tlsconfig.ClientConfig(tlsconfig.Files{CertFile: *cert, KeyFile: *key, CAFile: *cacert}, *sni, *insecure)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	var reports []report
+	for _, name := range strings.Split(strings.ToLower(*tests), ",") {
+		w, ok := workloads[name]
+		if !ok {
+			fmt.Fprintln(os.Stderr, "Unknown test:", name)
+			os.Exit(1)
+		}
+		r, err := run(strings.ToUpper(name), w, cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		// Text reports are printed as soon as they are ready, the others all at once
+		if *format == "text" {
+			r.writeText(os.Stdout)
+		}
+		reports = append(reports, r)
+	}
+	if *format != "text" {
+		if err := writeReports(os.Stdout, reports, *format); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// Spread cfg.requests commands over cfg.clients connections and time each one of them
+func run(name string, w workload, cfg *config) (report, error) {
+	conns := make([]net.Conn, cfg.clients)
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	for i := range conns {
+		var conn net.Conn
+		var err error
+		if cfg.tls != nil {
+			conn, err = tls.DialWithDialer(dialer, "tcp", cfg.addr, cfg.tls)
+		} else {
+			conn, err = dialer.Dial("tcp", cfg.addr)
+		}
+		if err != nil {
+			return report{}, fmt.Errorf("could not connect to %s: %w", cfg.addr, err)
+		}
+		defer conn.Close()
+		conns[i] = conn
+	}
+
+	var (
+		remaining = int64(cfg.requests)
+		errors    atomic.Int64
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		latencies = make([]time.Duration, 0, cfg.requests)
+		failure   error
+	)
+	start := time.Now()
+	for i, conn := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			own, failed, err := client(conn, w, newGenerator(cfg, uint64(i)), cfg.pipeline, &remaining)
+			errors.Add(int64(failed))
+			mu.Lock()
+			latencies = append(latencies, own...)
+			if err != nil && failure == nil {
+				failure = err
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	if failure != nil {
+		return report{}, fmt.Errorf("%s: %w", name, failure)
+	}
+	return newReport(name, latencies, int(errors.Load()), elapsed, cfg), nil
+}
+
+// Send batches of pipeline commands until the shared budget runs out
+// The latency of a command goes from the moment its batch is sent to the moment its reply is read
+func client(conn net.Conn, w workload, g *generator, pipeline int, remaining *int64) ([]time.Duration, int, error) {
+	var latencies []time.Duration
+	failed := 0
+	r := resp.NewReader(bufio.NewReader(conn))
+	var out []byte
+	for {
+		n := int(min(atomic.AddInt64(remaining, -int64(pipeline))+int64(pipeline), int64(pipeline)))
+		if n <= 0 {
+			return latencies, failed, nil
+		}
+		out = out[:0]
+		for range n {
+			out = resp.AppendArray(out, w(g))
+		}
+		sent := time.Now()
+		if _, err := conn.Write(out); err != nil {
+			return latencies, failed, err
+		}
+		for range n {
+			rep, err := r.ReadValue()
+			if err != nil {
+				return latencies, failed, err
+			}
+			if isError(rep) {
+				failed++
+			}
+			latencies = append(latencies, time.Since(sent))
+		}
+	}
+}
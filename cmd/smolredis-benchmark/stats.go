@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Upper bounds of the buckets of the latency histogram
+var HISTOGRAM_BOUNDS = []time.Duration{
+	100 * time.Microsecond, 200 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond, time.Second,
+}
+
+// Outcome of one test, latencies in milliseconds
+type report struct {
+	Test      string  `json:"test"`
+	Requests  int     `json:"requests"`
+	Errors    int     `json:"errors"`
+	Clients   int     `json:"clients"`
+	Pipeline  int     `json:"pipeline"`
+	DataSize  int     `json:"data_size"`
+	Seconds   float64 `json:"seconds"`
+	RPS       float64 `json:"rps"`
+	AvgMs     float64 `json:"avg_ms"`
+	MinMs     float64 `json:"min_ms"`
+	P50Ms     float64 `json:"p50_ms"`
+	P99Ms     float64 `json:"p99_ms"`
+	P999Ms    float64 `json:"p999_ms"`
+	MaxMs     float64 `json:"max_ms"`
+	Histogram []int   `json:"histogram"` // Requests per bucket of HISTOGRAM_BOUNDS, the last one for anything slower
+}
+
+// Summarize the latency of every request
+func newReport(test string, latencies []time.Duration, errors int, elapsed time.Duration, cfg *config) report {
+	r := report{
+		Test: test, Requests: len(latencies), Errors: errors,
+		Clients: cfg.clients, Pipeline: cfg.pipeline, DataSize: cfg.dataSize,
+		Seconds:   elapsed.Seconds(),
+		Histogram: make([]int, len(HISTOGRAM_BOUNDS)+1),
+	}
+	if len(latencies) == 0 {
+		return r
+	}
+	r.RPS = float64(len(latencies)) / elapsed.Seconds()
+
+	slices.Sort(latencies)
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+		i, _ := slices.BinarySearch(HISTOGRAM_BOUNDS, l)
+		r.Histogram[i]++
+	}
+	r.AvgMs = ms(total / time.Duration(len(latencies)))
+	r.MinMs = ms(latencies[0])
+	r.P50Ms = ms(percentile(latencies, 50))
+	r.P99Ms = ms(percentile(latencies, 99))
+	r.P999Ms = ms(percentile(latencies, 99.9))
+	r.MaxMs = ms(latencies[len(latencies)-1])
+	return r
+}
+
+// Nearest rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Readable summary, like redis-benchmark
+func (r report) writeText(w io.Writer) {
+	fmt.Fprintf(w, "====== %s ======\n", r.Test)
+	fmt.Fprintf(w, "  %d requests completed in %.2f seconds\n", r.Requests, r.Seconds)
+	fmt.Fprintf(w, "  %d parallel clients, pipeline %d, %d bytes payload\n", r.Clients, r.Pipeline, r.DataSize)
+	if r.Errors > 0 {
+		fmt.Fprintf(w, "  %d errors\n", r.Errors)
+	}
+	fmt.Fprintf(w, "  throughput summary: %.2f requests per second\n", r.RPS)
+	fmt.Fprintf(w, "  latency summary (msec):\n")
+	fmt.Fprintf(w, "  %9s %9s %9s %9s %9s %9s\n", "avg", "min", "p50", "p99", "p99.9", "max")
+	fmt.Fprintf(w, "  %9.3f %9.3f %9.3f %9.3f %9.3f %9.3f\n", r.AvgMs, r.MinMs, r.P50Ms, r.P99Ms, r.P999Ms, r.MaxMs)
+	fmt.Fprintf(w, "  latency distribution:\n")
+	cumulative := 0
+	for i, n := range r.Histogram {
+		if n == 0 {
+			continue
+		}
+		cumulative += n
+		bound := "+inf"
+		if i < len(HISTOGRAM_BOUNDS) {
+			bound = fmt.Sprintf("%.3f", ms(HISTOGRAM_BOUNDS[i]))
+		}
+		fmt.Fprintf(w, "  %7.3f%% <= %s msec (%d)\n", 100*float64(cumulative)/float64(r.Requests), bound, n)
+	}
+	fmt.Fprintln(w)
+}
+
+var csvHeader = []string{"test", "requests", "errors", "rps", "avg_ms", "min_ms", "p50_ms", "p99_ms", "p999_ms", "max_ms"}
+
+func (r report) csvRecord() []string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	return []string{
+		r.Test, strconv.Itoa(r.Requests), strconv.Itoa(r.Errors),
+		f(r.RPS), f(r.AvgMs), f(r.MinMs), f(r.P50Ms), f(r.P99Ms), f(r.P999Ms), f(r.MaxMs),
+	}
+}
+
+func writeReports(w io.Writer, reports []report, format string) error {
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(csvHeader)
+		for _, r := range reports {
+			cw.Write(r.csvRecord())
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(reports)
+	default:
+		for _, r := range reports {
+			r.writeText(w)
+		}
+		return nil
+	}
+}
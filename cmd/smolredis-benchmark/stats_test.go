@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewReport(t *testing.T) {
+	// 1ms to 1000ms
+	var latencies []time.Duration
+	for i := 1000; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	cfg := &config{clients: 1, pipeline: 1}
+	r := newReport("SET", latencies, 2, 2*time.Second, cfg)
+
+	tests := []struct {
+		name     string
+		got      float64
+		expected float64
+	}{
+		{"rps", r.RPS, 500},
+		{"min", r.MinMs, 1},
+		{"p50", r.P50Ms, 500},
+		{"p99", r.P99Ms, 990},
+		{"p99.9", r.P999Ms, 999},
+		{"max", r.MaxMs, 1000},
+		{"avg", r.AvgMs, 500.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, tt.got)
+			}
+		})
+	}
+
+	total := 0
+	for _, n := range r.Histogram {
+		total += n
+	}
+	if total != 1000 {
+		t.Errorf("Expected every request in the histogram, got %d", total)
+	}
+	// 1ms is within the 1ms bucket, everything above 1s in the last one
+	if r.Histogram[3] != 1 || r.Histogram[len(r.Histogram)-1] != 0 {
+		t.Errorf("Expected bounds to be inclusive, got %v", r.Histogram)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	r := report{Test: "GET", Requests: 10, RPS: 1234.5}
+	var buf bytes.Buffer
+	if err := writeReports(&buf, []report{r}, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "GET,10,0,1234.500,") {
+		t.Errorf("Expected a header and one record, got %q", buf.String())
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+)
+
+// Builds the next command of a test, each client has its own generator
+type workload func(g *generator) []string
+
+var workloads = map[string]workload{
+	"set":   func(g *generator) []string { return []string{"SET", g.key(), g.value} },
+	"get":   func(g *generator) []string { return []string{"GET", g.key()} },
+	"lpush": func(g *generator) []string { return []string{"LPUSH", "mylist", g.value} },
+	"lpop":  func(g *generator) []string { return []string{"LPOP", "mylist"} },
+	"hset":  func(g *generator) []string { return []string{"HSET", "myhash", g.key(), g.value} },
+	"sadd":  func(g *generator) []string { return []string{"SADD", "myset", g.key()} },
+	// GETs and SETs on the same keys, -ratio of them being GETs
+	"mixed": func(g *generator) []string {
+		if g.rand.Float64() < g.readRatio {
+			return []string{"GET", g.key()}
+		}
+		return []string{"SET", g.key(), g.value}
+	},
+}
+
+// Order the tests run in when -t is not given, SET first so GET finds the keys
+var DEFAULT_TESTS = []string{"set", "get", "mixed", "lpush", "lpop", "hset", "sadd"}
+
+type generator struct {
+	rand      *rand.Rand
+	keyspace  int // Number of distinct keys, 0 to always use the same key
+	value     string
+	readRatio float64
+}
+
+func newGenerator(cfg *config, seed uint64) *generator {
+	return &generator{
+		rand:      rand.New(rand.NewPCG(seed, seed)),
+		keyspace:  cfg.keyspace,
+		value:     strings.Repeat("x", cfg.dataSize),
+		readRatio: cfg.readRatio,
+	}
+}
+
+// Random key out of the keyspace, zero padded like redis-benchmark's key:__rand_int__
+func (g *generator) key() string {
+	if g.keyspace == 0 {
+		return "key:__rand_int__"
+	}
+	return fmt.Sprintf("key:%012d", g.rand.IntN(g.keyspace))
+}
+
+// Whether the reply is an error or holds one, e.g. within the reply of EXEC
+func isError(rep resp.Value) bool {
+	if rep.Type == resp.ERROR || rep.Type == resp.BLOB_ERROR {
+		return true
+	}
+	return slices.ContainsFunc(rep.Array, isError)
+}
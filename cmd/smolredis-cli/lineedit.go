@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Lines kept in the history file, older ones are dropped
+const HISTORY_SIZE = 1000
+
+var errInterrupted = errors.New("interrupted")
+
+// Reads commands typed by the user, with line editing and history when stdin is a terminal
+type lineEditor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	fd      int
+	tty     bool
+	history []string
+	path    string // History file, empty to not persist it
+}
+
+func newLineEditor() *lineEditor {
+	e := &lineEditor{in: bufio.NewReader(os.Stdin), out: os.Stdout, fd: int(os.Stdin.Fd())}
+	e.tty = isTerminal(e.fd)
+	if home, err := os.UserHomeDir(); err == nil {
+		e.path = filepath.Join(home, ".smolredis_cli_history")
+		e.loadHistory()
+	}
+	return e
+}
+
+// Read one line, io.EOF once the input is over or on Ctrl-D, errInterrupted on Ctrl-C
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	if !e.tty {
+		return e.readPlain(prompt)
+	}
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return e.readPlain(prompt)
+	}
+	defer restore()
+	return e.edit(prompt)
+}
+
+func (e *lineEditor) readPlain(prompt string) (string, error) {
+	if e.tty {
+		fmt.Fprint(e.out, prompt)
+	}
+	line, err := e.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Emacs style editing: arrows, Home/End, Ctrl-A/E/U/K/W and history with Up/Down
+func (e *lineEditor) edit(prompt string) (string, error) {
+	var line []rune
+	pos := 0
+	browsing := len(e.history) // Position in history, len(history) is the line being typed
+	draft := ""
+
+	redraw := func() {
+		// Back to the start of the line, print it, clear what is left and place the cursor
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(line))
+		if back := len(line) - pos; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+	recall := func(i int) {
+		if browsing == len(e.history) {
+			draft = string(line)
+		}
+		browsing = i
+		if i == len(e.history) {
+			line = []rune(draft)
+		} else {
+			line = []rune(e.history[i])
+		}
+		pos = len(line)
+	}
+
+	redraw()
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(line)
+		case 11: // Ctrl-K
+			line = line[:pos]
+		case 21: // Ctrl-U
+			line = line[pos:]
+			pos = 0
+		case 23: // Ctrl-W
+			start := pos
+			for start > 0 && line[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && line[start-1] != ' ' {
+				start--
+			}
+			line = append(line[:start], line[pos:]...)
+			pos = start
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case 27: // Escape sequence, e.g. ESC [ A for Up
+			key := e.escape()
+			switch key {
+			case "A":
+				if browsing > 0 {
+					recall(browsing - 1)
+				}
+			case "B":
+				if browsing < len(e.history) {
+					recall(browsing + 1)
+				}
+			case "C":
+				pos = min(pos+1, len(line))
+			case "D":
+				pos = max(pos-1, 0)
+			case "H", "1~":
+				pos = 0
+			case "F", "4~":
+				pos = len(line)
+			case "3~": // Delete
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			}
+		default:
+			if r >= ' ' {
+				line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+				pos++
+			}
+		}
+		redraw()
+	}
+}
+
+// Read the rest of an escape sequence, e.g. A for ESC [ A or 3~ for ESC [ 3 ~
+func (e *lineEditor) escape() string {
+	b, err := e.in.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return ""
+	}
+	var seq []byte
+	for {
+		b, err := e.in.ReadByte()
+		if err != nil {
+			return ""
+		}
+		seq = append(seq, b)
+		if b >= 0x40 && b <= 0x7e {
+			return string(seq)
+		}
+	}
+}
+
+// Remember a line for Up/Down and the next sessions, skipping repeats
+func (e *lineEditor) addHistory(line string) {
+	if line == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > HISTORY_SIZE {
+		e.history = e.history[len(e.history)-HISTORY_SIZE:]
+	}
+}
+
+func (e *lineEditor) loadHistory() {
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		e.addHistory(line)
+	}
+}
+
+func (e *lineEditor) saveHistory() {
+	if e.path == "" || !e.tty {
+		return
+	}
+	data := strings.Join(e.history, "\n") + "\n"
+	if err := os.WriteFile(e.path, []byte(data), 0o600); err != nil {
+		fmt.Fprintln(os.Stderr, "Could not save history:", err)
+	}
+}
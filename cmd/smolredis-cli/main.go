@@ -0,0 +1,251 @@
+// Command line client for smolredis
+//
+//	smolredis-cli                      Interactive mode
+//	smolredis-cli -h host -p port GET key   Run a single command and print its reply
+//	smolredis-cli --pipe < commands.resp    Send RESP commands read from stdin
+//	smolredis-cli --tls --cacert ca.crt     Connect to the TLS port
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/tlsconfig"
+)
+
+type cli struct {
+	addr string
+	tls  *tls.Config // Nil to connect in clear text
+	conn net.Conn
+	r    *resp.Reader
+	raw  bool // Print replies as is, for scripts
+}
+
+func main() {
+	host := flag.String("h", "127.0.0.1", "server hostname")
+	port := flag.String("p", "6380", "server port")
+	pipe := flag.Bool("pipe", false, "transfer raw RESP commands from stdin to the server")
+	raw := flag.Bool("raw", false, "print replies as is, the default when stdout is not a terminal")
+	useTLS := flag.Bool("tls", false, "establish a secure TLS connection")
+	sni := flag.String("sni", "", "server name indication for TLS, the hostname by default")
+	cacert := flag.String("cacert", "", "CA certificate file to verify the server with")
+	cert := flag.String("cert", "", "client certificate to authenticate with")
+	key := flag.String("key", "", "private key file of the client certificate")
+	insecure := flag.Bool("insecure", false, "allow insecure TLS connection by skipping certificate validation")
+	flag.Parse()
+
+	c := &cli{addr: net.JoinHostPort(*host, *port), raw: *raw || !isTerminal(int(os.Stdout.Fd()))}
+	if *useTLS {
+		if *sni == "" {
+			*sni = *host
+		}
+		config, err := tlsconfig.ClientConfig(tlsconfig.Files{CertFile: *cert, KeyFile: *key, CAFile: *cacert}, *sni, *insecure)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		c.tls = config
+	}
+	if err := c.connect(); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not connect to %s: %v\n", c.addr, err)
+		os.Exit(1)
+	}
+	defer c.conn.Close()
+
+	switch {
+	case *pipe:
+		if err := c.pipe(os.Stdin); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case flag.NArg() > 0:
+		if err := c.run(flag.Args()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	default:
+		c.repl()
+	}
+}
+
+func (c *cli) connect() error {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	var conn net.Conn
+	var err error
+	if c.tls != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.addr, c.tls)
+	} else {
+		conn, err = dialer.Dial("tcp", c.addr)
+	}
+	if err != nil {
+		return err
+	}
+	c.conn, c.r = conn, resp.NewReader(bufio.NewReader(conn))
+	return nil
+}
+
+// Send one command and print its reply
+// Subscribing keeps printing messages as they arrive until the connection is closed
+func (c *cli) run(args []string) error {
+	if _, err := c.conn.Write(resp.AppendArray(nil, args)); err != nil {
+		return err
+	}
+	rep, err := c.r.ReadValue()
+	if err != nil {
+		return err
+	}
+	c.print(rep)
+
+	switch strings.ToUpper(args[0]) {
+	case "SUBSCRIBE", "PSUBSCRIBE":
+		// One confirmation per channel, then messages forever
+		for {
+			rep, err := c.r.ReadValue()
+			if err != nil {
+				return err
+			}
+			c.print(rep)
+		}
+	}
+	return nil
+}
+
+func (c *cli) print(rep resp.Value) {
+	if c.raw {
+		fmt.Print(raw(rep))
+	} else {
+		fmt.Print(pretty(rep))
+	}
+}
+
+func (c *cli) repl() {
+	editor := newLineEditor()
+	defer editor.saveHistory()
+	prompt := c.addr + "> "
+	for {
+		line, err := editor.readLine(prompt)
+		if errors.Is(err, errInterrupted) {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		args, err := splitArgs(line)
+		if err != nil {
+			fmt.Println("Invalid argument(s)")
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		editor.addHistory(line)
+
+		switch strings.ToLower(args[0]) {
+		case "quit", "exit":
+			return
+		case "clear":
+			fmt.Print("\x1b[H\x1b[2J")
+			continue
+		}
+
+		if c.conn == nil {
+			if err := c.connect(); err != nil {
+				fmt.Printf("Could not connect to %s: %v\n", c.addr, err)
+				continue
+			}
+		}
+		if err := c.run(args); err != nil {
+			// Try again with a fresh connection on the next command
+			fmt.Println("Error:", err)
+			c.conn.Close()
+			c.conn = nil
+		}
+	}
+}
+
+// Forward every RESP command from in, then wait for all the replies
+// and report how many there were and how many were errors, like redis-cli --pipe
+func (c *cli) pipe(in io.Reader) error {
+	sent := make(chan int, 1)
+	failed := make(chan error, 1)
+	go func() {
+		n, err := c.send(in)
+		if err != nil {
+			failed <- err
+			return
+		}
+		fmt.Fprintln(os.Stderr, "All data transferred. Waiting for the last reply...")
+		sent <- n
+	}()
+
+	replies := make(chan resp.Value)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			rep, err := c.r.ReadValue()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			replies <- rep
+		}
+	}()
+
+	received, errs, total := 0, 0, -1
+	for total != received {
+		select {
+		case n := <-sent:
+			total = n
+		case err := <-failed:
+			return err
+		case err := <-readErr:
+			return fmt.Errorf("reading replies after %d of them: %w", received, err)
+		case rep := <-replies:
+			received++
+			if rep.Type == resp.ERROR || rep.Type == resp.BLOB_ERROR {
+				errs++
+				if errs <= 10 {
+					fmt.Fprintln(os.Stderr, rep.Str)
+				}
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "errors: %d, replies: %d\n", errs, received)
+	if errs > 0 {
+		return errors.New("some commands failed")
+	}
+	return nil
+}
+
+// Re-encode the commands read from in so each one is checked before it goes out
+// Return how many were sent
+func (c *cli) send(in io.Reader) (int, error) {
+	r := resp.NewReader(bufio.NewReader(in))
+	w := bufio.NewWriter(c.conn)
+	n := 0
+	for {
+		args, err := r.ReadCommand()
+		if err == io.EOF {
+			return n, w.Flush()
+		}
+		if err != nil {
+			return n, fmt.Errorf("command %d: %w", n+1, err)
+		}
+		if len(args) == 0 {
+			return n, fmt.Errorf("command %d: empty command", n+1)
+		}
+		if _, err := w.Write(resp.AppendArray(nil, args)); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
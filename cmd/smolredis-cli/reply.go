@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+)
+
+// Human friendly form of a reply, e.g. (integer) 1 or a numbered list for arrays
+func pretty(rep resp.Value) string {
+	var b strings.Builder
+	writePretty(&b, rep, "")
+	return b.String()
+}
+
+// indent is the prefix of every line but the first, so nested arrays line up
+func writePretty(b *strings.Builder, rep resp.Value, indent string) {
+	if rep.Null {
+		b.WriteString("(nil)\n")
+		return
+	}
+	switch rep.Type {
+	case resp.SIMPLE_STRING, resp.VERBATIM_STRING:
+		b.WriteString(rep.Str + "\n")
+	case resp.ERROR, resp.BLOB_ERROR:
+		b.WriteString("(error) " + rep.Str + "\n")
+	case resp.INTEGER:
+		b.WriteString("(integer) " + strconv.FormatInt(rep.Int, 10) + "\n")
+	case resp.DOUBLE:
+		b.WriteString("(double) " + formatDouble(rep.Float) + "\n")
+	case resp.BOOLEAN:
+		if rep.Bool {
+			b.WriteString("(true)\n")
+		} else {
+			b.WriteString("(false)\n")
+		}
+	case resp.BIG_NUMBER:
+		b.WriteString("(big number) " + rep.Str + "\n")
+	case resp.BULK_STRING:
+		b.WriteString(quote(rep.Str) + "\n")
+	case resp.ARRAY, resp.SET, resp.PUSH, resp.MAP:
+		writeAggregate(b, rep, indent)
+	}
+}
+
+func writeAggregate(b *strings.Builder, rep resp.Value, indent string) {
+	if len(rep.Array) == 0 {
+		switch rep.Type {
+		case resp.MAP:
+			b.WriteString("(empty hash)\n")
+		case resp.SET:
+			b.WriteString("(empty set)\n")
+		default:
+			b.WriteString("(empty array)\n")
+		}
+		return
+	}
+	// Sets are numbered with ~ and maps with #, like redis-cli
+	sep := ")"
+	switch rep.Type {
+	case resp.SET:
+		sep = "~"
+	case resp.MAP:
+		sep = "#"
+	}
+	step := 1
+	if rep.Type == resp.MAP {
+		step = 2
+	}
+	count := len(rep.Array) / step
+	width := len(strconv.Itoa(count))
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			b.WriteString(indent)
+		}
+		label := fmt.Sprintf("%*d%s ", width, i+1, sep)
+		b.WriteString(label)
+		nested := indent + strings.Repeat(" ", len(label))
+		if rep.Type == resp.MAP {
+			key := strings.TrimSuffix(pretty(rep.Array[2*i]), "\n")
+			b.WriteString(key + " => ")
+			writePretty(b, rep.Array[2*i+1], nested+strings.Repeat(" ", len(key)+4))
+		} else {
+			writePretty(b, rep.Array[i], nested)
+		}
+	}
+}
+
+// Raw form for scripts: strings as is and one element per line
+func raw(rep resp.Value) string {
+	var b strings.Builder
+	writeRaw(&b, rep)
+	return b.String()
+}
+
+func writeRaw(b *strings.Builder, rep resp.Value) {
+	if rep.Null {
+		b.WriteString("\n")
+		return
+	}
+	switch rep.Type {
+	case resp.ARRAY, resp.SET, resp.PUSH, resp.MAP:
+		for _, item := range rep.Array {
+			writeRaw(b, item)
+		}
+	case resp.BOOLEAN:
+		if rep.Bool {
+			b.WriteString("1\n")
+		} else {
+			b.WriteString("0\n")
+		}
+	case resp.INTEGER:
+		b.WriteString(strconv.FormatInt(rep.Int, 10) + "\n")
+	case resp.DOUBLE:
+		b.WriteString(formatDouble(rep.Float) + "\n")
+	default:
+		b.WriteString(rep.Str + "\n")
+	}
+}
+
+// Spelled like the server sends it, e.g. inf rather than +Inf
+func formatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Double quoted with quotes, backslashes and non printable bytes escaped, like redis-cli does
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+)
+
+func TestPretty(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"status", "+OK\r\n", "OK\n"},
+		{"error", "-ERR oops\r\n", "(error) ERR oops\n"},
+		{"integer", ":3\r\n", "(integer) 3\n"},
+		{"bulk string with escapes", "$5\r\na\"b\n\x01\r\n", `"a\"b\n\x01"` + "\n"},
+		{"nil", "$-1\r\n", "(nil)\n"},
+		{"empty array", "*0\r\n", "(empty array)\n"},
+		{"nested array", "*2\r\n*2\r\n$1\r\na\r\n:1\r\n$1\r\nb\r\n", "1) 1) \"a\"\n   2) (integer) 1\n2) \"b\"\n"},
+		{"map", "%1\r\n$1\r\nk\r\n*1\r\n$1\r\nv\r\n", "1# \"k\" => 1) \"v\"\n"},
+		{"boolean", "#t\r\n", "(true)\n"},
+		{"double", ",1.5\r\n", "(double) 1.5\n"},
+		{"infinite double", ",-inf\r\n", "(double) -inf\n"},
+		{"verbatim string", "=7\r\ntxt:abc\r\n", "abc\n"},
+		{"empty set", "~0\r\n", "(empty set)\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep, err := resp.NewReader(bufio.NewReader(strings.NewReader(tt.input))).ReadValue()
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got := pretty(rep); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPrettyAlignsLongArrays(t *testing.T) {
+	input := "*10\r\n" + strings.Repeat(":1\r\n", 10)
+	rep, err := resp.NewReader(bufio.NewReader(strings.NewReader(input))).ReadValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(pretty(rep), "\n")
+	if lines[0] != " 1) (integer) 1" || lines[9] != "10) (integer) 1" {
+		t.Errorf("Expected numbers aligned to the right, got %q and %q", lines[0], lines[9])
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import "errors"
+
+var errUnbalancedQuotes = errors.New("unbalanced quotes in request")
+
+// Split a line into arguments the way the server parses inline commands:
+// arguments are separated by spaces and a double quoted one may contain spaces and \"
+// Any other backslash is kept as is
+// Unlike the server, "" is kept as an empty argument since we send RESP
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	i := 0
+	for {
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+
+		if line[i] != '"' {
+			start := i
+			for i < len(line) && line[i] != ' ' {
+				i++
+			}
+			args = append(args, line[start:i])
+			continue
+		}
+
+		i++
+		var arg []byte
+		for i < len(line) && line[i] != '"' {
+			if line[i] == '\\' && i+1 < len(line) && line[i+1] == '"' {
+				arg = append(arg, '"')
+				i += 2
+				continue
+			}
+			arg = append(arg, line[i])
+			i++
+		}
+		if i == len(line) {
+			return nil, errUnbalancedQuotes
+		}
+		i++
+		args = append(args, string(arg))
+	}
+}
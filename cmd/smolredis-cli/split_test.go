@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+		err      bool
+	}{
+		{"GET a", []string{"GET", "a"}, false},
+		{"  SET   a  1 ", []string{"SET", "a", "1"}, false},
+		{`SET text "quoted \"text\" here"`, []string{"SET", "text", `quoted "text" here`}, false},
+		{`SET path "C:\dir"`, []string{"SET", "path", `C:\dir`}, false},
+		{`SET empty ""`, []string{"SET", "empty", ""}, false},
+		{`GET "unbalanced`, nil, true},
+		{"", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := splitArgs(tt.input)
+			if (err != nil) != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
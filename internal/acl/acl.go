@@ -0,0 +1,349 @@
+package acl
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/helpers"
+)
+
+// User every connection starts as, unless it has a password
+const DEFAULT_USER = "default"
+
+// Why a request was refused, as ACL LOG reports it
+const (
+	REASON_AUTH    = "auth"
+	REASON_COMMAND = "command"
+	REASON_KEY     = "key"
+	REASON_CHANNEL = "channel"
+)
+
+// Entries kept by ACL LOG, older ones are dropped
+const LOG_MAX_LEN = 128
+
+// Denials of the same thing within this window are counted in a single entry
+const LOG_GROUP_WINDOW = 60 * time.Second
+
+var (
+	ErrNoFile      = errors.New("This Redis instance is not configured to use an ACL file. You may want to specify users via the ACL SETUSER command and then issue a CONFIG REWRITE (assuming you have a Redis configuration file set) in order to store users in the Redis configuration.")
+	ErrDefaultUser = errors.New("The 'default' user cannot be removed")
+	ErrBadUsername = errors.New("Usernames can't contain spaces or null characters")
+)
+
+// What the rules refer to, so a typo in a rule is caught when it is set rather than ignored
+type Catalog interface {
+	// Whether name is a command, or command|subcommand, in lower case
+	IsCommand(name string) bool
+	// Whether name is a category, in lower case and without the @
+	IsCategory(name string) bool
+}
+
+// What a command is about to do, checked against the rules of the user running it
+type Request struct {
+	Command    string   // Lower case name
+	Subcommand string   // Lower case first argument of commands that have subcommands
+	Categories []string // Without the @
+	Keys       []string
+	Channels   []string
+	Patterns   bool // Channels are patterns given to PSUBSCRIBE
+}
+
+// Why a request was refused and what it was refused on, e.g. the key
+type Denial struct {
+	Reason string
+	Object string
+}
+
+type LogEntry struct {
+	Count      int
+	Reason     string
+	Context    string // toplevel or multi
+	Object     string
+	Username   string
+	ClientInfo string
+	EntryID    int64
+	Created    time.Time
+	Updated    time.Time
+}
+
+// Users of a server and the denials they ran into
+type ACL struct {
+	catalog Catalog
+
+	mu      sync.RWMutex // Guard the fields below and the users themselves
+	users   map[string]*User
+	log     []*LogEntry // Newest first
+	entries int64       // Entries ever logged, the next one gets it as ID
+	file    string      // Set once users are loaded from a file
+	// Password of the default user from requirepass, it wins over the file
+	requirePass string
+}
+
+// Only the default user exists, allowed everything without a password
+func New(catalog Catalog) *ACL {
+	return &ACL{
+		catalog: catalog,
+		users:   map[string]*User{DEFAULT_USER: newDefaultUser()},
+	}
+}
+
+func newDefaultUser() *User {
+	return &User{
+		name:     DEFAULT_USER,
+		enabled:  true,
+		nopass:   true,
+		keys:     []string{"*"},
+		channels: []string{"*"},
+		commands: []commandRule{{allow: true, name: "@all"}},
+	}
+}
+
+// Create the user if needed then apply the rules in order
+// Either every rule applies or the user is left untouched
+func (a *ACL) SetUser(name string, rules []string) error {
+	if strings.ContainsAny(name, " \x00") {
+		return ErrBadUsername
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	u, ok := a.users[name]
+	if !ok {
+		u = newUser(name)
+	}
+	updated := u.clone()
+	for _, rule := range rules {
+		if err := updated.apply(rule, a.catalog); err != nil {
+			return fmt.Errorf("Error in ACL SETUSER modifier '%s': %w", rule, err)
+		}
+	}
+	// Connections authenticated as the user see the change with their next command
+	*u = *updated
+	a.users[name] = u
+	return nil
+}
+
+// Give the default user a password like requirepass does, now and every time the file is loaded
+// An empty password lets the default user in without one again
+func (a *ACL) SetRequirePass(password string) error {
+	a.mu.Lock()
+	a.requirePass = password
+	a.mu.Unlock()
+	return a.SetUser(DEFAULT_USER, requirePassRules(password))
+}
+
+func requirePassRules(password string) []string {
+	if password == "" {
+		return []string{"resetpass", "nopass"}
+	}
+	return []string{"resetpass", ">" + password}
+}
+
+// Delete the users and return how many existed
+func (a *ACL) DelUser(names ...string) (int, error) {
+	if slices.Contains(names, DEFAULT_USER) {
+		return 0, ErrDefaultUser
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	deleted := 0
+	for _, name := range names {
+		if u, ok := a.users[name]; ok {
+			u.removed = true
+			delete(a.users, name)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// What ACL GETUSER shows about a user
+type UserInfo struct {
+	Flags     []string
+	Passwords []string // Hashes
+	Commands  string
+	Keys      string
+	Channels  string
+}
+
+func (a *ACL) GetUser(name string) (UserInfo, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	u, ok := a.users[name]
+	if !ok {
+		return UserInfo{}, false
+	}
+	info := UserInfo{
+		Flags:     u.flags(),
+		Passwords: slices.Clone(u.passwords),
+		Commands:  u.describeCommands(),
+	}
+	if len(u.keys) > 0 {
+		info.Keys = "~" + strings.Join(u.keys, " ~")
+	}
+	if len(u.channels) > 0 {
+		info.Channels = "&" + strings.Join(u.channels, " &")
+	}
+	return info, true
+}
+
+// Names of every user, sorted
+func (a *ACL) Users() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	names := make([]string, 0, len(a.users))
+	for name := range a.users {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// A line per user with the rules that rebuild it, the format of the ACL file
+func (a *ACL) List() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.list()
+}
+
+func (a *ACL) list() []string {
+	lines := make([]string, 0, len(a.users))
+	for _, u := range a.users {
+		lines = append(lines, "user "+u.name+" "+u.describe())
+	}
+	slices.Sort(lines)
+	return lines
+}
+
+// The user if the password is one of its own and it is enabled, nil otherwise
+func (a *ACL) Authenticate(name, password string) *User {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	u, ok := a.users[name]
+	if !ok || !u.checkPassword(password) {
+		return nil
+	}
+	return u
+}
+
+// The default user when it needs no password, so new connections can skip AUTH
+func (a *ACL) AutoLogin() *User {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	u := a.users[DEFAULT_USER]
+	if !u.enabled || !u.nopass {
+		return nil
+	}
+	return u
+}
+
+// Whether the user was deleted since it authenticated
+func (a *ACL) Removed(u *User) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return u.removed
+}
+
+// Nil if the user may run the request
+func (a *ACL) Check(u *User, req Request) *Denial {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if !u.canRun(req.Command, req.Subcommand, req.Categories) {
+		object := req.Command
+		if req.Subcommand != "" {
+			object += "|" + req.Subcommand
+		}
+		return &Denial{Reason: REASON_COMMAND, Object: object}
+	}
+	for _, key := range req.Keys {
+		if !matchesAny(u.keys, key) {
+			return &Denial{Reason: REASON_KEY, Object: key}
+		}
+	}
+	for _, channel := range req.Channels {
+		// A pattern could match channels the user can't see, so it has to be allowed as is
+		allowed := matchesAny(u.channels, channel)
+		if req.Patterns {
+			allowed = slices.Contains(u.channels, "*") || slices.Contains(u.channels, channel)
+		}
+		if !allowed {
+			return &Denial{Reason: REASON_CHANNEL, Object: channel}
+		}
+	}
+	return nil
+}
+
+func matchesAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if helpers.GlobMatch(pattern, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Record a denial for ACL LOG
+func (a *ACL) Log(d Denial, context, username, clientInfo string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for i, e := range a.log {
+		if e.Reason == d.Reason && e.Context == context && e.Object == d.Object && e.Username == username &&
+			now.Sub(e.Updated) < LOG_GROUP_WINDOW {
+			e.Count++
+			e.Updated = now
+			e.ClientInfo = clientInfo
+			// The entry moves back to the top
+			copy(a.log[1:i+1], a.log[:i])
+			a.log[0] = e
+			return
+		}
+	}
+
+	e := &LogEntry{
+		Count:      1,
+		Reason:     d.Reason,
+		Context:    context,
+		Object:     d.Object,
+		Username:   username,
+		ClientInfo: clientInfo,
+		EntryID:    a.entries,
+		Created:    now,
+		Updated:    now,
+	}
+	a.entries++
+	a.log = append([]*LogEntry{e}, a.log...)
+	if len(a.log) > LOG_MAX_LEN {
+		a.log = a.log[:LOG_MAX_LEN]
+	}
+}
+
+// The count most recent entries, newest first
+func (a *ACL) Entries(count int) []LogEntry {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	count = min(count, len(a.log))
+	entries := make([]LogEntry, count)
+	for i := range entries {
+		entries[i] = *a.log[i]
+	}
+	return entries
+}
+
+func (a *ACL) ResetLog() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.log = nil
+}
@@ -0,0 +1,232 @@
+package acl
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type fakeCatalog struct{}
+
+func (fakeCatalog) IsCommand(name string) bool {
+	name, _, _ = strings.Cut(name, "|")
+	return slices.Contains([]string{"get", "set", "del", "publish", "subscribe", "psubscribe", "config"}, name)
+}
+
+func (fakeCatalog) IsCategory(name string) bool {
+	return slices.Contains([]string{"all", "read", "write", "keyspace", "pubsub", "admin"}, name)
+}
+
+var (
+	getReq = Request{Command: "get", Categories: []string{"read", "string"}, Keys: []string{"cache:1"}}
+	setReq = Request{Command: "set", Categories: []string{"write", "string"}, Keys: []string{"cache:1"}}
+	delReq = Request{Command: "del", Categories: []string{"write", "keyspace"}, Keys: []string{"session:1"}}
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []string
+		req      Request
+		expected *Denial
+	}{
+		{"Denied by default", []string{"on", "allkeys"}, getReq, &Denial{REASON_COMMAND, "get"}},
+		{"Allowed by category", []string{"on", "allkeys", "+@read"}, getReq, nil},
+		{"Category doesn't cover others", []string{"on", "allkeys", "+@read"}, setReq, &Denial{REASON_COMMAND, "set"}},
+		{"Command denied after its category", []string{"on", "allkeys", "+@write", "-set"}, setReq, &Denial{REASON_COMMAND, "set"}},
+		{"Command allowed after all denied", []string{"on", "allkeys", "+@write", "-@all", "+set"}, setReq, nil},
+		{"Key pattern", []string{"on", "~cache:*", "+@all"}, getReq, nil},
+		{"Key outside the patterns", []string{"on", "~cache:*", "+@all"}, delReq, &Denial{REASON_KEY, "session:1"}},
+		{"Subcommand", []string{"on", "+config|get"}, Request{Command: "config", Subcommand: "get"}, nil},
+		{"Other subcommand", []string{"on", "+config|get"}, Request{Command: "config", Subcommand: "set"}, &Denial{REASON_COMMAND, "config|set"}},
+		{"Channel pattern", []string{"on", "&news.*", "+@all"}, Request{Command: "publish", Channels: []string{"news.tech"}}, nil},
+		{"Channel outside the patterns", []string{"on", "&news.*", "+@all"}, Request{Command: "publish", Channels: []string{"sports"}}, &Denial{REASON_CHANNEL, "sports"}},
+		{"Pattern must be allowed as is", []string{"on", "&news.*", "+@all"}, Request{Command: "psubscribe", Channels: []string{"news.t*"}, Patterns: true}, &Denial{REASON_CHANNEL, "news.t*"}},
+		{"Same pattern", []string{"on", "&news.*", "+@all"}, Request{Command: "psubscribe", Channels: []string{"news.*"}, Patterns: true}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := New(fakeCatalog{})
+			if err := a.SetUser("alice", append([]string{"nopass"}, tt.rules...)); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got := a.Check(a.Authenticate("alice", ""), tt.req)
+			if (got == nil) != (tt.expected == nil) || (got != nil && *got != *tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSetUserErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []string
+		expected string
+	}{
+		{"Unknown rule", []string{"on", "sometimes"}, "Error in ACL SETUSER modifier 'sometimes': Syntax error"},
+		{"Unknown category", []string{"+@nope"}, "Error in ACL SETUSER modifier '+@nope': Unknown command or category name in ACL"},
+		{"Unknown command", []string{"+nope"}, "Error in ACL SETUSER modifier '+nope': Unknown command or category name in ACL"},
+		{"Bad hash", []string{"#abc"}, "Error in ACL SETUSER modifier '#abc': The password hash must be exactly 64 characters and contain only lowercase hexadecimal characters"},
+		{"Missing password", []string{"<secret"}, "Error in ACL SETUSER modifier '<secret': The password you are trying to remove from the user does not exist"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := New(fakeCatalog{})
+			err := a.SetUser("alice", tt.rules)
+			if err == nil || err.Error() != tt.expected {
+				t.Fatalf("Expected %q, got %v", tt.expected, err)
+			}
+			// Nothing applies when a rule is wrong
+			if slices.Contains(a.Users(), "alice") {
+				t.Errorf("Expected alice not to be created")
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	a := New(fakeCatalog{})
+	if a.AutoLogin() == nil {
+		t.Fatalf("Expected the default user to need no password")
+	}
+	a.SetUser(DEFAULT_USER, []string{"resetpass", ">secret"})
+	if a.AutoLogin() != nil {
+		t.Errorf("Expected the default user to need a password")
+	}
+	if a.Authenticate(DEFAULT_USER, "wrong") != nil {
+		t.Errorf("Expected a wrong password to be refused")
+	}
+	u := a.Authenticate(DEFAULT_USER, "secret")
+	if u == nil {
+		t.Fatalf("Expected the password to be accepted")
+	}
+
+	a.SetUser(DEFAULT_USER, []string{"off"})
+	if a.Authenticate(DEFAULT_USER, "secret") != nil {
+		t.Errorf("Expected a disabled user to be refused")
+	}
+
+	a.SetUser("bob", []string{"on", ">pw"})
+	b := a.Authenticate("bob", "pw")
+	if _, err := a.DelUser(DEFAULT_USER); err != ErrDefaultUser {
+		t.Errorf("Expected %v, got %v", ErrDefaultUser, err)
+	}
+	if n, _ := a.DelUser("bob", "nobody"); n != 1 {
+		t.Errorf("Expected 1, got %d", n)
+	}
+	if !a.Removed(b) {
+		t.Errorf("Expected bob to be removed")
+	}
+}
+
+func TestLog(t *testing.T) {
+	a := New(fakeCatalog{})
+	a.Log(Denial{REASON_KEY, "a"}, "toplevel", "alice", "")
+	a.Log(Denial{REASON_KEY, "b"}, "toplevel", "alice", "")
+	a.Log(Denial{REASON_KEY, "a"}, "toplevel", "alice", "")
+
+	entries := a.Entries(10)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2, got %d", len(entries))
+	}
+	// The repeated denial is grouped and moves to the top
+	if entries[0].Object != "a" || entries[0].Count != 2 || entries[0].EntryID != 0 {
+		t.Errorf("Expected a counted twice, got %+v", entries[0])
+	}
+	a.ResetLog()
+	if len(a.Entries(10)) != 0 {
+		t.Errorf("Expected the log to be empty")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.acl")
+	a := New(fakeCatalog{})
+	if err := a.Save(); err != ErrNoFile {
+		t.Errorf("Expected %v, got %v", ErrNoFile, err)
+	}
+	if err := os.WriteFile(path, []byte("# Users\nuser alice on >pw ~cache:* &* +@read -get\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.LoadFile(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	alice := a.Authenticate("alice", "pw")
+	if alice == nil {
+		t.Fatalf("Expected alice to be loaded")
+	}
+	if err := a.Save(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "user alice on #" + hash("pw") + " ~cache:* &* -@all +@read -get\n" +
+		"user default on nopass ~* &* +@all\n"
+	if string(saved) != expected {
+		t.Errorf("Expected %q, got %q", expected, saved)
+	}
+
+	// A bad line leaves every user as it was
+	os.WriteFile(path, []byte("user bob on\nuser carol +@nope\n"), 0644)
+	if err := a.Load(); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("Expected an error on line 2, got %v", err)
+	}
+	if a.Removed(alice) {
+		t.Errorf("Expected alice to be kept")
+	}
+
+	// Users left out of the file are deleted
+	os.WriteFile(path, []byte("user bob on nopass\n"), 0644)
+	if err := a.Load(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !a.Removed(alice) {
+		t.Errorf("Expected alice to be removed")
+	}
+	if got := a.Users(); !slices.Equal(got, []string{"bob", DEFAULT_USER}) {
+		t.Errorf("Expected bob and default, got %q", got)
+	}
+}
+
+func TestRequirePassOverFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.acl")
+	// No default line, loading the file alone would let anyone in
+	if err := os.WriteFile(path, []byte("user alice on >pw +@all ~*\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a := New(fakeCatalog{})
+	if err := a.LoadFile(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := a.SetRequirePass("secret"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if a.AutoLogin() != nil {
+		t.Errorf("Expected the default user to need a password")
+	}
+
+	// ACL LOAD keeps the password too
+	if err := a.Load(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if a.AutoLogin() != nil {
+		t.Errorf("Expected the default user to need a password after a reload")
+	}
+	if a.Authenticate(DEFAULT_USER, "secret") == nil {
+		t.Errorf("Expected the password to be accepted")
+	}
+
+	if err := a.SetRequirePass(""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if a.AutoLogin() == nil {
+		t.Errorf("Expected the default user to need no password")
+	}
+}
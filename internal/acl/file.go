@@ -0,0 +1,116 @@
+package acl
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Load users from the file, which ACL LOAD and ACL SAVE then use
+func (a *ACL) LoadFile(path string) error {
+	a.mu.Lock()
+	a.file = path
+	a.mu.Unlock()
+	return a.Load()
+}
+
+// Replace every user with those of the file
+// Users left out are deleted, nothing changes if any line is wrong
+func (a *ACL) Load() error {
+	a.mu.RLock()
+	path := a.file
+	a.mu.RUnlock()
+	if path == "" {
+		return ErrNoFile
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	users := make(map[string]*User)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] != "user" || len(fields) < 2 {
+			return fmt.Errorf("%s:%d: should start with user keyword", path, n)
+		}
+		name := fields[1]
+		if _, ok := users[name]; ok {
+			return fmt.Errorf("%s:%d: duplicate user '%s' found", path, n, name)
+		}
+		u := newUser(name)
+		for _, rule := range fields[2:] {
+			if err := u.apply(rule, a.catalog); err != nil {
+				return fmt.Errorf("%s:%d: %s. Error in user declaration '%s'", path, n, err, name)
+			}
+		}
+		users[name] = u
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	// Like Redis, a file without the default user keeps it as it is out of the box
+	if _, ok := users[DEFAULT_USER]; !ok {
+		users[DEFAULT_USER] = newDefaultUser()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// Otherwise a file without the default user would drop the password of requirepass
+	if a.requirePass != "" {
+		for _, rule := range requirePassRules(a.requirePass) {
+			if err := users[DEFAULT_USER].apply(rule, a.catalog); err != nil {
+				return err
+			}
+		}
+	}
+	for name, u := range a.users {
+		if loaded, ok := users[name]; ok {
+			// Keep the same user so its connections see the new rules
+			*u = *loaded
+			users[name] = u
+		} else {
+			u.removed = true
+		}
+	}
+	a.users = users
+	return nil
+}
+
+// Write every user to the file, replacing it in one go
+func (a *ACL) Save() error {
+	a.mu.RLock()
+	path := a.file
+	lines := a.list()
+	a.mu.RUnlock()
+	if path == "" {
+		return ErrNoFile
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "temp-*.acl")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := tmp.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,270 @@
+package acl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// A user and the rules that say what it may do once authenticated
+// Commands, keys and channels are denied unless a rule allows them
+type User struct {
+	name      string
+	enabled   bool
+	nopass    bool     // Any password is accepted
+	passwords []string // SHA-256 of each password, in hex
+	commands  []commandRule
+	keys      []string // Glob patterns of the keys it may touch
+	channels  []string // Glob patterns of the channels it may publish or subscribe to
+	removed   bool     // Deleted or replaced by ACL LOAD, its clients are disconnected
+}
+
+// +name or -name where name is a command, command|subcommand or @category
+// The last rule matching a command decides
+type commandRule struct {
+	allow bool
+	name  string
+}
+
+var (
+	errSyntax         = errors.New("Syntax error")
+	errUnknownCommand = errors.New("Unknown command or category name in ACL")
+	errNoSuchPassword = errors.New("The password you are trying to remove from the user does not exist")
+	errBadHash        = errors.New("The password hash must be exactly 64 characters and contain only lowercase hexadecimal characters")
+)
+
+func newUser(name string) *User {
+	return &User{name: name}
+}
+
+func (u *User) Name() string {
+	return u.name
+}
+
+func (u *User) clone() *User {
+	c := *u
+	c.passwords = slices.Clone(u.passwords)
+	c.commands = slices.Clone(u.commands)
+	c.keys = slices.Clone(u.keys)
+	c.channels = slices.Clone(u.channels)
+	return &c
+}
+
+// Apply a single rule of ACL SETUSER, e.g. on, >secret, ~cache:* or +@read
+func (u *User) apply(rule string, catalog Catalog) error {
+	switch lower := strings.ToLower(rule); lower {
+	case "on":
+		u.enabled = true
+	case "off":
+		u.enabled = false
+	case "nopass":
+		u.nopass = true
+		u.passwords = nil
+	case "resetpass":
+		u.nopass = false
+		u.passwords = nil
+	case "allkeys":
+		u.keys = []string{"*"}
+	case "resetkeys":
+		u.keys = nil
+	case "allchannels":
+		u.channels = []string{"*"}
+	case "resetchannels":
+		u.channels = nil
+	case "allcommands":
+		u.commands = []commandRule{{allow: true, name: "@all"}}
+	case "nocommands":
+		u.commands = []commandRule{{allow: false, name: "@all"}}
+	case "reset":
+		*u = User{name: u.name}
+	default:
+		return u.applyPrefixed(rule, catalog)
+	}
+	return nil
+}
+
+func (u *User) applyPrefixed(rule string, catalog Catalog) error {
+	if len(rule) < 2 {
+		return errSyntax
+	}
+	arg := rule[1:]
+	switch rule[0] {
+	case '>':
+		u.addPassword(hash(arg))
+	case '#':
+		if !validHash(arg) {
+			return errBadHash
+		}
+		u.addPassword(arg)
+	case '<':
+		return u.removePassword(hash(arg))
+	case '!':
+		if !validHash(arg) {
+			return errBadHash
+		}
+		return u.removePassword(arg)
+	case '~':
+		if !slices.Contains(u.keys, arg) {
+			u.keys = append(u.keys, arg)
+		}
+	case '&':
+		if !slices.Contains(u.channels, arg) {
+			u.channels = append(u.channels, arg)
+		}
+	case '+', '-':
+		return u.addCommandRule(rule[0] == '+', strings.ToLower(arg), catalog)
+	default:
+		return errSyntax
+	}
+	return nil
+}
+
+func (u *User) addPassword(h string) {
+	u.nopass = false
+	if !slices.Contains(u.passwords, h) {
+		u.passwords = append(u.passwords, h)
+	}
+}
+
+func (u *User) removePassword(h string) error {
+	i := slices.Index(u.passwords, h)
+	if i == -1 {
+		return errNoSuchPassword
+	}
+	u.passwords = slices.Delete(u.passwords, i, i+1)
+	return nil
+}
+
+func (u *User) addCommandRule(allow bool, name string, catalog Catalog) error {
+	if category, ok := strings.CutPrefix(name, "@"); ok {
+		if !catalog.IsCategory(category) {
+			return errUnknownCommand
+		}
+		// +@all and -@all override everything that came before
+		if category == "all" {
+			u.commands = nil
+		}
+	} else {
+		if _, sub, ok := strings.Cut(name, "|"); ok && (sub == "" || strings.Contains(sub, "|")) {
+			return errSyntax
+		}
+		if !catalog.IsCommand(name) {
+			return errUnknownCommand
+		}
+	}
+	// The rule replaces an older one about the same name
+	u.commands = slices.DeleteFunc(u.commands, func(r commandRule) bool { return r.name == name })
+	u.commands = append(u.commands, commandRule{allow: allow, name: name})
+	return nil
+}
+
+// Whether password is one of the user's
+func (u *User) checkPassword(password string) bool {
+	if !u.enabled {
+		return false
+	}
+	return u.nopass || slices.Contains(u.passwords, hash(password))
+}
+
+// Whether the rules let the user run the command, sub is empty for commands without subcommands
+func (u *User) canRun(command, sub string, categories []string) bool {
+	allowed := false
+	for _, r := range u.commands {
+		if r.matches(command, sub, categories) {
+			allowed = r.allow
+		}
+	}
+	return allowed
+}
+
+func (r commandRule) matches(command, sub string, categories []string) bool {
+	if category, ok := strings.CutPrefix(r.name, "@"); ok {
+		return category == "all" || slices.Contains(categories, category)
+	}
+	if name, s, ok := strings.Cut(r.name, "|"); ok {
+		return name == command && s == sub
+	}
+	return r.name == command
+}
+
+// Rules that rebuild the user from scratch, in the order ACL LIST shows them
+func (u *User) describe() string {
+	var rules []string
+	if u.enabled {
+		rules = append(rules, "on")
+	} else {
+		rules = append(rules, "off")
+	}
+	if u.nopass {
+		rules = append(rules, "nopass")
+	}
+	for _, h := range u.passwords {
+		rules = append(rules, "#"+h)
+	}
+	rules = append(rules, u.describeKeys(), u.describeChannels(), u.describeCommands())
+	return strings.Join(rules, " ")
+}
+
+func (u *User) describeKeys() string {
+	if len(u.keys) == 0 {
+		return "resetkeys"
+	}
+	return "~" + strings.Join(u.keys, " ~")
+}
+
+func (u *User) describeChannels() string {
+	if len(u.channels) == 0 {
+		return "resetchannels"
+	}
+	return "&" + strings.Join(u.channels, " &")
+}
+
+func (u *User) describeCommands() string {
+	// Everything is denied unless allowed, -@all makes that explicit
+	if len(u.commands) == 0 || u.commands[0].name != "@all" {
+		return strings.TrimSpace("-@all " + formatRules(u.commands))
+	}
+	return formatRules(u.commands)
+}
+
+func formatRules(rules []commandRule) string {
+	out := make([]string, len(rules))
+	for i, r := range rules {
+		if r.allow {
+			out[i] = "+" + r.name
+		} else {
+			out[i] = "-" + r.name
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+func (u *User) flags() []string {
+	flags := []string{"off"}
+	if u.enabled {
+		flags[0] = "on"
+	}
+	if u.nopass {
+		flags = append(flags, "nopass")
+	}
+	return flags
+}
+
+func hash(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func validHash(h string) bool {
+	if len(h) != 64 {
+		return false
+	}
+	for _, c := range h {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
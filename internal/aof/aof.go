@@ -0,0 +1,326 @@
+package aof
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/logger"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+)
+
+// How often the log is flushed from the OS page cache to the disk
+type FsyncPolicy int
+
+const (
+	FsyncAlways   FsyncPolicy = iota // After every write, safest and slowest
+	FsyncEverySec                    // Once per second from a background goroutine
+	FsyncNo                          // Leave it to the OS
+)
+
+// Max number of elements per command when rewriting a collection
+const REWRITE_BATCH = 64
+
+var (
+	ErrRewriteInProgress = errors.New("Background append only file rewriting already in progress")
+	ErrBadFormat         = errors.New("aof: bad file format")
+)
+
+func ParseFsyncPolicy(s string) (FsyncPolicy, error) {
+	switch s {
+	case "always":
+		return FsyncAlways, nil
+	case "everysec":
+		return FsyncEverySec, nil
+	case "no":
+		return FsyncNo, nil
+	default:
+		return 0, fmt.Errorf("invalid appendfsync policy '%s'", s)
+	}
+}
+
+func (p FsyncPolicy) String() string {
+	switch p {
+	case FsyncAlways:
+		return "always"
+	case FsyncEverySec:
+		return "everysec"
+	case FsyncNo:
+		return "no"
+	default:
+		return ""
+	}
+}
+
+// Execute the commands stored in r and report how many bytes were fully applied
+// An io.ErrUnexpectedEOF means the last record was cut in the middle
+type ReplayFunc func(r io.Reader) (int64, error)
+
+// Append-only log of every write command in RESP form
+type Log struct {
+	path   string
+	policy FsyncPolicy
+	logger *logger.Logger
+
+	mu   sync.Mutex // Guard the fields below
+	file *os.File
+	// Commands appended while a rewrite is running
+	// They are copied to the new file once the snapshot part is written
+	rewriteBuf []byte
+	rewriting  bool
+	rewritten  *sync.Cond // Broadcast whenever a rewrite finishes
+
+	done chan struct{}
+}
+
+// Replay the existing log through replay then open it for appending
+func Open(path string, policy FsyncPolicy, logger *logger.Logger, replay ReplayFunc) (*Log, error) {
+	if err := load(path, logger, replay); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log{
+		path:   path,
+		policy: policy,
+		logger: logger,
+		file:   file,
+		done:   make(chan struct{}),
+	}
+	l.rewritten = sync.NewCond(&l.mu)
+	if policy == FsyncEverySec {
+		go l.fsyncLoop()
+	}
+	return l, nil
+}
+
+func load(path string, logger *logger.Logger, replay ReplayFunc) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	n, err := replay(f)
+	switch {
+	case err == nil:
+		logger.Info("Append only file loaded", map[string]string{"path": path, "bytes": strconv.FormatInt(n, 10)})
+		return nil
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		// The server most likely crashed in the middle of a write
+		// Drop the incomplete record and keep everything before it
+		logger.Info("Truncating incomplete last record of the append only file", map[string]string{"path": path, "offset": strconv.FormatInt(n, 10)})
+		return os.Truncate(path, n)
+	default:
+		return fmt.Errorf("%w at offset %d: %s", ErrBadFormat, n, err)
+	}
+}
+
+// Add a command to the log and flush it according to the fsync policy
+func (l *Log) Append(args []string) error {
+	record := resp.AppendArray(nil, args)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rewriting {
+		l.rewriteBuf = append(l.rewriteBuf, record...)
+	}
+	if _, err := l.file.Write(record); err != nil {
+		return err
+	}
+	if l.policy == FsyncAlways {
+		return l.file.Sync()
+	}
+	return nil
+}
+
+// Compact the log by writing the given point-in-time view of the store as a fresh file
+// Commands appended in the meantime are carried over before the new file replaces the old one
+func (l *Log) BackgroundRewrite(snapshot map[string]store.Entry) error {
+	l.mu.Lock()
+	if l.rewriting {
+		l.mu.Unlock()
+		return ErrRewriteInProgress
+	}
+	l.rewriting = true
+	l.rewriteBuf = nil
+	l.mu.Unlock()
+
+	go func() {
+		if err := l.rewrite(snapshot); err != nil {
+			l.logger.Error(err, map[string]string{"path": l.path})
+			l.mu.Lock()
+			l.rewriting = false
+			l.rewriteBuf = nil
+			l.rewritten.Broadcast()
+			l.mu.Unlock()
+			return
+		}
+		l.logger.Info("Background AOF rewrite finished successfully", map[string]string{"path": l.path})
+	}()
+	return nil
+}
+
+// Block until the rewrite in progress, if any, is over
+// e.g. so a newer snapshot is not dropped with ErrRewriteInProgress
+func (l *Log) Wait() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.rewriting {
+		l.rewritten.Wait()
+	}
+}
+
+func (l *Log) InProgress() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rewriting
+}
+
+func (l *Log) rewrite(snapshot map[string]store.Entry) error {
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), "temp-rewriteaof-*.aof")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	var buf []byte
+	for key, e := range snapshot {
+		buf = encodeEntry(buf, key, e)
+		if len(buf) > 64<<10 {
+			if _, err := tmp.Write(buf); err != nil {
+				tmp.Close()
+				return err
+			}
+			buf = buf[:0]
+		}
+	}
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	// Block appends while we catch up with them and swap the files
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := tmp.Write(l.rewriteBuf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	l.file.Close()
+	l.file = tmp // Its offset is already at the end so appends keep going from there
+	l.rewriting = false
+	l.rewriteBuf = nil
+	l.rewritten.Broadcast()
+	return nil
+}
+
+// Flush everything to disk and stop the background fsync
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-l.done:
+		return nil
+	default:
+		close(l.done)
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	return l.file.Close()
+}
+
+func (l *Log) fsyncLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			// Sync outside the lock so writers are not stuck behind the disk
+			l.mu.Lock()
+			file := l.file
+			l.mu.Unlock()
+			// The file may have been swapped and closed by a rewrite in the meantime
+			if err := file.Sync(); err != nil && !errors.Is(err, os.ErrClosed) {
+				l.logger.Error(err, map[string]string{"path": l.path})
+			}
+		}
+	}
+}
+
+// The shortest command that rebuilds the entry
+func encodeEntry(buf []byte, key string, e store.Entry) []byte {
+	switch v := e.Value.(type) {
+	case string:
+		if e.ExpireAt.IsZero() {
+			return resp.AppendArray(buf, []string{"SET", key, v})
+		}
+		return resp.AppendArray(buf, []string{"SET", key, v, "PXAT", strconv.FormatInt(e.ExpireAt.UnixMilli(), 10)})
+	case *store.List:
+		// Split long lists so no single command gets huge
+		values := v.Values()
+		for len(values) > 0 {
+			n := min(len(values), REWRITE_BATCH)
+			buf = resp.AppendArray(buf, append([]string{"RPUSH", key}, values[:n]...))
+			values = values[n:]
+		}
+	case *store.Hash:
+		pairs := v.Pairs()
+		for len(pairs) > 0 {
+			n := min(len(pairs), 2*REWRITE_BATCH)
+			buf = resp.AppendArray(buf, append([]string{"HSET", key}, pairs[:n]...))
+			pairs = pairs[n:]
+		}
+	case *store.Set:
+		members := v.Members()
+		for len(members) > 0 {
+			n := min(len(members), REWRITE_BATCH)
+			buf = resp.AppendArray(buf, append([]string{"SADD", key}, members[:n]...))
+			members = members[n:]
+		}
+	case *store.ZSet:
+		entries := v.Entries()
+		for len(entries) > 0 {
+			n := min(len(entries), REWRITE_BATCH)
+			args := []string{"ZADD", key}
+			for _, e := range entries[:n] {
+				// Exact round trip of the double, unlike a shortened decimal form
+				args = append(args, strconv.FormatFloat(e.Score, 'g', -1, 64), e.Member)
+			}
+			buf = resp.AppendArray(buf, args)
+			entries = entries[n:]
+		}
+	}
+	// Only strings can carry their deadline in the command that creates them
+	if !e.ExpireAt.IsZero() {
+		buf = resp.AppendArray(buf, []string{"PEXPIREAT", key, strconv.FormatInt(e.ExpireAt.UnixMilli(), 10)})
+	}
+	return buf
+}
@@ -0,0 +1,119 @@
+package aof
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/logger"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+)
+
+func newTestLogger() *logger.Logger {
+	return logger.New(io.Discard, logger.LoggerConfig{MinLevel: logger.LevelOff})
+}
+
+func TestOpenTruncatesIncompleteRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	complete := resp.AppendArray(nil, []string{"SET", "a", "1"})
+	if err := os.WriteFile(path, append(bytes.Clone(complete), "*3\r\n$3\r\nSET\r\n$1"...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pretend only the first record could be parsed
+	replay := func(r io.Reader) (int64, error) {
+		return int64(len(complete)), io.ErrUnexpectedEOF
+	}
+	l, err := Open(path, FsyncNo, newTestLogger(), replay)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer l.Close()
+
+	if err := l.Append([]string{"DEL", "a"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got, _ := os.ReadFile(path)
+	expected := append(bytes.Clone(complete), resp.AppendArray(nil, []string{"DEL", "a"})...)
+	if !bytes.Equal(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestOpenRejectsCorruptedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	if err := os.WriteFile(path, []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	replay := func(r io.Reader) (int64, error) {
+		return 0, errors.New("unexpected byte")
+	}
+	if _, err := Open(path, FsyncNo, newTestLogger(), replay); !errors.Is(err, ErrBadFormat) {
+		t.Errorf("Expected %v, got %v", ErrBadFormat, err)
+	}
+}
+
+func TestBackgroundRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	l, err := Open(path, FsyncAlways, newTestLogger(), func(r io.Reader) (int64, error) { return 0, nil })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer l.Close()
+
+	for _, v := range []string{"1", "2", "3"} {
+		l.Append([]string{"SET", "a", v})
+	}
+
+	expireAt := time.UnixMilli(1893456000000)
+	snapshot := map[string]store.Entry{"a": {Value: "3", ExpireAt: expireAt}}
+	if err := l.BackgroundRewrite(snapshot); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for l.InProgress() {
+		time.Sleep(time.Millisecond)
+	}
+	l.Append([]string{"DEL", "a"})
+
+	got, _ := os.ReadFile(path)
+	expected := resp.AppendArray(nil, []string{"SET", "a", "3", "PXAT", "1893456000000"})
+	expected = resp.AppendArray(expected, []string{"DEL", "a"})
+	if !bytes.Equal(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+// A newer snapshot, e.g. from a full resync, must not be dropped because a rewrite is running
+func TestRewriteAfterRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	l, err := Open(path, FsyncAlways, newTestLogger(), func(r io.Reader) (int64, error) { return 0, nil })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer l.Close()
+
+	if err := l.BackgroundRewrite(map[string]store.Entry{"a": {Value: "1"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	l.Wait()
+	if err := l.BackgroundRewrite(map[string]store.Entry{"b": {Value: "2"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	l.Wait()
+	if l.InProgress() {
+		t.Fatal("Expected the rewrite to be over")
+	}
+
+	got, _ := os.ReadFile(path)
+	expected := resp.AppendArray(nil, []string{"SET", "b", "2"})
+	if !bytes.Equal(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
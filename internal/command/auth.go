@@ -0,0 +1,278 @@
+package command
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/acl"
+)
+
+const (
+	AUTH = "AUTH"
+	ACL  = "ACL"
+)
+
+const WRONGPASS = "WRONGPASS invalid username-password pair or user is disabled."
+
+// Entries ACL LOG shows without a count
+const ACL_LOG_DEFAULT_COUNT = 10
+
+// Commands whose first argument picks a subcommand that ACL rules can name, e.g. -config|set
+var containerCommands = map[string]bool{
+	ACL: true, COMMAND: true, CONFIG: true, PUBSUB: true,
+}
+
+// What ACL rules may refer to, taken from the command table
+type catalog struct{}
+
+var ACLCatalog acl.Catalog = catalog{}
+
+func (catalog) IsCommand(name string) bool {
+	name, sub, ok := strings.Cut(name, "|")
+	name = strings.ToUpper(name)
+	if _, exists := commands[name]; !exists {
+		return false
+	}
+	return !ok || (containerCommands[name] && sub != "")
+}
+
+func (catalog) IsCategory(name string) bool {
+	return name == "all" || slices.Contains(aclCategories(), name)
+}
+
+// Every category some command belongs to, sorted and without the @
+func aclCategories() []string {
+	var out []string
+	for _, spec := range commands {
+		for _, category := range spec.categories() {
+			category = strings.TrimPrefix(category, "@")
+			if !slices.Contains(out, category) {
+				out = append(out, category)
+			}
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Whether the client's user may run the command, replying with why not otherwise
+// Commands without a client come from the AOF or our leader and were checked already
+func (cmd *Command) authorize(srv *Server, spec *commandSpec) bool {
+	c := cmd.Client
+	if c == nil {
+		return true
+	}
+	if c.user == nil {
+		if spec.has(FLAG_NO_AUTH) {
+			return true
+		}
+		cmd.writeError("NOAUTH Authentication required.")
+		return false
+	}
+
+	req := acl.Request{
+		Command: strings.ToLower(spec.name),
+		Keys:    spec.keysOf(cmd.Args),
+	}
+	if containerCommands[spec.name] && len(cmd.Args) > 1 {
+		req.Subcommand = strings.ToLower(cmd.Args[1])
+	}
+	for _, category := range spec.categories() {
+		req.Categories = append(req.Categories, strings.TrimPrefix(category, "@"))
+	}
+	switch spec.name {
+	case SUBSCRIBE, PSUBSCRIBE:
+		req.Channels = cmd.Args[1:]
+		req.Patterns = spec.name == PSUBSCRIBE
+	case PUBLISH:
+		req.Channels = cmd.Args[1:2]
+	}
+
+	denial := srv.ACL.Check(c.user, req)
+	if denial == nil {
+		return true
+	}
+	context := "toplevel"
+	if cmd.inTransaction || c.inMulti() {
+		context = "multi"
+	}
+	srv.ACL.Log(*denial, context, c.user.Name(), c.info())
+	switch denial.Reason {
+	case acl.REASON_KEY:
+		cmd.writeError("NOPERM No permissions to access a key")
+	case acl.REASON_CHANNEL:
+		cmd.writeError("NOPERM No permissions to access a channel")
+	default:
+		cmd.writeError("NOPERM User " + c.user.Name() + " has no permissions to run the '" + denial.Object + "' command")
+	}
+	return false
+}
+
+// AUTH [username] password
+func (cmd *Command) auth(srv *Server) bool {
+	if len(cmd.Args) > 3 {
+		cmd.writeError(SYNTAX_ERROR)
+		return true
+	}
+	username, password := acl.DEFAULT_USER, cmd.Args[1]
+	if len(cmd.Args) == 3 {
+		username, password = cmd.Args[1], cmd.Args[2]
+	} else if info, _ := srv.ACL.GetUser(acl.DEFAULT_USER); slices.Contains(info.Flags, "nopass") {
+		cmd.writeError("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+		return true
+	}
+	if cmd.login(srv, username, password) {
+		cmd.writeOK()
+	}
+	return true
+}
+
+// Switch the client to the user, replying with WRONGPASS if the password is not one of its own
+func (cmd *Command) login(srv *Server, username, password string) bool {
+	u := srv.ACL.Authenticate(username, password)
+	if u == nil {
+		info := ""
+		if cmd.Client != nil {
+			info = cmd.Client.info()
+		}
+		srv.ACL.Log(acl.Denial{Reason: acl.REASON_AUTH, Object: strings.ToLower(AUTH)}, "toplevel", username, info)
+		cmd.writeError(WRONGPASS)
+		return false
+	}
+	if cmd.Client != nil {
+		cmd.Client.user = u
+	}
+	return true
+}
+
+// ACL SETUSER | GETUSER | DELUSER | LIST | USERS | WHOAMI | CAT | LOG | LOAD | SAVE
+func (cmd *Command) acl(srv *Server) bool {
+	a := srv.ACL
+	switch sub := strings.ToUpper(cmd.Args[1]); {
+	case sub == "SETUSER" && len(cmd.Args) > 2:
+		if err := a.SetUser(cmd.Args[2], cmd.Args[3:]); err != nil {
+			cmd.writeError("ERR " + err.Error())
+			return true
+		}
+		cmd.writeOK()
+	case sub == "GETUSER" && len(cmd.Args) == 3:
+		info, ok := a.GetUser(cmd.Args[2])
+		if !ok {
+			cmd.writeNilArray()
+			return true
+		}
+		cmd.writeMapLen(6)
+		cmd.writeBulk("flags")
+		cmd.writeSet(info.Flags)
+		cmd.writeBulk("passwords")
+		cmd.writeArray(info.Passwords)
+		cmd.writeBulk("commands")
+		cmd.writeBulk(info.Commands)
+		cmd.writeBulk("keys")
+		cmd.writeBulk(info.Keys)
+		cmd.writeBulk("channels")
+		cmd.writeBulk(info.Channels)
+		cmd.writeBulk("selectors")
+		cmd.writeArray(nil)
+	case sub == "DELUSER" && len(cmd.Args) > 2:
+		deleted, err := a.DelUser(cmd.Args[2:]...)
+		if err != nil {
+			cmd.writeError("ERR " + err.Error())
+			return true
+		}
+		cmd.writeInt(int64(deleted))
+	case sub == "LIST" && len(cmd.Args) == 2:
+		cmd.writeArray(a.List())
+	case sub == "USERS" && len(cmd.Args) == 2:
+		cmd.writeArray(a.Users())
+	case sub == "WHOAMI" && len(cmd.Args) == 2:
+		if cmd.Client == nil || cmd.Client.user == nil {
+			cmd.writeBulk(acl.DEFAULT_USER)
+			return true
+		}
+		cmd.writeBulk(cmd.Client.user.Name())
+	case sub == "CAT" && len(cmd.Args) == 2:
+		cmd.writeArray(aclCategories())
+	case sub == "CAT" && len(cmd.Args) == 3:
+		category := strings.ToLower(cmd.Args[2])
+		if !ACLCatalog.IsCategory(category) {
+			cmd.writeError("ERR Unknown category '" + cmd.Args[2] + "'")
+			return true
+		}
+		var names []string
+		for _, name := range commandNames() {
+			if category == "all" || slices.Contains(commands[name].categories(), "@"+category) {
+				names = append(names, strings.ToLower(name))
+			}
+		}
+		cmd.writeArray(names)
+	case sub == "LOG" && len(cmd.Args) <= 3:
+		cmd.aclLog(a)
+	case (sub == "LOAD" || sub == "SAVE") && len(cmd.Args) == 2:
+		var err error
+		if sub == "LOAD" {
+			err = a.Load()
+		} else {
+			err = a.Save()
+		}
+		if err != nil {
+			if !errors.Is(err, acl.ErrNoFile) {
+				srv.Logger.Error(err, map[string]string{"command": "ACL " + sub})
+			}
+			cmd.writeError("ERR " + err.Error())
+			return true
+		}
+		cmd.writeOK()
+	default:
+		cmd.writeError("ERR unknown subcommand or wrong number of arguments for '" + cmd.Args[1] + "'. Try ACL HELP.")
+	}
+	return true
+}
+
+// ACL LOG [count | RESET]
+func (cmd *Command) aclLog(a *acl.ACL) {
+	count := ACL_LOG_DEFAULT_COUNT
+	if len(cmd.Args) == 3 {
+		if strings.EqualFold(cmd.Args[2], "RESET") {
+			a.ResetLog()
+			cmd.writeOK()
+			return
+		}
+		n, err := strconv.Atoi(cmd.Args[2])
+		if err != nil || n < 0 {
+			cmd.writeError(NOT_INTEGER)
+			return
+		}
+		count = n
+	}
+
+	now := time.Now()
+	entries := a.Entries(count)
+	cmd.writeArrayLen(len(entries))
+	for _, e := range entries {
+		cmd.writeMapLen(10)
+		cmd.writeBulk("count")
+		cmd.writeInt(int64(e.Count))
+		cmd.writeBulk("reason")
+		cmd.writeBulk(e.Reason)
+		cmd.writeBulk("context")
+		cmd.writeBulk(e.Context)
+		cmd.writeBulk("object")
+		cmd.writeBulk(e.Object)
+		cmd.writeBulk("username")
+		cmd.writeBulk(e.Username)
+		cmd.writeBulk("age-seconds")
+		cmd.writeDouble(now.Sub(e.Created).Seconds())
+		cmd.writeBulk("client-info")
+		cmd.writeBulk(e.ClientInfo)
+		cmd.writeBulk("entry-id")
+		cmd.writeInt(e.EntryID)
+		cmd.writeBulk("timestamp-created")
+		cmd.writeInt(e.Created.UnixMilli())
+		cmd.writeBulk("timestamp-last-updated")
+		cmd.writeInt(e.Updated.UnixMilli())
+	}
+}
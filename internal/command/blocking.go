@@ -0,0 +1,265 @@
+package command
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+)
+
+const (
+	BLPOP  = "BLPOP"
+	BRPOP  = "BRPOP"
+	BLMOVE = "BLMOVE"
+)
+
+// A client parked by BLPOP/BRPOP/BLMOVE until one of its keys holds a list
+type waiter struct {
+	cmd     *Command
+	keys    []string
+	target  string        // Destination of BLMOVE, which may wake up clients blocked on it
+	timeout time.Duration // Zero blocks forever
+
+	serve  func(db *store.InMemoryStore, key string) // Write the reply for an element available at key
+	expire func()                                    // Write the reply for a timeout
+
+	done chan struct{} // Closed once the reply is written, on the session goroutine's side
+}
+
+// BLPOP/BRPOP key [key ...] timeout
+func (cmd *Command) bpop(srv *Server, left bool) bool {
+	timeout, ok := cmd.parseTimeout(cmd.Args[len(cmd.Args)-1])
+	if !ok {
+		return true
+	}
+
+	db := srv.Store
+	keys := cmd.Args[1 : len(cmd.Args)-1]
+	// The first key holding a list wins
+	for _, key := range keys {
+		l, ok := cmd.lookupList(db, key)
+		if !ok {
+			return true
+		}
+		if l != nil {
+			cmd.popFrom(db, key, left)
+			return true
+		}
+	}
+
+	cmd.block(srv, &waiter{
+		keys:    slices.Clone(keys),
+		timeout: timeout,
+		serve: func(db *store.InMemoryStore, key string) {
+			cmd.popFrom(db, key, left)
+		},
+		expire: cmd.writeNilArray,
+	})
+	return true
+}
+
+// BLMOVE source destination LEFT|RIGHT LEFT|RIGHT timeout
+func (cmd *Command) blmove(srv *Server) bool {
+	from, ok1 := parseEnd(cmd.Args[3])
+	to, ok2 := parseEnd(cmd.Args[4])
+	if !ok1 || !ok2 {
+		cmd.writeError(SYNTAX_ERROR)
+		return true
+	}
+	timeout, ok := cmd.parseTimeout(cmd.Args[5])
+	if !ok {
+		return true
+	}
+
+	db := srv.Store
+	source, destination := cmd.Args[1], cmd.Args[2]
+	l, ok := cmd.lookupList(db, source)
+	if !ok {
+		return true
+	}
+	if l != nil {
+		cmd.move(db, source, destination, from, to)
+		return true
+	}
+
+	cmd.block(srv, &waiter{
+		keys:    []string{source},
+		target:  destination,
+		timeout: timeout,
+		serve: func(db *store.InMemoryStore, key string) {
+			cmd.move(db, key, destination, from, to)
+		},
+		expire: cmd.writeNil,
+	})
+	return true
+}
+
+// Pop one element for a blocking pop and reply with the key it came from
+// Followers and the AOF only see a plain pop, they never block
+func (cmd *Command) popFrom(db *store.InMemoryStore, key string, left bool) {
+	l, _ := cmd.lookupList(db, key)
+	v := popN(l, 1, left)[0]
+	cmd.listChanged(db, key, l)
+
+	name := RPOP
+	if left {
+		name = LPOP
+	}
+	cmd.rewrite = []string{name, key}
+	cmd.writeArray([]string{key, v})
+}
+
+// Park the client until another command serves it or the timeout fires
+// Commands without a client, e.g. replayed ones, and transactions can't wait for anything
+func (cmd *Command) block(srv *Server, w *waiter) {
+	if cmd.Client == nil || cmd.inTransaction {
+		w.expire()
+		return
+	}
+	w.cmd = cmd
+	w.done = make(chan struct{})
+	if srv.waiters == nil {
+		srv.waiters = make(map[string][]*waiter)
+	}
+	for _, key := range w.keys {
+		srv.waiters[key] = append(srv.waiters[key], w)
+	}
+	cmd.blocked = w
+}
+
+// Wait for the blocked command to be served, to time out or for the client to go away
+// Must be called without srv.mu held
+func (srv *Server) wait(w *waiter) {
+	var expired <-chan time.Time
+	if w.timeout > 0 {
+		timer := time.NewTimer(w.timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case <-w.done:
+		return
+	case <-expired:
+	case <-w.cmd.Client.Disconnected():
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	select {
+	case <-w.done:
+		return // Served while we were giving up
+	default:
+	}
+	srv.unblock(w)
+	w.expire()
+}
+
+// Whether the client waits on some key, e.g. with BLPOP
+// Must be called with srv.mu held
+func (srv *Server) blocked(c *Client) bool {
+	for _, waiters := range srv.waiters {
+		for _, w := range waiters {
+			if w.cmd.Client == c {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Hand elements pushed to keys over to the clients blocked on them, oldest first
+// Must be called with srv.mu held, right after the command that pushed them
+func (srv *Server) serveBlocked(keys []string) {
+	if len(srv.waiters) == 0 {
+		return
+	}
+	db := srv.Store
+	ready := slices.Clone(keys)
+	for len(ready) > 0 {
+		key := ready[0]
+		ready = ready[1:]
+
+		for len(srv.waiters[key]) > 0 {
+			val, ok := db.Get(key)
+			if l, isList := val.(*store.List); !ok || !isList || l.Len() == 0 {
+				break
+			}
+
+			w := srv.waiters[key][0]
+			srv.unblock(w)
+			dirty := db.Dirty()
+			w.serve(db, key)
+			srv.propagateExpired(w.cmd.Client != nil)
+			if db.Dirty() != dirty {
+				srv.propagate(w.cmd.rewrite, w.cmd.Client)
+			}
+			close(w.done)
+			// BLMOVE pushes to its destination, which may have clients waiting on it too
+			if w.target != "" {
+				ready = append(ready, w.target)
+			}
+		}
+	}
+}
+
+// Wake up every blocked client with an error, e.g. once we follow a leader and become read-only
+// Must be called with srv.mu held
+func (srv *Server) unblockAll(msg string) {
+	// A client blocked on several keys sits in several queues
+	blocked := make(map[*waiter]struct{})
+	for _, queue := range srv.waiters {
+		for _, w := range queue {
+			blocked[w] = struct{}{}
+		}
+	}
+	for w := range blocked {
+		srv.unblock(w)
+		w.cmd.writeError(msg)
+		close(w.done)
+	}
+}
+
+// Remove the waiter from the queue of every key it was blocked on
+func (srv *Server) unblock(w *waiter) {
+	for _, key := range w.keys {
+		queue := slices.DeleteFunc(srv.waiters[key], func(other *waiter) bool { return other == w })
+		if len(queue) == 0 {
+			delete(srv.waiters, key)
+		} else {
+			srv.waiters[key] = queue
+		}
+	}
+}
+
+// Timeouts are in seconds with decimals, 0 meaning forever
+func (cmd *Command) parseTimeout(arg string) (time.Duration, bool) {
+	secs, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(secs) || math.IsInf(secs, 0) {
+		cmd.writeError("ERR timeout is not a float or out of range")
+		return 0, false
+	}
+	if secs < 0 {
+		cmd.writeError("ERR timeout is negative")
+		return 0, false
+	}
+	if secs > float64(math.MaxInt64/time.Second) {
+		cmd.writeError("ERR timeout is out of range")
+		return 0, false
+	}
+	return time.Duration(secs * float64(time.Second)), true
+}
+
+// LEFT or RIGHT, true for LEFT
+func parseEnd(arg string) (bool, bool) {
+	switch strings.ToUpper(arg) {
+	case "LEFT":
+		return true, true
+	case "RIGHT":
+		return false, true
+	}
+	return false, false
+}
@@ -0,0 +1,201 @@
+package command
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/acl"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/logger"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/replication"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+)
+
+func newTestServer() *Server {
+	log := logger.New(io.Discard, logger.LoggerConfig{MinLevel: logger.LevelOff})
+	return &Server{
+		Logger:      log,
+		Store:       store.NewInMemoryStore(),
+		Replication: replication.New("0", log, nil),
+		ACL:         acl.New(ACLCatalog),
+	}
+}
+
+// A client logged in as the default user
+func connect(srv *Server) *Client {
+	c := NewClient(nil)
+	srv.Connect(c)
+	return c
+}
+
+// Run a command that does not come from a client and return its reply
+func runCommand(srv *Server, args ...string) string {
+	conn := &replyBuffer{}
+	Command{Args: args, Conn: conn}.Handle(srv)
+	return string(conn.buf)
+}
+
+// Handle the command for the client in the background
+// The reply is sent once the command is done, blocking or not
+func handle(srv *Server, c *Client, args ...string) <-chan string {
+	reply := make(chan string, 1)
+	go func() {
+		conn := &replyBuffer{}
+		Command{Args: args, Conn: conn, Client: c}.Handle(srv)
+		reply <- string(conn.buf)
+	}()
+	return reply
+}
+
+// Wait for the reply of a command that is not expected to block
+func handleNow(t *testing.T, srv *Server, c *Client, args ...string) string {
+	t.Helper()
+	select {
+	case got := <-handle(srv, c, args...):
+		return got
+	case <-time.After(time.Second):
+		t.Fatalf("Expected %s to reply right away", args[0])
+		return ""
+	}
+}
+
+func isBlocked(srv *Server, c *Client) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for _, queue := range srv.waiters {
+		for _, w := range queue {
+			if w.cmd.Client == c {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func waitBlocked(t *testing.T, srv *Server, c *Client) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if isBlocked(srv, c) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Expected the client to be blocked")
+}
+
+func expectReply(t *testing.T, reply <-chan string, expected string) {
+	t.Helper()
+	select {
+	case got := <-reply:
+		if got != expected {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected %q, got no reply", expected)
+	}
+}
+
+func TestBlockedClientsServedInOrder(t *testing.T) {
+	srv := newTestServer()
+	first, second, pusher := connect(srv), connect(srv), connect(srv)
+
+	firstReply := handle(srv, first, "BLPOP", "a", "0")
+	waitBlocked(t, srv, first)
+	secondReply := handle(srv, second, "BRPOP", "b", "a", "0")
+	waitBlocked(t, srv, second)
+
+	if got := handleNow(t, srv, pusher, "RPUSH", "a", "x"); got != ":1\r\n" {
+		t.Fatalf("Expected :1, got %q", got)
+	}
+	expectReply(t, firstReply, "*2\r\n$1\r\na\r\n$1\r\nx\r\n")
+	if !isBlocked(srv, second) {
+		t.Fatalf("Expected the second client to still be blocked")
+	}
+
+	handleNow(t, srv, pusher, "LPUSH", "a", "y")
+	expectReply(t, secondReply, "*2\r\n$1\r\na\r\n$1\r\ny\r\n")
+
+	// Both were handed the element, nothing is left behind
+	if got := handleNow(t, srv, pusher, "TYPE", "a"); got != "+none\r\n" {
+		t.Errorf("Expected none, got %q", got)
+	}
+	if len(srv.waiters) != 0 {
+		t.Errorf("Expected no waiters, got %v", srv.waiters)
+	}
+}
+
+func TestBlockTimeout(t *testing.T) {
+	srv := newTestServer()
+	expectReply(t, handle(srv, connect(srv), "BLPOP", "a", "b", "0.01"), "*-1\r\n")
+	expectReply(t, handle(srv, connect(srv), "BLMOVE", "a", "b", "LEFT", "RIGHT", "0.01"), "$-1\r\n")
+	if len(srv.waiters) != 0 {
+		t.Errorf("Expected no waiters, got %v", srv.waiters)
+	}
+
+	// Replayed commands have no client to wait for, they time out right away
+	if got := runCommand(srv, "BRPOP", "a", "0"); got != "*-1\r\n" {
+		t.Errorf("Expected a nil array, got %q", got)
+	}
+}
+
+func TestBlockedClientDisconnects(t *testing.T) {
+	srv := newTestServer()
+	gone, pusher := connect(srv), connect(srv)
+
+	reply := handle(srv, gone, "BLPOP", "a", "b", "0")
+	waitBlocked(t, srv, gone)
+	gone.MarkDisconnected()
+	srv.Disconnect(gone)
+	select {
+	case <-reply:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the command to give up")
+	}
+	if isBlocked(srv, gone) || len(srv.waiters) != 0 {
+		t.Errorf("Expected no waiters, got %v", srv.waiters)
+	}
+
+	// Nobody takes the element anymore
+	handleNow(t, srv, pusher, "RPUSH", "a", "x")
+	if got := handleNow(t, srv, pusher, "LLEN", "a"); got != ":1\r\n" {
+		t.Errorf("Expected :1, got %q", got)
+	}
+}
+
+// BLMOVE pushes to its destination, which serves the clients blocked there in turn
+func TestBlockingMoveChain(t *testing.T) {
+	srv := newTestServer()
+	mover, popper, pusher := connect(srv), connect(srv), connect(srv)
+
+	moved := handle(srv, mover, "BLMOVE", "a", "b", "LEFT", "RIGHT", "0")
+	waitBlocked(t, srv, mover)
+	popped := handle(srv, popper, "BLPOP", "b", "0")
+	waitBlocked(t, srv, popper)
+
+	handleNow(t, srv, pusher, "RPUSH", "a", "x")
+	expectReply(t, moved, "$1\r\nx\r\n")
+	expectReply(t, popped, "*2\r\n$1\r\nb\r\n$1\r\nx\r\n")
+	for _, key := range []string{"a", "b"} {
+		if got := handleNow(t, srv, pusher, "TYPE", key); got != "+none\r\n" {
+			t.Errorf("Expected %s to be gone, got %q", key, got)
+		}
+	}
+}
+
+// Keys pushed to inside a transaction serve their blocked clients once it is over
+func TestBlockedClientServedAfterTransaction(t *testing.T) {
+	srv := newTestServer()
+	popper, pusher := connect(srv), connect(srv)
+
+	popped := handle(srv, popper, "BLPOP", "b", "0")
+	waitBlocked(t, srv, popper)
+
+	handleNow(t, srv, pusher, "MULTI")
+	handleNow(t, srv, pusher, "SET", "a", "b")
+	handleNow(t, srv, pusher, "RPUSH", "b", "x")
+	if got := handleNow(t, srv, pusher, "EXEC"); got != "*2\r\n+OK\r\n:1\r\n" {
+		t.Fatalf("Expected OK and :1, got %q", got)
+	}
+	expectReply(t, popped, "*2\r\n$1\r\nb\r\n$1\r\nx\r\n")
+}
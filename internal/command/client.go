@@ -0,0 +1,143 @@
+package command
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/acl"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/pubsub"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/replication"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+)
+
+// State of a single client connection that outlives one command
+// Commands replayed from the AOF or streamed by our leader have no client
+type Client struct {
+	Conn net.Conn
+	id   uint64
+	name string    // Set with HELLO SETNAME
+	user *acl.User // Nil until the client authenticates
+	// RESP version the client speaks, switched with HELLO
+	// Only touched from the client's own session so it needs no lock
+	proto int
+
+	listeningPort string               // Announced by REPLCONF before a follower asks to SYNC
+	replica       *replication.Replica // Set once the client turned into one of our followers
+	subscriber    *pubsub.Subscriber   // Set on the first (P)SUBSCRIBE
+	multi         []Command            // Commands queued since MULTI, nil outside of a transaction
+	multiFailed   bool                 // A command could not be queued, EXEC will refuse to run
+	watched       map[string]uint64    // Version of each watched key when WATCH was called
+
+	lastInteraction atomic.Int64 // Unix time in nanoseconds the client last sent a command, for the idle timeout
+
+	gone      chan struct{} // Closed once the connection can't be read from anymore
+	closeOnce sync.Once
+}
+
+// Every client gets the next ID, like in Redis they are never reused
+var lastClientID atomic.Uint64
+
+func NewClient(conn net.Conn) *Client {
+	c := &Client{
+		Conn:  conn,
+		id:    lastClientID.Add(1),
+		proto: resp.RESP2,
+		gone:  make(chan struct{}),
+	}
+	c.touch()
+	return c
+}
+
+func (c *Client) touch() {
+	c.lastInteraction.Store(time.Now().UnixNano())
+}
+
+func (c *Client) idle() time.Duration {
+	return time.Since(time.Unix(0, c.lastInteraction.Load()))
+}
+
+func (c *Client) Protocol() int {
+	return c.proto
+}
+
+// Tell whoever waits on behalf of the client, e.g. a blocked BLPOP, that it went away
+func (c *Client) MarkDisconnected() {
+	c.closeOnce.Do(func() { close(c.gone) })
+}
+
+func (c *Client) Disconnected() <-chan struct{} {
+	return c.gone
+}
+
+// Published messages to push to the client, nil until it subscribes to something
+func (c *Client) Messages() <-chan pubsub.Message {
+	if c.subscriber == nil {
+		return nil
+	}
+	return c.subscriber.Messages()
+}
+
+// Log the client in as the default user, unless that one has a password
+func (srv *Server) Connect(c *Client) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.clients == nil {
+		srv.clients = make(map[*Client]struct{})
+	}
+	srv.clients[c] = struct{}{}
+	srv.stats.connections++
+	c.user = srv.ACL.AutoLogin()
+}
+
+// Release everything the client held on the server side
+func (srv *Server) Disconnect(c *Client) {
+	if c.replica != nil {
+		srv.Replication.RemoveReplica(c.replica)
+	}
+	if c.subscriber != nil {
+		srv.PubSub.Remove(c.subscriber)
+	}
+	srv.unwatchAll(c)
+	srv.mu.Lock()
+	delete(srv.clients, c)
+	srv.mu.Unlock()
+}
+
+// How often idle clients are looked for
+const CLIENTS_CRON_INTERVAL = time.Second
+
+// Hang up on clients idle for longer than the timeout setting
+// Subscribers, followers and blocked clients wait on purpose so they are left alone, like in Redis
+// Runs until the process exits
+func (srv *Server) ClientsCron() {
+	ticker := time.NewTicker(CLIENTS_CRON_INTERVAL)
+	defer ticker.Stop()
+	for range ticker.C {
+		timeout := time.Duration(srv.Config.GetInt("timeout")) * time.Second
+		if timeout <= 0 {
+			continue
+		}
+		srv.mu.Lock()
+		for c := range srv.clients {
+			if c.idle() <= timeout || c.subscriber != nil || c.replica != nil || srv.blocked(c) {
+				continue
+			}
+			srv.Logger.Info("Closing idle client", map[string]string{"client": c.info()})
+			// The session notices the connection is gone and cleans up after the client
+			c.Conn.Close()
+		}
+		srv.mu.Unlock()
+	}
+}
+
+// A line about the client for ACL LOG, like a shorter CLIENT INFO
+func (c *Client) info() string {
+	user := ""
+	if c.user != nil {
+		user = c.user.Name()
+	}
+	return fmt.Sprintf("id=%d addr=%s laddr=%s name=%s resp=%d user=%s", c.id, c.Conn.RemoteAddr(), c.Conn.LocalAddr(), c.name, c.proto, user)
+}
@@ -1,7 +1,6 @@
 package command
 
 import (
-	"fmt"
 	"net"
 	"strconv"
 	"strings"
@@ -13,175 +12,333 @@ import (
 
 // TODO: Replace with a struct that receive values in handlers
 type Command struct {
-	Args []string
-	Conn net.Conn
+	Args   []string
+	Conn   net.Conn
+	Client *Client // Nil when the command does not come from a user connection
+	// Replaces Args when the command is propagated
+	// e.g. a relative TTL has to become absolute before it is replayed later
+	rewrite []string
+	blocked *waiter // Set when the command has to wait before it can reply
+
+	inTransaction bool // Run by EXEC, which must never wait
+	failed        bool // An error was replied, for INFO commandstats
 }
 
 const (
-	GET  = "GET"
-	SET  = "SET"
-	DEL  = "DEL"
-	QUIT = "QUIT"
-	PING = "PING"
-	ECHO = "ECHO"
-	NX   = "NX"
-	XX   = "PX"
-	EX   = "EX"
-	PX   = "PX"
+	GET          = "GET"
+	SET          = "SET"
+	DEL          = "DEL"
+	QUIT         = "QUIT"
+	PING         = "PING"
+	ECHO         = "ECHO"
+	SAVE         = "SAVE"
+	BGSAVE       = "BGSAVE"
+	LASTSAVE     = "LASTSAVE"
+	BGREWRITEAOF = "BGREWRITEAOF"
+	INFO         = "INFO"
+	REPLICAOF    = "REPLICAOF"
+	SLAVEOF      = "SLAVEOF"
+	REPLCONF     = "REPLCONF"
+	SYNC         = "SYNC"
+	PSYNC        = "PSYNC"
+	TYPE         = "TYPE"
+	NX           = "NX"
+	XX           = "XX"
+	EX           = "EX"
+	PX           = "PX"
+	EXAT         = "EXAT"
+	PXAT         = "PXAT"
+	KEEPTTL      = "KEEPTTL"
 )
 
-func (cmd Command) Handle(logger *logger.Logger, store *store.InMemoryStore) bool {
-	switch strings.ToUpper(cmd.Args[0]) {
-	case GET:
-		return cmd.get(logger, store)
-	case SET:
-		return cmd.set(logger, store)
-	case DEL:
-		return cmd.del(store)
-	case QUIT:
-		return cmd.quit(logger)
-	case PING:
-		return cmd.ping(logger)
-	case ECHO:
-		return cmd.echo(logger)
-	default:
-		logger.Info("Command not supported", map[string]string{"command": cmd.Args[0]})
-		cmd.Conn.Write([]uint8("-ERR unknown command '" + cmd.Args[0] + "'\r\n"))
+func (cmd Command) Handle(srv *Server) bool {
+	if len(cmd.Args) == 0 {
+		return true
 	}
-	return true
+
+	// Hold the reply until the command is in the AOF
+	// so appendfsync always never acknowledges a write that is not on disk yet
+	conn := cmd.Conn
+	reply := &replyBuffer{Conn: conn}
+	cmd.Conn = reply
+	if cmd.Client != nil {
+		cmd.Client.touch()
+	}
+	keepOpen := cmd.exec(srv)
+	if cmd.blocked != nil {
+		srv.wait(cmd.blocked)
+		// The idle time starts once the client is served
+		cmd.Client.touch()
+	}
+	// Followers only ever receive the command stream, a reply would corrupt it
+	if cmd.Client == nil || cmd.Client.replica == nil {
+		conn.Write(reply.buf)
+	}
+	return keepOpen
 }
 
-func (cmd *Command) quit(logger *logger.Logger) bool {
-	if len(cmd.Args) != 1 {
-		cmd.Conn.Write([]uint8("-ERR wrong number of arguments for '" + cmd.Args[0] + "' command\r\n"))
+// Run the command while no other session can touch the store
+// so the AOF sees writes in the exact order they were applied
+func (cmd *Command) exec(srv *Server) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	// The client's user was deleted, like Redis we hang up on it
+	if c := cmd.Client; c != nil && c.user != nil && srv.ACL.Removed(c.user) {
+		return false
+	}
+	name := strings.ToUpper(cmd.Args[0])
+	if srv.subscribed(cmd.Client) && cmd.subscriberMode(name) {
+		return true
+	}
+	// Between MULTI and EXEC commands are only queued
+	if cmd.Client.inMulti() && !transactionCommands[name] {
+		// Like Redis, a command that can't even be queued dooms the whole transaction
+		spec, errMsg := lookupCommand(cmd.Args)
+		if errMsg != "" {
+			cmd.Client.multiFailed = true
+			cmd.writeError(errMsg)
+			return true
+		}
+		if !cmd.authorize(srv, spec) {
+			srv.stats.rejected(spec)
+			cmd.Client.multiFailed = true
+			return true
+		}
+		cmd.Client.multi = append(cmd.Client.multi, *cmd)
+		cmd.writeSimple("QUEUED")
+		return true
+	}
+	return cmd.run(srv)
+}
+
+// Must be called with srv.mu held
+func (cmd *Command) run(srv *Server) bool {
+	spec, errMsg := lookupCommand(cmd.Args)
+	if errMsg != "" {
+		srv.Logger.Info("Command rejected", map[string]string{"command": cmd.Args[0]})
+		// A known command with the wrong number of arguments still shows in INFO commandstats
+		if known, ok := commands[strings.ToUpper(cmd.Args[0])]; ok {
+			srv.stats.rejected(known)
+		}
+		cmd.writeError(errMsg)
+		return true
+	}
+	if !cmd.authorize(srv, spec) {
+		srv.stats.rejected(spec)
 		return true
 	}
+	if spec.has(FLAG_WRITE) && cmd.Client != nil && srv.Replication.IsReplica() {
+		srv.stats.rejected(spec)
+		cmd.writeError("READONLY You can't write against a read only replica.")
+		return true
+	}
+	// Our leader decides what to evict and sends us the DELs
+	if spec.has(FLAG_DENYOOM) && cmd.Client != nil && !srv.Replication.IsReplica() && !srv.evict(cmd.Client) {
+		srv.stats.rejected(spec)
+		cmd.writeError(OOM)
+		return true
+	}
+	if spec.has(FLAG_READONLY) {
+		srv.lookedUp(spec.keysOf(cmd.Args))
+	}
+
+	dirty := srv.Store.Dirty()
+	start := time.Now()
+	keepOpen := spec.handler(cmd, srv)
+	srv.stats.called(spec, time.Since(start), cmd.failed)
+	// Keys found expired along the way go first, the command saw them gone
+	srv.propagateExpired(cmd.Client != nil)
+	if spec.has(FLAG_WRITE) && srv.Store.Dirty() != dirty {
+		args := cmd.Args
+		if cmd.rewrite != nil {
+			args = cmd.rewrite
+		}
+		srv.propagate(args, cmd.Client)
+		if srv.txn == nil {
+			srv.serveBlocked(spec.keysOf(cmd.Args))
+		}
+	}
+	return keepOpen
+}
+
+func (cmd *Command) quit(logger *logger.Logger) bool {
 	logger.Info("Handle QUIT", nil)
-	cmd.Conn.Write([]uint8("+OK\r\n"))
+	cmd.writeOK()
 	return false
 }
 
 func (cmd *Command) del(store *store.InMemoryStore) bool {
 	count := 0
 	for _, key := range cmd.Args[1:] {
-		if _, ok := store.Data.LoadAndDelete(key); ok {
+		if store.Delete(key) {
 			count++
 		}
 	}
 	// Write back to the client the number of keys deleted
-	cmd.Conn.Write(fmt.Appendf(nil, ":%d\r\n", count))
+	cmd.writeInt(int64(count))
 	return true
 }
 
 func (cmd *Command) get(logger *logger.Logger, store *store.InMemoryStore) bool {
-	if len(cmd.Args) != 2 {
-		cmd.Conn.Write([]uint8("-ERR wrong number of arguments for '" + cmd.Args[0] + "' command\r\n"))
-		return true
-	}
 	logger.Info("Handle GET", nil)
-	val, _ := store.Data.Load(cmd.Args[1])
-	if val != nil {
-		res, _ := val.(string)
+	val, ok := store.Get(cmd.Args[1])
+	if ok {
+		res, isString := val.(string)
+		if !isString {
+			cmd.writeError(WRONGTYPE)
+			return true
+		}
 		if strings.HasPrefix(res, "\"") {
 			res, _ = strconv.Unquote(res)
 		}
 		logger.Info("Response length", map[string]string{"length": strconv.Itoa(len(res))})
-		cmd.Conn.Write(fmt.Appendf(nil, "$%d\r\n", len(res)))
-		cmd.Conn.Write(append([]uint8(res), []uint8("\r\n")...)) // Write the key-value
+		cmd.writeBulk(res)
 	} else {
-		cmd.Conn.Write([]uint8("$-1\r\n"))
+		cmd.writeNil()
 	}
 	return true
 }
 
+// SET key value [NX | XX] [GET] [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]
+// Options may come in any order
 func (cmd *Command) set(logger *logger.Logger, store *store.InMemoryStore) bool {
-	if len(cmd.Args) < 3 || len(cmd.Args) > 6 {
-		cmd.Conn.Write([]uint8("-ERR wrong number of arguments for '" + cmd.Args[0] + "' command\r\n"))
-		return true
-	}
 	logger.Info("Handle SET", nil)
 	logger.Info("Value length", map[string]string{"length": strconv.Itoa(len(cmd.Args[2]))})
-	if len(cmd.Args) > 3 {
-		pos := 3
-		option := strings.ToUpper(cmd.Args[pos])
-		switch option {
-		// Set the key if it does not exist before
+	opts, errMsg := parseSetOptions(cmd.Args[3:])
+	if errMsg != "" {
+		cmd.writeError(errMsg)
+		return true
+	}
+
+	key := cmd.Args[1]
+	old, exists := store.Get(key)
+	if opts.get && exists {
+		if _, ok := old.(string); !ok {
+			cmd.writeError(WRONGTYPE)
+			return true
+		}
+	}
+	// The reply to a SET that did not happen is nil, or the current value with GET
+	reply := func() {
+		switch {
+		case !opts.get:
+			cmd.writeOK()
+		case exists:
+			cmd.writeBulk(old.(string))
+		default:
+			cmd.writeNil()
+		}
+	}
+	if (opts.nx && exists) || (opts.xx && !exists) {
+		if opts.get {
+			reply()
+		} else {
+			cmd.writeNil()
+		}
+		return true
+	}
+
+	var deadline time.Time
+	if opts.keepTTL {
+		deadline, _ = store.ExpireAt(key)
+	} else if opts.expire {
+		ms, ok := toMillis(opts.n, opts.unit, opts.absolute)
+		if !ok || (!opts.absolute && opts.n <= 0) || ms <= 0 {
+			cmd.writeError("ERR invalid expire time in 'set' command")
+			return true
+		}
+		deadline = time.UnixMilli(ms)
+	}
+
+	store.Set(key, cmd.Args[2])
+	// Followers and the AOF get the outcome, so a relative TTL becomes absolute
+	// and NX, XX and GET are dropped since they were already settled
+	cmd.rewrite = []string{SET, key, cmd.Args[2]}
+	if !deadline.IsZero() {
+		// The key is reclaimed once accessed after its deadline or by the active expire cycle
+		store.SetExpiry(key, deadline)
+		cmd.rewrite = append(cmd.rewrite, PXAT, strconv.FormatInt(deadline.UnixMilli(), 10))
+		logger.Info("Key expires", map[string]string{"duration": shortDur(time.Until(deadline))})
+	}
+	reply()
+	return true
+}
+
+type setOptions struct {
+	nx, xx, get, keepTTL bool
+
+	expire   bool // Whether one of EX, PX, EXAT or PXAT was given
+	n        int64
+	unit     time.Duration
+	absolute bool
+}
+
+// Return the error to reply with if the options are invalid
+func parseSetOptions(args []string) (setOptions, string) {
+	var opts setOptions
+	for i := 0; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); option {
 		case NX:
-			logger.Info("Handle NX", nil)
-			if _, ok := store.Data.Load(cmd.Args[1]); ok {
-				cmd.Conn.Write([]uint8("$-1\r\n"))
-				return true
+			if opts.xx {
+				return opts, SYNTAX_ERROR
 			}
-			pos++
-		// Only set the key if it it already exists
+			opts.nx = true
 		case XX:
-			logger.Info("Handle NX", nil)
-			if _, ok := store.Data.Load(cmd.Args[1]); !ok {
-				cmd.Conn.Write([]uint8("$-1\r\n"))
-				return true
+			if opts.nx {
+				return opts, SYNTAX_ERROR
 			}
-			pos++
-		}
-
-		// Parse the expiration flag
-		if len(cmd.Args) > pos {
-			if err := cmd.setExpiration(pos, logger, store); err != nil {
-				cmd.Conn.Write([]uint8("-ERR " + err.Error() + "\r\n"))
-				return true
+			opts.xx = true
+		case GET:
+			opts.get = true
+		case KEEPTTL:
+			if opts.expire {
+				return opts, SYNTAX_ERROR
+			}
+			opts.keepTTL = true
+		case EX, PX, EXAT, PXAT:
+			if opts.expire || opts.keepTTL || i+1 == len(args) {
+				return opts, SYNTAX_ERROR
+			}
+			i++
+			n, err := strconv.ParseInt(args[i], 10, 64)
+			if err != nil {
+				return opts, NOT_INTEGER
 			}
+			opts.expire, opts.n = true, n
+			opts.unit, opts.absolute = time.Second, option == EXAT
+			if option == PX || option == PXAT {
+				opts.unit, opts.absolute = time.Millisecond, option == PXAT
+			}
+		default:
+			return opts, SYNTAX_ERROR
 		}
-
 	}
-
-	store.Data.Store(cmd.Args[1], cmd.Args[2])
-	cmd.Conn.Write([]uint8("+OK\r\n"))
-	return true
+	return opts, ""
 }
 
+// PING [message]
 func (cmd *Command) ping(logger *logger.Logger) bool {
-	if len(cmd.Args) != 1 {
-		cmd.Conn.Write([]uint8("-ERR wrong number of arguments for '" + cmd.Args[0] + "' command\r\n"))
+	if len(cmd.Args) > 2 {
+		cmd.writeArityError()
 		return true
 	}
 	logger.Info("Handle PING", nil)
-	cmd.Conn.Write([]uint8("$PONG\r\n"))
+	if len(cmd.Args) == 2 {
+		cmd.writeBulk(cmd.Args[1])
+		return true
+	}
+	cmd.writeSimple("PONG")
 	return true
 }
 
 func (cmd *Command) echo(logger *logger.Logger) bool {
-	if len(cmd.Args) != 2 {
-		cmd.Conn.Write([]uint8("-ERR wrong number of arguments for '" + cmd.Args[0] + "' command\r\n"))
-		return true
-	}
-
 	logger.Info("Handle ECHO", nil)
-	cmd.Conn.Write(append([]uint8(cmd.Args[1]), []uint8("\r\n")...))
+	cmd.writeBulk(cmd.Args[1])
 	return true
 }
 
-func (cmd *Command) setExpiration(pos int, logger *logger.Logger, store *store.InMemoryStore) error {
-	option := strings.ToUpper(cmd.Args[pos])
-	value, _ := strconv.Atoi(cmd.Args[pos+1])
-	var duration time.Duration
-
-	switch option {
-	case EX:
-		duration = time.Second * time.Duration(value)
-	case PX:
-		duration = time.Millisecond * time.Duration(value)
-	default:
-		return fmt.Errorf("expiration option not valid")
-	}
-
-	// Wait by sleeping then delete the key-value from the store.Data
-	go func() {
-		logger.Info("Handling expirations", map[string]string{"option": option, "duration": shortDur(duration)})
-		time.Sleep(duration)
-		store.Data.Delete(cmd.Args[1])
-	}()
-	return nil
-}
-
+// Compact duration for logs, e.g. 1m instead of 1m0s
 func shortDur(d time.Duration) string {
 	s := d.String()
 	if strings.HasSuffix(s, "m0s") || strings.HasSuffix(s, "h0m") {
@@ -189,3 +346,31 @@ func shortDur(d time.Duration) string {
 	}
 	return s
 }
+
+// Name of the type of the value stored at key, none if it does not exist
+func (cmd *Command) keyType(db *store.InMemoryStore) bool {
+	val, ok := db.Get(cmd.Args[1])
+	if !ok {
+		cmd.writeSimple("none")
+		return true
+	}
+	cmd.writeSimple(typeName(val))
+	return true
+}
+
+func typeName(val any) string {
+	switch val.(type) {
+	case string:
+		return "string"
+	case *store.List:
+		return "list"
+	case *store.Hash:
+		return "hash"
+	case *store.Set:
+		return "set"
+	case *store.ZSet:
+		return "zset"
+	default:
+		return "none"
+	}
+}
@@ -0,0 +1,76 @@
+package command
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSetOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected setOptions
+		errMsg   string
+	}{
+		{"None", nil, setOptions{}, ""},
+		{"Any case", []string{"nx", "Get"}, setOptions{nx: true, get: true}, ""},
+		{"Expire before condition", []string{"EX", "10", "XX"}, setOptions{xx: true, expire: true, n: 10, unit: time.Second}, ""},
+		{"PXAT", []string{"PXAT", "1893456000000"}, setOptions{expire: true, n: 1893456000000, unit: time.Millisecond, absolute: true}, ""},
+		{"KEEPTTL", []string{"KEEPTTL", "GET"}, setOptions{get: true, keepTTL: true}, ""},
+		// XX once parsed as PX and swallowed the next argument as a TTL
+		{"XX is not PX", []string{"XX", "GET"}, setOptions{xx: true, get: true}, ""},
+		{"NX and XX", []string{"NX", "XX"}, setOptions{}, SYNTAX_ERROR},
+		{"EX and PX", []string{"EX", "10", "PX", "100"}, setOptions{}, SYNTAX_ERROR},
+		{"KEEPTTL and EX", []string{"KEEPTTL", "EX", "10"}, setOptions{}, SYNTAX_ERROR},
+		{"EX without a value", []string{"EX"}, setOptions{}, SYNTAX_ERROR},
+		{"EX not an integer", []string{"EX", "abc"}, setOptions{}, NOT_INTEGER},
+		{"Unknown option", []string{"NX", "PXX", "1"}, setOptions{}, SYNTAX_ERROR},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, errMsg := parseSetOptions(tt.args)
+			if errMsg != tt.errMsg {
+				t.Fatalf("Expected %q, got %q", tt.errMsg, errMsg)
+			}
+			if errMsg == "" && opts != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, opts)
+			}
+		})
+	}
+}
+
+func TestSetGetKeepTTL(t *testing.T) {
+	srv := newTestServer()
+	runCommand(srv, "SET", "a", "1", "EX", "100")
+	if got := runCommand(srv, "SET", "a", "2", "KEEPTTL", "GET"); got != "$1\r\n1\r\n" {
+		t.Errorf("Expected the old value, got %q", got)
+	}
+	if got := runCommand(srv, "TTL", "a"); got != ":100\r\n" {
+		t.Errorf("Expected the TTL to be kept, got %q", got)
+	}
+	// A plain SET drops it
+	runCommand(srv, "SET", "a", "3")
+	if got := runCommand(srv, "TTL", "a"); got != ":-1\r\n" {
+		t.Errorf("Expected no TTL, got %q", got)
+	}
+	if got := runCommand(srv, "SET", "a", "4", "NX", "GET"); got != "$1\r\n3\r\n" {
+		t.Errorf("Expected the old value, got %q", got)
+	}
+}
+
+// The expire time is only checked once the options parsed
+func TestSetExpireTime(t *testing.T) {
+	srv := newTestServer()
+	for _, args := range [][]string{{"EX", "0"}, {"PX", "-1"}, {"EX", "9223372036854775807"}} {
+		if got := runCommand(srv, append([]string{"SET", "a", "1"}, args...)...); got != "-ERR invalid expire time in 'set' command\r\n" {
+			t.Errorf("Expected an invalid expire time for %v, got %q", args, got)
+		}
+	}
+	if got := runCommand(srv, "SET", "a", "1", "PXAT", "1"); got != "+OK\r\n" {
+		t.Errorf("Expected OK, got %q", got)
+	}
+	if got := runCommand(srv, "GET", "a"); got != "$-1\r\n" {
+		t.Errorf("Expected a to be expired, got %q", got)
+	}
+}
@@ -0,0 +1,53 @@
+package command
+
+import (
+	"errors"
+	"strings"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/config"
+)
+
+const CONFIG = "CONFIG"
+
+// CONFIG GET pattern [pattern ...] | SET name value [name value ...] | REWRITE | RESETSTAT
+func (cmd *Command) config(srv *Server) bool {
+	switch sub := strings.ToUpper(cmd.Args[1]); {
+	case sub == "GET" && len(cmd.Args) > 2:
+		cmd.writeMap(srv.Config.Match(cmd.Args[2:]...))
+	case sub == "SET" && len(cmd.Args) > 3 && len(cmd.Args)%2 == 0:
+		if err := srv.Config.Set(cmd.Args[2:]...); err != nil {
+			cmd.writeError("ERR " + err.Error())
+			return true
+		}
+		// Only the names, values may be passwords
+		var names []string
+		for i := 2; i < len(cmd.Args); i += 2 {
+			names = append(names, strings.ToLower(cmd.Args[i]))
+		}
+		srv.Logger.Info("Configuration changed", map[string]string{"settings": strings.Join(names, " ")})
+		cmd.writeOK()
+	case sub == "REWRITE" && len(cmd.Args) == 2:
+		if err := srv.Config.Rewrite(); err != nil {
+			if !errors.Is(err, config.ErrNoFile) {
+				srv.Logger.Error(err, nil)
+			}
+			cmd.writeError("ERR " + err.Error())
+			return true
+		}
+		srv.Logger.Info("CONFIG REWRITE executed with success", nil)
+		cmd.writeOK()
+	case sub == "RESETSTAT" && len(cmd.Args) == 2:
+		srv.resetStats()
+		cmd.writeOK()
+	default:
+		cmd.writeError("ERR unknown subcommand or wrong number of arguments for '" + strings.ToLower(cmd.Args[1]) + "'. Try CONFIG HELP.")
+	}
+	return true
+}
+
+// Start counting again from zero, like after a restart
+// Must be called with srv.mu held
+func (srv *Server) resetStats() {
+	srv.stats = serverStats{}
+	srv.Store.ResetStats()
+}
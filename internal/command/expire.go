@@ -0,0 +1,179 @@
+package command
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+)
+
+const (
+	EXPIRE      = "EXPIRE"
+	PEXPIRE     = "PEXPIRE"
+	EXPIREAT    = "EXPIREAT"
+	PEXPIREAT   = "PEXPIREAT"
+	TTL         = "TTL"
+	PTTL        = "PTTL"
+	EXPIRETIME  = "EXPIRETIME"
+	PEXPIRETIME = "PEXPIRETIME"
+	PERSIST     = "PERSIST"
+	GT          = "GT"
+	LT          = "LT"
+)
+
+// EXPIRE key seconds [NX | XX | GT | LT], and PEXPIRE, EXPIREAT, PEXPIREAT
+// unit is the unit of the given time, absolute tells a unix time from a TTL
+// A key without an expiration counts as having an infinite TTL for GT and LT
+func (cmd *Command) expire(db *store.InMemoryStore, unit time.Duration, absolute bool) bool {
+	n, err := strconv.ParseInt(cmd.Args[2], 10, 64)
+	if err != nil {
+		cmd.writeError(NOT_INTEGER)
+		return true
+	}
+	var nx, xx, gt, lt bool
+	for _, arg := range cmd.Args[3:] {
+		switch strings.ToUpper(arg) {
+		case NX:
+			nx = true
+		case XX:
+			xx = true
+		case GT:
+			gt = true
+		case LT:
+			lt = true
+		default:
+			cmd.writeError("ERR Unsupported option " + arg)
+			return true
+		}
+	}
+	if nx && (xx || gt || lt) {
+		cmd.writeError("ERR NX and XX, GT or LT options at the same time are not compatible")
+		return true
+	}
+	if gt && lt {
+		cmd.writeError("ERR GT and LT options at the same time are not compatible")
+		return true
+	}
+
+	// Work in milliseconds since the epoch, the precision of every deadline we keep
+	ms, ok := toMillis(n, unit, absolute)
+	if !ok {
+		cmd.writeError("ERR invalid expire time in '" + strings.ToLower(cmd.Args[0]) + "' command")
+		return true
+	}
+
+	key := cmd.Args[1]
+	current, ok := db.ExpireAt(key)
+	if !ok {
+		cmd.writeInt(0)
+		return true
+	}
+	hasTTL := !current.IsZero()
+	switch {
+	case nx && hasTTL,
+		xx && !hasTTL,
+		gt && (!hasTTL || ms <= current.UnixMilli()),
+		lt && hasTTL && ms >= current.UnixMilli():
+		cmd.writeInt(0)
+		return true
+	}
+
+	// A deadline in the past deletes the key right away
+	if ms <= time.Now().UnixMilli() {
+		db.Delete(key)
+		cmd.rewrite = []string{DEL, key}
+	} else {
+		db.SetExpiry(key, time.UnixMilli(ms))
+		cmd.rewrite = []string{PEXPIREAT, key, strconv.FormatInt(ms, 10)}
+	}
+	cmd.writeInt(1)
+	return true
+}
+
+// Turn n units, relative to now unless absolute, into a unix time in milliseconds
+// Return false if it does not fit
+func toMillis(n int64, unit time.Duration, absolute bool) (int64, bool) {
+	scale := int64(unit / time.Millisecond)
+	if n > math.MaxInt64/scale || n < math.MinInt64/scale {
+		return 0, false
+	}
+	ms := n * scale
+	if absolute {
+		return ms, true
+	}
+	now := time.Now().UnixMilli()
+	if ms > math.MaxInt64-now {
+		return 0, false
+	}
+	return now + ms, true
+}
+
+// TTL key, PTTL key, EXPIRETIME key and PEXPIRETIME key
+// -2 if the key does not exist and -1 if it has no expiration
+func (cmd *Command) ttl(db *store.InMemoryStore, unit time.Duration, absolute bool) bool {
+	at, ok := db.ExpireAt(cmd.Args[1])
+	switch {
+	case !ok:
+		cmd.writeInt(-2)
+	case at.IsZero():
+		cmd.writeInt(-1)
+	case absolute:
+		cmd.writeInt(at.UnixMilli() / int64(unit/time.Millisecond))
+	default:
+		remaining := max(time.Until(at), 0)
+		// Round to the nearest unit like Redis does, e.g. 1.6s left is a TTL of 2
+		cmd.writeInt(int64((remaining + unit/2) / unit))
+	}
+	return true
+}
+
+// PERSIST key
+func (cmd *Command) persist(db *store.InMemoryStore) bool {
+	at, ok := db.ExpireAt(cmd.Args[1])
+	if !ok || at.IsZero() {
+		cmd.writeInt(0)
+		return true
+	}
+	db.SetExpiry(cmd.Args[1], time.Time{})
+	cmd.writeInt(1)
+	return true
+}
+
+const (
+	ACTIVE_EXPIRE_INTERVAL  = 100 * time.Millisecond // How often the active expire cycle runs, like hz 10
+	ACTIVE_EXPIRE_BUDGET    = 25 * time.Millisecond  // How long a single cycle may keep going
+	ACTIVE_EXPIRE_SAMPLE    = 20                     // Keys with a TTL looked at per round
+	ACTIVE_EXPIRE_STALE_PCT = 25                     // Start another round while more than this share of a sample was expired
+)
+
+// Reclaim expired keys nobody asks for anymore, otherwise they would only go away once accessed
+// Followers skip it, their leader sends a DEL for every key it reclaims
+// Runs until the process exits
+func (srv *Server) ActiveExpire() {
+	ticker := time.NewTicker(ACTIVE_EXPIRE_INTERVAL)
+	defer ticker.Stop()
+	for range ticker.C {
+		srv.expireCycle()
+	}
+}
+
+// Sample keys with a TTL in rounds, each one holding the lock only briefly
+// and stop once few of them turn out to be expired or the time budget is spent
+func (srv *Server) expireCycle() {
+	start := time.Now()
+	for time.Since(start) < ACTIVE_EXPIRE_BUDGET {
+		srv.mu.Lock()
+		if srv.Replication.IsReplica() {
+			srv.mu.Unlock()
+			return
+		}
+		sampled, expired := srv.Store.ExpireSample(ACTIVE_EXPIRE_SAMPLE)
+		srv.propagateExpired(true)
+		srv.mu.Unlock()
+		if sampled == 0 || expired*100 <= sampled*ACTIVE_EXPIRE_STALE_PCT {
+			return
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package command
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/aof"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+)
+
+func TestExpire(t *testing.T) {
+	srv := newTestServer()
+	if got := runCommand(srv, "EXPIRE", "a", "10"); got != ":0\r\n" {
+		t.Errorf("Expected :0 for a missing key, got %q", got)
+	}
+	runCommand(srv, "SET", "a", "1")
+	if got := runCommand(srv, "TTL", "a"); got != ":-1\r\n" {
+		t.Errorf("Expected :-1, got %q", got)
+	}
+	if got := runCommand(srv, "PEXPIRE", "a", "1600"); got != ":1\r\n" {
+		t.Fatalf("Expected :1, got %q", got)
+	}
+	// Rounded to the closest second
+	if got := runCommand(srv, "TTL", "a"); got != ":2\r\n" {
+		t.Errorf("Expected :2, got %q", got)
+	}
+	if got := runCommand(srv, "PERSIST", "a"); got != ":1\r\n" {
+		t.Errorf("Expected :1, got %q", got)
+	}
+	if got := runCommand(srv, "PTTL", "a"); got != ":-1\r\n" {
+		t.Errorf("Expected :-1, got %q", got)
+	}
+
+	runCommand(srv, "PEXPIREAT", "a", "32503680000500")
+	if got := runCommand(srv, "EXPIRETIME", "a"); got != ":32503680000\r\n" {
+		t.Errorf("Expected :32503680000, got %q", got)
+	}
+
+	// A deadline in the past deletes the key
+	if got := runCommand(srv, "EXPIREAT", "a", "1"); got != ":1\r\n" {
+		t.Errorf("Expected :1, got %q", got)
+	}
+	if got := runCommand(srv, "TTL", "a"); got != ":-2\r\n" {
+		t.Errorf("Expected :-2, got %q", got)
+	}
+}
+
+func TestExpireOptions(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+		ttl      string // Reply to TTL a afterwards
+	}{
+		{[]string{"EXPIRE", "a", "10", "NX"}, ":0\r\n", ":100\r\n"},
+		{[]string{"EXPIRE", "a", "10", "XX"}, ":1\r\n", ":10\r\n"},
+		{[]string{"EXPIRE", "a", "20", "LT"}, ":0\r\n", ":10\r\n"},
+		{[]string{"EXPIRE", "a", "20", "GT"}, ":1\r\n", ":20\r\n"},
+		{[]string{"EXPIRE", "a", "10", "NX", "XX"}, "-ERR NX and XX, GT or LT options at the same time are not compatible\r\n", ":20\r\n"},
+		{[]string{"EXPIRE", "a", "10", "GT", "LT"}, "-ERR GT and LT options at the same time are not compatible\r\n", ":20\r\n"},
+		{[]string{"EXPIRE", "a", "10", "KEEPTTL"}, "-ERR Unsupported option KEEPTTL\r\n", ":20\r\n"},
+		{[]string{"PEXPIRE", "a", "9223372036854775807"}, "-ERR invalid expire time in 'pexpire' command\r\n", ":20\r\n"},
+		// No TTL counts as an infinite one
+		{[]string{"PERSIST", "a"}, ":1\r\n", ":-1\r\n"},
+		{[]string{"EXPIRE", "a", "10", "GT"}, ":0\r\n", ":-1\r\n"},
+		{[]string{"EXPIRE", "a", "10", "LT"}, ":1\r\n", ":10\r\n"},
+	}
+
+	srv := newTestServer()
+	runCommand(srv, "SET", "a", "1", "EX", "100")
+	for _, tt := range tests {
+		if got := runCommand(srv, tt.args...); got != tt.expected {
+			t.Errorf("Expected %q for %v, got %q", tt.expected, tt.args, got)
+		}
+		if got := runCommand(srv, "TTL", "a"); got != tt.ttl {
+			t.Errorf("Expected a TTL of %q after %v, got %q", tt.ttl, tt.args, got)
+		}
+	}
+}
+
+func TestToMillis(t *testing.T) {
+	now := time.Now().UnixMilli()
+	if got, ok := toMillis(10, time.Second, false); !ok || got < now+10_000 || got > now+11_000 {
+		t.Errorf("Expected 10s from now, got %d", got-now)
+	}
+	if got, ok := toMillis(1893456000, time.Second, true); !ok || got != 1893456000000 {
+		t.Errorf("Expected 1893456000000, got %d", got)
+	}
+	if _, ok := toMillis(math.MaxInt64/1000+1, time.Second, true); ok {
+		t.Error("Expected the seconds to overflow")
+	}
+	if _, ok := toMillis(math.MaxInt64-1000, time.Millisecond, false); ok {
+		t.Error("Expected adding now to overflow")
+	}
+}
+
+// Keys reclaimed on access or by the active cycle are deleted on followers and in the AOF too
+func TestExpiredKeysPropagated(t *testing.T) {
+	srv := newTestServer()
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	log, err := aof.Open(path, aof.FsyncAlways, srv.Logger, func(r io.Reader) (int64, error) { return 0, nil })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer log.Close()
+	srv.AOF = log
+	for _, key := range []string{"a", "b"} {
+		srv.Store.Set(key, "1")
+		srv.Store.SetExpiry(key, time.Now().Add(-time.Second))
+	}
+
+	offset := srv.Replication.Offset()
+	if got := handleNow(t, srv, connect(srv), "GET", "a"); got != "$-1\r\n" {
+		t.Errorf("Expected a to be expired, got %q", got)
+	}
+	if srv.Replication.Offset() == offset {
+		t.Error("Expected the DEL to be fed to followers")
+	}
+	srv.expireCycle()
+
+	got, _ := os.ReadFile(path)
+	expected := resp.AppendArray(resp.AppendArray(nil, []string{"DEL", "a"}), []string{"DEL", "b"})
+	if !bytes.Equal(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,262 @@
+package command
+
+import (
+	"math"
+	"strconv"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+)
+
+const (
+	HSET    = "HSET"
+	HSETNX  = "HSETNX"
+	HGET    = "HGET"
+	HMGET   = "HMGET"
+	HDEL    = "HDEL"
+	HGETALL = "HGETALL"
+	HINCRBY = "HINCRBY"
+	HEXISTS = "HEXISTS"
+	HLEN    = "HLEN"
+	HKEYS   = "HKEYS"
+	HVALS   = "HVALS"
+)
+
+// Hash stored at key, nil if there is none
+// Reply with WRONGTYPE and return false if the key holds something else
+func (cmd *Command) lookupHash(db *store.InMemoryStore, key string) (*store.Hash, bool) {
+	val, ok := db.Get(key)
+	if !ok {
+		return nil, true
+	}
+	h, ok := val.(*store.Hash)
+	if !ok {
+		cmd.writeError(WRONGTYPE)
+		return nil, false
+	}
+	return h, true
+}
+
+// Same as lookupHash but create an empty hash when there is none
+func (cmd *Command) hashForWrite(db *store.InMemoryStore, key string) (*store.Hash, bool) {
+	h, ok := cmd.lookupHash(db, key)
+	if !ok || h != nil {
+		return h, ok
+	}
+	h = store.NewHash()
+	db.Set(key, h)
+	return h, true
+}
+
+// HSET key field value [field value ...]
+func (cmd *Command) hset(db *store.InMemoryStore) bool {
+	if len(cmd.Args)%2 != 0 {
+		cmd.writeArityError()
+		return true
+	}
+	key := cmd.Args[1]
+	h, ok := cmd.hashForWrite(db, key)
+	if !ok {
+		return true
+	}
+	added := 0
+	for i := 2; i < len(cmd.Args); i += 2 {
+		if h.Set(cmd.Args[i], cmd.Args[i+1]) {
+			added++
+		}
+	}
+	db.Modified(key)
+	cmd.writeInt(int64(added))
+	return true
+}
+
+// HSETNX key field value
+func (cmd *Command) hsetnx(db *store.InMemoryStore) bool {
+	key, field := cmd.Args[1], cmd.Args[2]
+	h, ok := cmd.lookupHash(db, key)
+	if !ok {
+		return true
+	}
+	if h != nil {
+		if _, exists := h.Get(field); exists {
+			cmd.writeInt(0)
+			return true
+		}
+	}
+	h, _ = cmd.hashForWrite(db, key)
+	h.Set(field, cmd.Args[3])
+	db.Modified(key)
+	cmd.writeInt(1)
+	return true
+}
+
+// HGET key field
+func (cmd *Command) hget(db *store.InMemoryStore) bool {
+	h, ok := cmd.lookupHash(db, cmd.Args[1])
+	if !ok {
+		return true
+	}
+	if h == nil {
+		cmd.writeNil()
+		return true
+	}
+	v, ok := h.Get(cmd.Args[2])
+	if !ok {
+		cmd.writeNil()
+		return true
+	}
+	cmd.writeBulk(v)
+	return true
+}
+
+// HMGET key field [field ...]
+// Missing fields come back as nil in the array
+func (cmd *Command) hmget(db *store.InMemoryStore) bool {
+	h, ok := cmd.lookupHash(db, cmd.Args[1])
+	if !ok {
+		return true
+	}
+	fields := cmd.Args[2:]
+	cmd.writeArrayLen(len(fields))
+	for _, field := range fields {
+		var v string
+		var found bool
+		if h != nil {
+			v, found = h.Get(field)
+		}
+		if found {
+			cmd.writeBulk(v)
+		} else {
+			cmd.writeNil()
+		}
+	}
+	return true
+}
+
+// HDEL key field [field ...]
+func (cmd *Command) hdel(db *store.InMemoryStore) bool {
+	key := cmd.Args[1]
+	h, ok := cmd.lookupHash(db, key)
+	if !ok {
+		return true
+	}
+	if h == nil {
+		cmd.writeInt(0)
+		return true
+	}
+	removed := 0
+	for _, field := range cmd.Args[2:] {
+		if h.Delete(field) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		cmd.hashChanged(db, key, h)
+	}
+	cmd.writeInt(int64(removed))
+	return true
+}
+
+// HGETALL key
+func (cmd *Command) hgetall(db *store.InMemoryStore) bool {
+	return cmd.hashList(db, (*store.Hash).Pairs, cmd.writeMap)
+}
+
+// HKEYS key
+func (cmd *Command) hkeys(db *store.InMemoryStore) bool {
+	return cmd.hashList(db, (*store.Hash).Keys, cmd.writeArray)
+}
+
+// HVALS key
+func (cmd *Command) hvals(db *store.InMemoryStore) bool {
+	return cmd.hashList(db, (*store.Hash).Values, cmd.writeArray)
+}
+
+// Reply with part of the hash, empty if the key does not exist
+func (cmd *Command) hashList(db *store.InMemoryStore, items func(*store.Hash) []string, write func([]string)) bool {
+	h, ok := cmd.lookupHash(db, cmd.Args[1])
+	if !ok {
+		return true
+	}
+	if h == nil {
+		write(nil)
+		return true
+	}
+	write(items(h))
+	return true
+}
+
+// HINCRBY key field increment
+func (cmd *Command) hincrby(db *store.InMemoryStore) bool {
+	incr, err := strconv.ParseInt(cmd.Args[3], 10, 64)
+	if err != nil {
+		cmd.writeError(NOT_INTEGER)
+		return true
+	}
+	key, field := cmd.Args[1], cmd.Args[2]
+	h, ok := cmd.lookupHash(db, key)
+	if !ok {
+		return true
+	}
+
+	var current int64
+	if h != nil {
+		if v, exists := h.Get(field); exists {
+			current, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				cmd.writeError("ERR hash value is not an integer")
+				return true
+			}
+		}
+	}
+	if (incr > 0 && current > math.MaxInt64-incr) || (incr < 0 && current < math.MinInt64-incr) {
+		cmd.writeError("ERR increment or decrement would overflow")
+		return true
+	}
+
+	h, _ = cmd.hashForWrite(db, key)
+	h.Set(field, strconv.FormatInt(current+incr, 10))
+	db.Modified(key)
+	cmd.writeInt(current + incr)
+	return true
+}
+
+// HEXISTS key field
+func (cmd *Command) hexists(db *store.InMemoryStore) bool {
+	h, ok := cmd.lookupHash(db, cmd.Args[1])
+	if !ok {
+		return true
+	}
+	if h == nil {
+		cmd.writeInt(0)
+		return true
+	}
+	if _, exists := h.Get(cmd.Args[2]); exists {
+		cmd.writeInt(1)
+	} else {
+		cmd.writeInt(0)
+	}
+	return true
+}
+
+// HLEN key
+func (cmd *Command) hlen(db *store.InMemoryStore) bool {
+	h, ok := cmd.lookupHash(db, cmd.Args[1])
+	if !ok {
+		return true
+	}
+	if h == nil {
+		cmd.writeInt(0)
+		return true
+	}
+	cmd.writeInt(int64(h.Len()))
+	return true
+}
+
+// Record a change to the hash and drop the key once the hash is empty
+func (cmd *Command) hashChanged(db *store.InMemoryStore, key string, h *store.Hash) {
+	if h.Len() == 0 {
+		db.Delete(key)
+		return
+	}
+	db.Modified(key)
+}
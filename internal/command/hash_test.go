@@ -0,0 +1,67 @@
+package command
+
+import "testing"
+
+func TestHashSetDelete(t *testing.T) {
+	srv := newTestServer()
+	if got := runCommand(srv, "HSET", "h", "a", "1", "b", "2"); got != ":2\r\n" {
+		t.Fatalf("Expected :2, got %q", got)
+	}
+	// Overwritten fields are not counted
+	if got := runCommand(srv, "HSET", "h", "a", "3", "c", "3"); got != ":1\r\n" {
+		t.Errorf("Expected :1, got %q", got)
+	}
+	if got := runCommand(srv, "HGET", "h", "a"); got != "$1\r\n3\r\n" {
+		t.Errorf("Expected 3, got %q", got)
+	}
+	if got := runCommand(srv, "HDEL", "h", "a", "b", "x"); got != ":2\r\n" {
+		t.Errorf("Expected :2, got %q", got)
+	}
+
+	// The key goes away with the last field, like in Redis
+	if got := runCommand(srv, "HDEL", "h", "c"); got != ":1\r\n" {
+		t.Fatalf("Expected :1, got %q", got)
+	}
+	if got := runCommand(srv, "TYPE", "h"); got != "+none\r\n" {
+		t.Errorf("Expected none, got %q", got)
+	}
+	if got := runCommand(srv, "HDEL", "h", "a"); got != ":0\r\n" {
+		t.Errorf("Expected :0, got %q", got)
+	}
+}
+
+func TestHashWrongType(t *testing.T) {
+	srv := newTestServer()
+	runCommand(srv, "SET", "s", "1")
+	if got := runCommand(srv, "HSET", "s", "a", "1"); got != "-"+WRONGTYPE+"\r\n" {
+		t.Errorf("Expected WRONGTYPE, got %q", got)
+	}
+}
+
+func TestHashIncrement(t *testing.T) {
+	tests := []struct {
+		field    string
+		incr     string
+		expected string
+	}{
+		{"missing", "5", ":5\r\n"},
+		{"small", "5", ":2\r\n"},
+		{"max", "1", "-ERR increment or decrement would overflow\r\n"},
+		{"min", "-1", "-ERR increment or decrement would overflow\r\n"},
+		{"word", "1", "-ERR hash value is not an integer\r\n"},
+		{"small", "1.5", "-" + NOT_INTEGER + "\r\n"},
+	}
+
+	srv := newTestServer()
+	runCommand(srv, "HSET", "h", "small", "-3", "max", "9223372036854775807", "min", "-9223372036854775808", "word", "abc")
+	for _, tt := range tests {
+		if got := runCommand(srv, "HINCRBY", "h", tt.field, tt.incr); got != tt.expected {
+			t.Errorf("Expected %q for %s, got %q", tt.expected, tt.field, got)
+		}
+	}
+
+	// A failed HINCRBY leaves the field as it was
+	if got := runCommand(srv, "HGET", "h", "max"); got != "$19\r\n9223372036854775807\r\n" {
+		t.Errorf("Expected the field to be kept, got %q", got)
+	}
+}
@@ -0,0 +1,101 @@
+package command
+
+import (
+	"strconv"
+	"strings"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+)
+
+const HELLO = "HELLO"
+
+// Version of Redis whose behavior we follow
+// Clients look at it to tell which commands and replies they can count on
+const REDIS_VERSION = "7.2.0"
+
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+// Switch the connection to another protocol and describe the server in it
+func (cmd *Command) hello(srv *Server) bool {
+	proto := cmd.protocol()
+	if len(cmd.Args) > 1 {
+		v, err := strconv.Atoi(cmd.Args[1])
+		if err != nil {
+			cmd.writeError("ERR Protocol version is not an integer or out of range")
+			return true
+		}
+		if v != resp.RESP2 && v != resp.RESP3 {
+			cmd.writeError("NOPROTO unsupported protocol version")
+			return true
+		}
+		proto = v
+	}
+
+	var name, username, password *string
+	for i := 2; i < len(cmd.Args); i++ {
+		opt := strings.ToUpper(cmd.Args[i])
+		switch {
+		case opt == "AUTH" && i+2 < len(cmd.Args):
+			username, password = &cmd.Args[i+1], &cmd.Args[i+2]
+			i += 2
+		case opt == "SETNAME" && i+1 < len(cmd.Args):
+			if !validClientName(cmd.Args[i+1]) {
+				cmd.writeError("ERR Client names cannot contain spaces, newlines or special characters.")
+				return true
+			}
+			name = &cmd.Args[i+1]
+			i++
+		default:
+			cmd.writeError("ERR Syntax error in HELLO option '" + cmd.Args[i] + "'")
+			return true
+		}
+	}
+
+	c := cmd.Client
+	if c == nil {
+		return true
+	}
+	if username != nil {
+		if !cmd.login(srv, *username, *password) {
+			return true
+		}
+	} else if c.user == nil {
+		cmd.writeError("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+		return true
+	}
+	c.proto = proto
+	if name != nil {
+		c.name = *name
+	}
+
+	role := "master"
+	if srv.Replication.IsReplica() {
+		role = "replica"
+	}
+	// The reply already goes out in the new protocol
+	cmd.writeMapLen(7)
+	cmd.writeBulk("server")
+	cmd.writeBulk("redis")
+	cmd.writeBulk("version")
+	cmd.writeBulk(REDIS_VERSION)
+	cmd.writeBulk("proto")
+	cmd.writeInt(int64(proto))
+	cmd.writeBulk("id")
+	cmd.writeInt(int64(c.id))
+	cmd.writeBulk("mode")
+	cmd.writeBulk("standalone")
+	cmd.writeBulk("role")
+	cmd.writeBulk(role)
+	cmd.writeBulk("modules")
+	cmd.writeArray(nil)
+	return true
+}
+
+// Printable ASCII without spaces, so names show up intact in logs
+func validClientName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] < '!' || name[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
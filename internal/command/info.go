@@ -0,0 +1,188 @@
+package command
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sections in the order INFO prints them, each one a list of key:value lines
+// Sections left out of the default ones are only printed when asked for, or with all or everything
+var infoSections = []struct {
+	name         string
+	lines        func(srv *Server) []string
+	notByDefault bool
+}{
+	{"server", (*Server).infoServer, false},
+	{"clients", (*Server).infoClients, false},
+	{"memory", (*Server).infoMemory, false},
+	{"persistence", (*Server).infoPersistence, false},
+	{"stats", (*Server).infoStats, false},
+	{"replication", func(srv *Server) []string { return srv.Replication.Info() }, false},
+	{"commandstats", (*Server).commandStats, true},
+	{"keyspace", (*Server).infoKeyspace, false},
+}
+
+var (
+	startTime = time.Now()
+	// Tells a restarted server apart, unlike the replication ID it is never shared
+	runID = func() string {
+		b := make([]byte, 20)
+		rand.Read(b)
+		return hex.EncodeToString(b)
+	}()
+)
+
+// INFO [section ...]
+func (cmd *Command) info(srv *Server) bool {
+	wanted := make(map[string]bool)
+	for _, arg := range cmd.Args[1:] {
+		wanted[strings.ToLower(arg)] = true
+	}
+	all := wanted["all"] || wanted["everything"]
+	defaults := len(wanted) == 0 || wanted["default"]
+
+	var b strings.Builder
+	for _, section := range infoSections {
+		if !all && !wanted[section.name] && (!defaults || section.notByDefault) {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		// Section titles are capitalized, e.g. # Replication
+		fmt.Fprintf(&b, "# %s%s\r\n", strings.ToUpper(section.name[:1]), section.name[1:])
+		for _, line := range section.lines(srv) {
+			b.WriteString(line + "\r\n")
+		}
+	}
+
+	cmd.writeVerbatim(b.String())
+	return true
+}
+
+func (srv *Server) infoServer() []string {
+	uptime := time.Since(startTime)
+	return []string{
+		"redis_version:" + REDIS_VERSION,
+		"redis_mode:standalone",
+		"os:" + runtime.GOOS + " " + runtime.GOARCH,
+		fmt.Sprintf("arch_bits:%d", strconv.IntSize),
+		"go_version:" + runtime.Version(),
+		fmt.Sprintf("process_id:%d", os.Getpid()),
+		"run_id:" + runID,
+		"tcp_port:" + srv.Config.Get("port"),
+		fmt.Sprintf("server_time_usec:%d", time.Now().UnixMicro()),
+		fmt.Sprintf("uptime_in_seconds:%d", int64(uptime.Seconds())),
+		fmt.Sprintf("uptime_in_days:%d", int64(uptime.Hours()/24)),
+		"config_file:" + srv.Config.File(),
+	}
+}
+
+func (srv *Server) infoClients() []string {
+	// Followers are counted under replication
+	connected := 0
+	for c := range srv.clients {
+		if c.replica == nil {
+			connected++
+		}
+	}
+	blocked := make(map[*Client]bool)
+	for _, waiters := range srv.waiters {
+		for _, w := range waiters {
+			blocked[w.cmd.Client] = true
+		}
+	}
+	return []string{
+		fmt.Sprintf("connected_clients:%d", connected),
+		fmt.Sprintf("blocked_clients:%d", len(blocked)),
+		fmt.Sprintf("total_blocking_keys:%d", len(srv.waiters)),
+	}
+}
+
+func (srv *Server) infoMemory() []string {
+	// Heap figures come from the Go runtime, the dataset is what maxmemory is checked against
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	rss := m.Sys - m.HeapReleased
+	limit, policy := srv.Store.MaxMemory()
+	return []string{
+		fmt.Sprintf("used_memory:%d", m.HeapAlloc),
+		"used_memory_human:" + bytesToHuman(m.HeapAlloc),
+		fmt.Sprintf("used_memory_rss:%d", rss),
+		"used_memory_rss_human:" + bytesToHuman(rss),
+		fmt.Sprintf("used_memory_dataset:%d", srv.Store.UsedMemory()),
+		fmt.Sprintf("maxmemory:%d", limit),
+		"maxmemory_human:" + bytesToHuman(uint64(limit)),
+		fmt.Sprintf("maxmemory_policy:%s", policy),
+		fmt.Sprintf("mem_fragmentation_ratio:%.2f", float64(rss)/float64(max(m.HeapAlloc, 1))),
+		"mem_allocator:go",
+		fmt.Sprintf("mem_gc_cycles:%d", m.NumGC),
+	}
+}
+
+func (srv *Server) infoPersistence() []string {
+	status := "ok"
+	if srv.RDB.LastError() != nil {
+		status = "err"
+	}
+	return []string{
+		"loading:0",
+		fmt.Sprintf("rdb_changes_since_last_save:%d", srv.RDB.Changes()),
+		fmt.Sprintf("rdb_bgsave_in_progress:%d", boolToInt(srv.RDB.InProgress())),
+		fmt.Sprintf("rdb_last_save_time:%d", srv.RDB.LastSave().Unix()),
+		"rdb_last_bgsave_status:" + status,
+		fmt.Sprintf("aof_enabled:%d", boolToInt(srv.AOF != nil)),
+		fmt.Sprintf("aof_rewrite_in_progress:%d", boolToInt(srv.AOF != nil && srv.AOF.InProgress())),
+	}
+}
+
+func (srv *Server) infoStats() []string {
+	return []string{
+		fmt.Sprintf("total_connections_received:%d", srv.stats.connections),
+		fmt.Sprintf("total_commands_processed:%d", srv.stats.commands),
+		fmt.Sprintf("expired_keys:%d", srv.Store.Expired()),
+		fmt.Sprintf("evicted_keys:%d", srv.Store.Evicted()),
+		fmt.Sprintf("keyspace_hits:%d", srv.stats.hits),
+		fmt.Sprintf("keyspace_misses:%d", srv.stats.misses),
+		fmt.Sprintf("pubsub_channels:%d", len(srv.PubSub.Channels(""))),
+		fmt.Sprintf("pubsub_patterns:%d", srv.PubSub.NumPat()),
+	}
+}
+
+// A single database, left out while it is empty like Redis does
+func (srv *Server) infoKeyspace() []string {
+	keys := srv.Store.Len()
+	if keys == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("db0:keys=%d,expires=%d", keys, srv.Store.Expiring())}
+}
+
+// Format a size the way Redis does, e.g. 1.50M
+func bytesToHuman(n uint64) string {
+	units := []string{"K", "M", "G", "T", "P"}
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	size := float64(n) / 1024
+	for _, unit := range units[:len(units)-1] {
+		if size < 1024 {
+			return fmt.Sprintf("%.2f%s", size, unit)
+		}
+		size /= 1024
+	}
+	return fmt.Sprintf("%.2f%s", size, units[len(units)-1])
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
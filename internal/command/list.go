@@ -0,0 +1,384 @@
+package command
+
+import (
+	"strconv"
+	"strings"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+)
+
+const (
+	LPUSH   = "LPUSH"
+	RPUSH   = "RPUSH"
+	LPUSHX  = "LPUSHX"
+	RPUSHX  = "RPUSHX"
+	LPOP    = "LPOP"
+	RPOP    = "RPOP"
+	LRANGE  = "LRANGE"
+	LLEN    = "LLEN"
+	LINDEX  = "LINDEX"
+	LSET    = "LSET"
+	LREM    = "LREM"
+	LTRIM   = "LTRIM"
+	LINSERT = "LINSERT"
+	LMOVE   = "LMOVE"
+)
+
+// List stored at key, nil if there is none
+// Reply with WRONGTYPE and return false if the key holds something else
+func (cmd *Command) lookupList(db *store.InMemoryStore, key string) (*store.List, bool) {
+	val, ok := db.Get(key)
+	if !ok {
+		return nil, true
+	}
+	l, ok := val.(*store.List)
+	if !ok {
+		cmd.writeError(WRONGTYPE)
+		return nil, false
+	}
+	return l, true
+}
+
+// LPUSH/RPUSH key element [element ...]
+// The X variants only push when the list already exists
+func (cmd *Command) push(db *store.InMemoryStore, left bool, onlyIfExists bool) bool {
+	key := cmd.Args[1]
+	l, ok := cmd.lookupList(db, key)
+	if !ok {
+		return true
+	}
+	if l == nil {
+		if onlyIfExists {
+			cmd.writeInt(0)
+			return true
+		}
+		l = store.NewList()
+		db.Set(key, l)
+	}
+
+	for _, v := range cmd.Args[2:] {
+		if left {
+			l.PushLeft(v)
+		} else {
+			l.PushRight(v)
+		}
+	}
+	db.Modified(key)
+	cmd.writeInt(int64(l.Len()))
+	return true
+}
+
+// LPOP/RPOP key [count]
+func (cmd *Command) pop(db *store.InMemoryStore, left bool) bool {
+	if len(cmd.Args) > 3 {
+		cmd.writeArityError()
+		return true
+	}
+	count := -1 // No count means a single bulk reply instead of an array
+	if len(cmd.Args) == 3 {
+		n, err := strconv.Atoi(cmd.Args[2])
+		if err != nil || n < 0 {
+			cmd.writeError(NOT_POSITIVE)
+			return true
+		}
+		count = n
+	}
+
+	key := cmd.Args[1]
+	l, ok := cmd.lookupList(db, key)
+	if !ok {
+		return true
+	}
+	if l == nil {
+		if count < 0 {
+			cmd.writeNil()
+		} else {
+			cmd.writeNilArray()
+		}
+		return true
+	}
+
+	n := 1
+	if count >= 0 {
+		n = count
+	}
+	popped := popN(l, n, left)
+	if len(popped) > 0 {
+		cmd.listChanged(db, key, l)
+	}
+
+	if count < 0 {
+		cmd.writeBulk(popped[0])
+	} else {
+		cmd.writeArray(popped)
+	}
+	return true
+}
+
+// LRANGE key start stop
+func (cmd *Command) lrange(db *store.InMemoryStore) bool {
+	start, err1 := strconv.Atoi(cmd.Args[2])
+	stop, err2 := strconv.Atoi(cmd.Args[3])
+	if err1 != nil || err2 != nil {
+		cmd.writeError(NOT_INTEGER)
+		return true
+	}
+
+	l, ok := cmd.lookupList(db, cmd.Args[1])
+	if !ok {
+		return true
+	}
+	if l == nil {
+		cmd.writeArray(nil)
+		return true
+	}
+	start, stop = clampRange(start, stop, l.Len())
+	cmd.writeArray(l.Range(start, stop))
+	return true
+}
+
+// LLEN key
+func (cmd *Command) llen(db *store.InMemoryStore) bool {
+	l, ok := cmd.lookupList(db, cmd.Args[1])
+	if !ok {
+		return true
+	}
+	if l == nil {
+		cmd.writeInt(0)
+		return true
+	}
+	cmd.writeInt(int64(l.Len()))
+	return true
+}
+
+// LINDEX key index
+func (cmd *Command) lindex(db *store.InMemoryStore) bool {
+	index, err := strconv.Atoi(cmd.Args[2])
+	if err != nil {
+		cmd.writeError(NOT_INTEGER)
+		return true
+	}
+	l, ok := cmd.lookupList(db, cmd.Args[1])
+	if !ok {
+		return true
+	}
+	if l == nil {
+		cmd.writeNil()
+		return true
+	}
+	if index < 0 {
+		index += l.Len()
+	}
+	if index < 0 || index >= l.Len() {
+		cmd.writeNil()
+		return true
+	}
+	cmd.writeBulk(l.Index(index))
+	return true
+}
+
+// LSET key index element
+func (cmd *Command) lset(db *store.InMemoryStore) bool {
+	index, err := strconv.Atoi(cmd.Args[2])
+	if err != nil {
+		cmd.writeError(NOT_INTEGER)
+		return true
+	}
+	key := cmd.Args[1]
+	l, ok := cmd.lookupList(db, key)
+	if !ok {
+		return true
+	}
+	if l == nil {
+		cmd.writeError(NO_SUCH_KEY)
+		return true
+	}
+	if index < 0 {
+		index += l.Len()
+	}
+	if index < 0 || index >= l.Len() {
+		cmd.writeError(OUT_OF_RANGE)
+		return true
+	}
+	l.Set(index, cmd.Args[3])
+	db.Modified(key)
+	cmd.writeOK()
+	return true
+}
+
+// LREM key count element
+func (cmd *Command) lrem(db *store.InMemoryStore) bool {
+	count, err := strconv.Atoi(cmd.Args[2])
+	if err != nil {
+		cmd.writeError(NOT_INTEGER)
+		return true
+	}
+	key := cmd.Args[1]
+	l, ok := cmd.lookupList(db, key)
+	if !ok {
+		return true
+	}
+	if l == nil {
+		cmd.writeInt(0)
+		return true
+	}
+	removed := l.Remove(count, cmd.Args[3])
+	if removed > 0 {
+		cmd.listChanged(db, key, l)
+	}
+	cmd.writeInt(int64(removed))
+	return true
+}
+
+// LTRIM key start stop
+func (cmd *Command) ltrim(db *store.InMemoryStore) bool {
+	start, err1 := strconv.Atoi(cmd.Args[2])
+	stop, err2 := strconv.Atoi(cmd.Args[3])
+	if err1 != nil || err2 != nil {
+		cmd.writeError(NOT_INTEGER)
+		return true
+	}
+	key := cmd.Args[1]
+	l, ok := cmd.lookupList(db, key)
+	if !ok {
+		return true
+	}
+	if l != nil {
+		start, stop = clampRange(start, stop, l.Len())
+		l.Trim(start, stop)
+		cmd.listChanged(db, key, l)
+	}
+	cmd.writeOK()
+	return true
+}
+
+// LINSERT key BEFORE|AFTER pivot element
+func (cmd *Command) linsert(db *store.InMemoryStore) bool {
+	var before bool
+	switch strings.ToUpper(cmd.Args[2]) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+		before = false
+	default:
+		cmd.writeError(SYNTAX_ERROR)
+		return true
+	}
+	key := cmd.Args[1]
+	l, ok := cmd.lookupList(db, key)
+	if !ok {
+		return true
+	}
+	if l == nil {
+		cmd.writeInt(0)
+		return true
+	}
+	n := l.Insert(cmd.Args[3], cmd.Args[4], before)
+	if n > 0 {
+		db.Modified(key)
+	}
+	cmd.writeInt(int64(n))
+	return true
+}
+
+// LMOVE source destination LEFT|RIGHT LEFT|RIGHT
+func (cmd *Command) lmove(db *store.InMemoryStore) bool {
+	from, ok1 := parseEnd(cmd.Args[3])
+	to, ok2 := parseEnd(cmd.Args[4])
+	if !ok1 || !ok2 {
+		cmd.writeError(SYNTAX_ERROR)
+		return true
+	}
+	cmd.move(db, cmd.Args[1], cmd.Args[2], from, to)
+	return true
+}
+
+// Pop an element from one end of source and push it to one end of destination
+// Both can be the same list, which rotates it
+func (cmd *Command) move(db *store.InMemoryStore, source, destination string, from, to bool) {
+	src, ok := cmd.lookupList(db, source)
+	if !ok {
+		return
+	}
+	// Check the destination before touching anything
+	dst, ok := cmd.lookupList(db, destination)
+	if !ok {
+		return
+	}
+	if src == nil {
+		cmd.writeNil()
+		return
+	}
+
+	v := popN(src, 1, from)[0]
+	cmd.listChanged(db, source, src)
+	if source == destination || dst == nil {
+		// The source may have been deleted if that was its last element
+		dst, _ = cmd.lookupList(db, destination)
+	}
+	if dst == nil {
+		dst = store.NewList()
+		db.Set(destination, dst)
+	}
+	if to {
+		dst.PushLeft(v)
+	} else {
+		dst.PushRight(v)
+	}
+	db.Modified(destination)
+
+	cmd.rewrite = []string{LMOVE, source, destination, endName(from), endName(to)}
+	cmd.writeBulk(v)
+}
+
+func endName(left bool) string {
+	if left {
+		return "LEFT"
+	}
+	return "RIGHT"
+}
+
+// Record a change to the list and drop the key once the list is empty, like Redis does
+func (cmd *Command) listChanged(db *store.InMemoryStore, key string, l *store.List) {
+	if l.Len() == 0 {
+		db.Delete(key)
+		return
+	}
+	db.Modified(key)
+}
+
+// Pop up to n elements from one end
+func popN(l *store.List, n int, left bool) []string {
+	popped := make([]string, 0, min(n, l.Len()))
+	for range n {
+		var v string
+		var ok bool
+		if left {
+			v, ok = l.PopLeft()
+		} else {
+			v, ok = l.PopRight()
+		}
+		if !ok {
+			break
+		}
+		popped = append(popped, v)
+	}
+	return popped
+}
+
+// Turn negative indexes into positions from the head and clamp them to the list
+// An empty range comes back with start > stop
+func clampRange(start, stop, length int) (int, int) {
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	start = max(start, 0)
+	stop = min(stop, length-1)
+	if start > stop || start >= length {
+		return 1, 0
+	}
+	return start, stop
+}
@@ -0,0 +1,44 @@
+package command
+
+func (cmd *Command) save(srv *Server) bool {
+	srv.Logger.Info("Handle SAVE", nil)
+	if err := srv.RDB.Save(); err != nil {
+		srv.Logger.Error(err, nil)
+		cmd.writeError("ERR " + err.Error())
+		return true
+	}
+	cmd.writeOK()
+	return true
+}
+
+func (cmd *Command) bgsave(srv *Server) bool {
+	srv.Logger.Info("Handle BGSAVE", nil)
+	if err := srv.RDB.BackgroundSave(); err != nil {
+		cmd.writeError("ERR " + err.Error())
+		return true
+	}
+	cmd.writeSimple("Background saving started")
+	return true
+}
+
+// Reply with the unix time of the last successful save
+func (cmd *Command) lastsave(srv *Server) bool {
+	cmd.writeInt(int64(srv.RDB.LastSave().Unix()))
+	return true
+}
+
+func (cmd *Command) bgrewriteaof(srv *Server) bool {
+	srv.Logger.Info("Handle BGREWRITEAOF", nil)
+	if srv.AOF == nil {
+		cmd.writeError("ERR Append only file is disabled")
+		return true
+	}
+	// No other command runs while the snapshot is taken
+	// so every later write is caught by the rewrite buffer
+	if err := srv.AOF.BackgroundRewrite(srv.Store.Snapshot()); err != nil {
+		cmd.writeError("ERR " + err.Error())
+		return true
+	}
+	cmd.writeSimple("Background append only file rewriting started")
+	return true
+}
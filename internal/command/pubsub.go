@@ -0,0 +1,163 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/pubsub"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+)
+
+const (
+	SUBSCRIBE    = "SUBSCRIBE"
+	UNSUBSCRIBE  = "UNSUBSCRIBE"
+	PSUBSCRIBE   = "PSUBSCRIBE"
+	PUNSUBSCRIBE = "PUNSUBSCRIBE"
+	PUBLISH      = "PUBLISH"
+	PUBSUB       = "PUBSUB"
+)
+
+// The only commands a client may send while it has subscriptions
+var subscriberCommands = map[string]bool{
+	SUBSCRIBE: true, UNSUBSCRIBE: true, PSUBSCRIBE: true, PUNSUBSCRIBE: true, PING: true, QUIT: true,
+}
+
+// Whether the client is in subscriber mode, i.e. has at least one subscription
+func (srv *Server) subscribed(c *Client) bool {
+	return c != nil && c.subscriber != nil && srv.PubSub.Count(c.subscriber) > 0
+}
+
+// SUBSCRIBE channel [channel ...] / PSUBSCRIBE pattern [pattern ...]
+func (cmd *Command) subscribe(srv *Server, pattern bool) bool {
+	if cmd.Client == nil {
+		return true
+	}
+	c := cmd.Client
+	if c.subscriber == nil {
+		c.subscriber = pubsub.NewSubscriber(func() {
+			srv.Logger.Info("Disconnecting subscriber that fell too far behind", map[string]string{"client": c.Conn.RemoteAddr().String()})
+			c.Conn.Close()
+		})
+	}
+
+	for _, name := range cmd.Args[1:] {
+		if pattern {
+			cmd.writeSubscription("psubscribe", &name, srv.PubSub.PSubscribe(c.subscriber, name))
+		} else {
+			cmd.writeSubscription("subscribe", &name, srv.PubSub.Subscribe(c.subscriber, name))
+		}
+	}
+	return true
+}
+
+// UNSUBSCRIBE [channel ...] / PUNSUBSCRIBE [pattern ...]
+// Without arguments, drop every channel or pattern subscription
+func (cmd *Command) unsubscribe(srv *Server, pattern bool) bool {
+	kind := "unsubscribe"
+	if pattern {
+		kind = "punsubscribe"
+	}
+	c := cmd.Client
+	if c == nil || c.subscriber == nil {
+		if len(cmd.Args) == 1 {
+			cmd.writeSubscription(kind, nil, 0)
+		}
+		for _, name := range cmd.Args[1:] {
+			cmd.writeSubscription(kind, &name, 0)
+		}
+		return true
+	}
+
+	names := cmd.Args[1:]
+	if len(names) == 0 {
+		if pattern {
+			names = srv.PubSub.PatternsOf(c.subscriber)
+		} else {
+			names = srv.PubSub.ChannelsOf(c.subscriber)
+		}
+		if len(names) == 0 {
+			cmd.writeSubscription(kind, nil, srv.PubSub.Count(c.subscriber))
+			return true
+		}
+	}
+	for _, name := range names {
+		if pattern {
+			cmd.writeSubscription(kind, &name, srv.PubSub.PUnsubscribe(c.subscriber, name))
+		} else {
+			cmd.writeSubscription(kind, &name, srv.PubSub.Unsubscribe(c.subscriber, name))
+		}
+	}
+	return true
+}
+
+// PUBLISH channel message
+func (cmd *Command) publish(srv *Server) bool {
+	receivers := srv.PubSub.Publish(cmd.Args[1], cmd.Args[2])
+	// Subscribers connected to our followers get the message too
+	// A follower only forwards what its leader sent, its offsets must stay in line
+	if cmd.Client != nil && !srv.Replication.IsReplica() {
+		srv.Replication.Feed(cmd.Args)
+	}
+	cmd.writeInt(int64(receivers))
+	return true
+}
+
+// PUBSUB CHANNELS [pattern] | NUMSUB [channel ...] | NUMPAT
+func (cmd *Command) pubsub(srv *Server) bool {
+	switch sub := strings.ToUpper(cmd.Args[1]); {
+	case sub == "CHANNELS" && len(cmd.Args) <= 3:
+		pattern := ""
+		if len(cmd.Args) == 3 {
+			pattern = cmd.Args[2]
+		}
+		cmd.writeArray(srv.PubSub.Channels(pattern))
+	case sub == "NUMSUB":
+		channels := cmd.Args[2:]
+		cmd.writeMapLen(len(channels))
+		for _, channel := range channels {
+			cmd.writeBulk(channel)
+			cmd.writeInt(int64(srv.PubSub.NumSub(channel)))
+		}
+	case sub == "NUMPAT" && len(cmd.Args) == 2:
+		cmd.writeInt(int64(srv.PubSub.NumPat()))
+	default:
+		cmd.writeError("ERR unknown subcommand or wrong number of arguments for '" + cmd.Args[1] + "'. Try PUBSUB HELP.")
+	}
+	return true
+}
+
+// In subscriber mode, refuse everything but the subscription commands
+// and answer PING with an array so it can't be mistaken for a message
+// RESP3 tells messages apart with push frames so anything goes
+// Return false if the command should run as usual
+func (cmd *Command) subscriberMode(name string) bool {
+	switch {
+	case cmd.protocol() == resp.RESP3:
+		return false
+	case name == PING:
+		payload := ""
+		if len(cmd.Args) > 1 {
+			payload = cmd.Args[1]
+		}
+		cmd.writeArray([]string{"pong", payload})
+		return true
+	case subscriberCommands[name]:
+		return false
+	default:
+		cmd.writeError(fmt.Sprintf("ERR Can't execute '%s': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context", strings.ToLower(cmd.Args[0])))
+		return true
+	}
+}
+
+// kind name count, name is nil when there was nothing to unsubscribe from
+// Sent as a push frame like the messages that follow
+func (cmd *Command) writeSubscription(kind string, name *string, count int) {
+	cmd.writePushLen(3)
+	cmd.writeBulk(kind)
+	if name == nil {
+		cmd.writeNil()
+	} else {
+		cmd.writeBulk(*name)
+	}
+	cmd.writeInt(int64(count))
+}
@@ -0,0 +1,430 @@
+package command
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+)
+
+// What a command may do, reported by COMMAND with the names below
+// Dispatch, replication and ACL decisions are made from these rather than from lists of names
+type commandFlag uint16
+
+const (
+	FLAG_WRITE    commandFlag = 1 << iota // May change the keyspace, propagated to the AOF and our followers
+	FLAG_READONLY                         // Only reads the keyspace
+	FLAG_DENYOOM                          // May need more memory, refused once maxmemory is reached
+	FLAG_ADMIN                            // Server administration, e.g. SAVE or REPLICAOF
+	FLAG_PUBSUB                           // Publish/subscribe
+	FLAG_NOSCRIPT                         // Not allowed in scripts
+	FLAG_BLOCKING                         // May wait for another client
+	FLAG_LOADING                          // Allowed while loading the dataset
+	FLAG_STALE                            // Allowed on a replica that lost its leader
+	FLAG_FAST                             // O(1) or O(log N)
+	FLAG_NO_AUTH                          // Allowed before authenticating
+)
+
+var flagNames = []struct {
+	flag commandFlag
+	name string
+}{
+	{FLAG_WRITE, "write"},
+	{FLAG_READONLY, "readonly"},
+	{FLAG_DENYOOM, "denyoom"},
+	{FLAG_ADMIN, "admin"},
+	{FLAG_PUBSUB, "pubsub"},
+	{FLAG_NOSCRIPT, "noscript"},
+	{FLAG_BLOCKING, "blocking"},
+	{FLAG_LOADING, "loading"},
+	{FLAG_STALE, "stale"},
+	{FLAG_FAST, "fast"},
+	{FLAG_NO_AUTH, "no_auth"},
+}
+
+// Groups of COMMAND DOCS, each one also an ACL category, see categories
+var groupCategories = map[string]string{
+	"generic":      "@keyspace",
+	"string":       "@string",
+	"list":         "@list",
+	"hash":         "@hash",
+	"set":          "@set",
+	"sorted-set":   "@sortedset",
+	"pubsub":       "@pubsub",
+	"transactions": "@transaction",
+	"connection":   "@connection",
+	"server":       "@server",
+}
+
+// Positions of the keys among the arguments, from first to last every step
+// last counts from the end when negative, e.g. -2 for BLPOP whose timeout comes after the keys
+type keySpec struct {
+	first, last, step int
+}
+
+var noKeys = keySpec{}
+
+type commandSpec struct {
+	name    string
+	arity   int // Number of arguments including the name, -N for at least N
+	flags   commandFlag
+	keys    keySpec
+	group   string // For COMMAND DOCS
+	since   string // Version of Redis that introduced the command
+	summary string
+	handler func(cmd *Command, srv *Server) bool
+}
+
+// Handlers that only need the keyspace
+func withStore(handler func(*Command, *store.InMemoryStore) bool) func(*Command, *Server) bool {
+	return func(cmd *Command, srv *Server) bool {
+		return handler(cmd, srv.Store)
+	}
+}
+
+// Every command the server knows about, see commandSpec
+var commandTable = []commandSpec{
+	// Strings
+	{GET, 2, FLAG_READONLY | FLAG_FAST, keySpec{1, 1, 1}, "string", "1.0.0", "Returns the string value of a key.",
+		func(cmd *Command, srv *Server) bool { return cmd.get(srv.Logger, srv.Store) }},
+	{SET, -3, FLAG_WRITE | FLAG_DENYOOM, keySpec{1, 1, 1}, "string", "1.0.0", "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.",
+		func(cmd *Command, srv *Server) bool { return cmd.set(srv.Logger, srv.Store) }},
+
+	// Keys
+	{DEL, -2, FLAG_WRITE, keySpec{1, -1, 1}, "generic", "1.0.0", "Deletes one or more keys.", withStore((*Command).del)},
+	{TYPE, 2, FLAG_READONLY | FLAG_FAST, keySpec{1, 1, 1}, "generic", "1.0.0", "Determines the type of value stored at a key.", withStore((*Command).keyType)},
+	{EXPIRE, -3, FLAG_WRITE | FLAG_FAST, keySpec{1, 1, 1}, "generic", "1.0.0", "Sets the expiration time of a key in seconds.",
+		func(cmd *Command, srv *Server) bool { return cmd.expire(srv.Store, time.Second, false) }},
+	{PEXPIRE, -3, FLAG_WRITE | FLAG_FAST, keySpec{1, 1, 1}, "generic", "2.6.0", "Sets the expiration time of a key in milliseconds.",
+		func(cmd *Command, srv *Server) bool { return cmd.expire(srv.Store, time.Millisecond, false) }},
+	{EXPIREAT, -3, FLAG_WRITE | FLAG_FAST, keySpec{1, 1, 1}, "generic", "1.2.0", "Sets the expiration time of a key to a Unix timestamp.",
+		func(cmd *Command, srv *Server) bool { return cmd.expire(srv.Store, time.Second, true) }},
+	{PEXPIREAT, -3, FLAG_WRITE | FLAG_FAST, keySpec{1, 1, 1}, "generic", "2.6.0", "Sets the expiration time of a key to a Unix milliseconds timestamp.",
+		func(cmd *Command, srv *Server) bool { return cmd.expire(srv.Store, time.Millisecond, true) }},
+	{TTL, 2, FLAG_READONLY | FLAG_FAST, keySpec{1, 1, 1}, "generic", "1.0.0", "Returns the expiration time in seconds of a key.",
+		func(cmd *Command, srv *Server) bool { return cmd.ttl(srv.Store, time.Second, false) }},
+	{PTTL, 2, FLAG_READONLY | FLAG_FAST, keySpec{1, 1, 1}, "generic", "2.6.0", "Returns the expiration time in milliseconds of a key.",
+		func(cmd *Command, srv *Server) bool { return cmd.ttl(srv.Store, time.Millisecond, false) }},
+	{EXPIRETIME, 2, FLAG_READONLY | FLAG_FAST, keySpec{1, 1, 1}, "generic", "7.0.0", "Returns the expiration time of a key as a Unix timestamp.",
+		func(cmd *Command, srv *Server) bool { return cmd.ttl(srv.Store, time.Second, true) }},
+	{PEXPIRETIME, 2, FLAG_READONLY | FLAG_FAST, keySpec{1, 1, 1}, "generic", "7.0.0", "Returns the expiration time of a key as a Unix milliseconds timestamp.",
+		func(cmd *Command, srv *Server) bool { return cmd.ttl(srv.Store, time.Millisecond, true) }},
+	{PERSIST, 2, FLAG_WRITE | FLAG_FAST, keySpec{1, 1, 1}, "generic", "2.2.0", "Removes the expiration time of a key.", withStore((*Command).persist)},
+
+	// Lists
+	{LPUSH, -3, FLAG_WRITE | FLAG_DENYOOM | FLAG_FAST, keySpec{1, 1, 1}, "list", "1.0.0", "Prepends one or more elements to a list. Creates the key if it doesn't exist.",
+		func(cmd *Command, srv *Server) bool { return cmd.push(srv.Store, true, false) }},
+	{RPUSH, -3, FLAG_WRITE | FLAG_DENYOOM | FLAG_FAST, keySpec{1, 1, 1}, "list", "1.0.0", "Appends one or more elements to a list. Creates the key if it doesn't exist.",
+		func(cmd *Command, srv *Server) bool { return cmd.push(srv.Store, false, false) }},
+	{LPUSHX, -3, FLAG_WRITE | FLAG_DENYOOM | FLAG_FAST, keySpec{1, 1, 1}, "list", "2.2.0", "Prepends one or more elements to a list only when the list exists.",
+		func(cmd *Command, srv *Server) bool { return cmd.push(srv.Store, true, true) }},
+	{RPUSHX, -3, FLAG_WRITE | FLAG_DENYOOM | FLAG_FAST, keySpec{1, 1, 1}, "list", "2.2.0", "Appends an element to a list only when the list exists.",
+		func(cmd *Command, srv *Server) bool { return cmd.push(srv.Store, false, true) }},
+	{LPOP, -2, FLAG_WRITE | FLAG_FAST, keySpec{1, 1, 1}, "list", "1.0.0", "Returns the first elements in a list after removing it. Deletes the list if the last element was popped.",
+		func(cmd *Command, srv *Server) bool { return cmd.pop(srv.Store, true) }},
+	{RPOP, -2, FLAG_WRITE | FLAG_FAST, keySpec{1, 1, 1}, "list", "1.0.0", "Returns and removes the last elements of a list. Deletes the list if the last element was popped.",
+		func(cmd *Command, srv *Server) bool { return cmd.pop(srv.Store, false) }},
+	{LRANGE, 4, FLAG_READONLY, keySpec{1, 1, 1}, "list", "1.0.0", "Returns a range of elements from a list.", withStore((*Command).lrange)},
+	{LLEN, 2, FLAG_READONLY | FLAG_FAST, keySpec{1, 1, 1}, "list", "1.0.0", "Returns the length of a list.", withStore((*Command).llen)},
+	{LINDEX, 3, FLAG_READONLY, keySpec{1, 1, 1}, "list", "1.0.0", "Returns an element from a list by its index.", withStore((*Command).lindex)},
+	{LSET, 4, FLAG_WRITE | FLAG_DENYOOM, keySpec{1, 1, 1}, "list", "1.0.0", "Sets the value of an element in a list by its index.", withStore((*Command).lset)},
+	{LREM, 4, FLAG_WRITE, keySpec{1, 1, 1}, "list", "1.0.0", "Removes elements from a list. Deletes the list if the last element was removed.", withStore((*Command).lrem)},
+	{LTRIM, 4, FLAG_WRITE, keySpec{1, 1, 1}, "list", "1.0.0", "Removes elements from both ends a list. Deletes the list if all elements were trimmed.", withStore((*Command).ltrim)},
+	{LINSERT, 5, FLAG_WRITE | FLAG_DENYOOM, keySpec{1, 1, 1}, "list", "2.2.0", "Inserts an element before or after another element in a list.", withStore((*Command).linsert)},
+	{LMOVE, 5, FLAG_WRITE | FLAG_DENYOOM, keySpec{1, 2, 1}, "list", "6.2.0", "Returns an element after popping it from one list and pushing it to another. Deletes the list if the last element was moved.", withStore((*Command).lmove)},
+	{BLPOP, -3, FLAG_WRITE | FLAG_BLOCKING, keySpec{1, -2, 1}, "list", "2.0.0", "Removes and returns the first element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.",
+		func(cmd *Command, srv *Server) bool { return cmd.bpop(srv, true) }},
+	{BRPOP, -3, FLAG_WRITE | FLAG_BLOCKING, keySpec{1, -2, 1}, "list", "2.0.0", "Removes and returns the last element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.",
+		func(cmd *Command, srv *Server) bool { return cmd.bpop(srv, false) }},
+	{BLMOVE, 6, FLAG_WRITE | FLAG_DENYOOM | FLAG_BLOCKING, keySpec{1, 2, 1}, "list", "6.2.0", "Pops an element from a list, pushes it to another list and returns it. Blocks until an element is available otherwise. Deletes the list if the last element was moved.",
+		(*Command).blmove},
+
+	// Hashes
+	{HSET, -4, FLAG_WRITE | FLAG_DENYOOM | FLAG_FAST, keySpec{1, 1, 1}, "hash", "2.0.0", "Creates or modifies the value of a field in a hash.", withStore((*Command).hset)},
+	{HSETNX, 4, FLAG_WRITE | FLAG_DENYOOM | FLAG_FAST, keySpec{1, 1, 1}, "hash", "2.0.0", "Sets the value of a field in a hash only when the field doesn't exist.", withStore((*Command).hsetnx)},
+	{HGET, 3, FLAG_READONLY | FLAG_FAST, keySpec{1, 1, 1}, "hash", "2.0.0", "Returns the value of a field in a hash.", withStore((*Command).hget)},
+	{HMGET, -3, FLAG_READONLY | FLAG_FAST, keySpec{1, 1, 1}, "hash", "2.0.0", "Returns the values of all fields in a hash.", withStore((*Command).hmget)},
+	{HDEL, -3, FLAG_WRITE | FLAG_FAST, keySpec{1, 1, 1}, "hash", "2.0.0", "Deletes one or more fields and their values from a hash. Deletes the hash if no fields remain.", withStore((*Command).hdel)},
+	{HGETALL, 2, FLAG_READONLY, keySpec{1, 1, 1}, "hash", "2.0.0", "Returns all fields and values in a hash.", withStore((*Command).hgetall)},
+	{HINCRBY, 4, FLAG_WRITE | FLAG_DENYOOM | FLAG_FAST, keySpec{1, 1, 1}, "hash", "2.0.0", "Increments the integer value of a field in a hash by a number. Uses 0 as initial value if the field doesn't exist.", withStore((*Command).hincrby)},
+	{HEXISTS, 3, FLAG_READONLY | FLAG_FAST, keySpec{1, 1, 1}, "hash", "2.0.0", "Determines whether a field exists in a hash.", withStore((*Command).hexists)},
+	{HLEN, 2, FLAG_READONLY | FLAG_FAST, keySpec{1, 1, 1}, "hash", "2.0.0", "Returns the number of fields in a hash.", withStore((*Command).hlen)},
+	{HKEYS, 2, FLAG_READONLY, keySpec{1, 1, 1}, "hash", "2.0.0", "Returns all fields in a hash.", withStore((*Command).hkeys)},
+	{HVALS, 2, FLAG_READONLY, keySpec{1, 1, 1}, "hash", "2.0.0", "Returns all values in a hash.", withStore((*Command).hvals)},
+
+	// Sets
+	{SADD, -3, FLAG_WRITE | FLAG_DENYOOM | FLAG_FAST, keySpec{1, 1, 1}, "set", "1.0.0", "Adds one or more members to a set. Creates the key if it doesn't exist.", withStore((*Command).sadd)},
+	{SREM, -3, FLAG_WRITE | FLAG_FAST, keySpec{1, 1, 1}, "set", "1.0.0", "Removes one or more members from a set. Deletes the set if the last member was removed.", withStore((*Command).srem)},
+	{SMEMBERS, 2, FLAG_READONLY, keySpec{1, 1, 1}, "set", "1.0.0", "Returns all members of a set.", withStore((*Command).smembers)},
+	{SISMEMBER, 3, FLAG_READONLY | FLAG_FAST, keySpec{1, 1, 1}, "set", "1.0.0", "Determines whether a member belongs to a set.", withStore((*Command).sismember)},
+	{SCARD, 2, FLAG_READONLY | FLAG_FAST, keySpec{1, 1, 1}, "set", "1.0.0", "Returns the number of members in a set.", withStore((*Command).scard)},
+	{SINTER, -2, FLAG_READONLY, keySpec{1, -1, 1}, "set", "1.0.0", "Returns the intersect of multiple sets.",
+		func(cmd *Command, srv *Server) bool { return cmd.setAlgebra(srv.Store, SINTER, false) }},
+	{SUNION, -2, FLAG_READONLY, keySpec{1, -1, 1}, "set", "1.0.0", "Returns the union of multiple sets.",
+		func(cmd *Command, srv *Server) bool { return cmd.setAlgebra(srv.Store, SUNION, false) }},
+	{SDIFF, -2, FLAG_READONLY, keySpec{1, -1, 1}, "set", "1.0.0", "Returns the difference of multiple sets.",
+		func(cmd *Command, srv *Server) bool { return cmd.setAlgebra(srv.Store, SDIFF, false) }},
+	{SINTERSTORE, -3, FLAG_WRITE | FLAG_DENYOOM, keySpec{1, -1, 1}, "set", "1.0.0", "Stores the intersect of multiple sets in a key.",
+		func(cmd *Command, srv *Server) bool { return cmd.setAlgebra(srv.Store, SINTER, true) }},
+	{SUNIONSTORE, -3, FLAG_WRITE | FLAG_DENYOOM, keySpec{1, -1, 1}, "set", "1.0.0", "Stores the union of multiple sets in a key.",
+		func(cmd *Command, srv *Server) bool { return cmd.setAlgebra(srv.Store, SUNION, true) }},
+	{SDIFFSTORE, -3, FLAG_WRITE | FLAG_DENYOOM, keySpec{1, -1, 1}, "set", "1.0.0", "Stores the difference of multiple sets in a key.",
+		func(cmd *Command, srv *Server) bool { return cmd.setAlgebra(srv.Store, SDIFF, true) }},
+	{SRANDMEMBER, -2, FLAG_READONLY, keySpec{1, 1, 1}, "set", "1.0.0", "Get one or multiple random members from a set", withStore((*Command).srandmember)},
+	{SPOP, -2, FLAG_WRITE | FLAG_FAST, keySpec{1, 1, 1}, "set", "1.0.0", "Returns one or more random members from a set after removing them. Deletes the set if the last member was popped.", withStore((*Command).spop)},
+
+	// Sorted sets
+	{ZADD, -4, FLAG_WRITE | FLAG_DENYOOM | FLAG_FAST, keySpec{1, 1, 1}, "sorted-set", "1.2.0", "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist.", withStore((*Command).zadd)},
+	{ZINCRBY, 4, FLAG_WRITE | FLAG_DENYOOM | FLAG_FAST, keySpec{1, 1, 1}, "sorted-set", "1.2.0", "Increments the score of a member in a sorted set.", withStore((*Command).zincrby)},
+	{ZREM, -3, FLAG_WRITE | FLAG_FAST, keySpec{1, 1, 1}, "sorted-set", "1.2.0", "Removes one or more members from a sorted set. Deletes the sorted set if all members were removed.", withStore((*Command).zrem)},
+	{ZSCORE, 3, FLAG_READONLY | FLAG_FAST, keySpec{1, 1, 1}, "sorted-set", "1.2.0", "Returns the score of a member in a sorted set.", withStore((*Command).zscore)},
+	{ZCARD, 2, FLAG_READONLY | FLAG_FAST, keySpec{1, 1, 1}, "sorted-set", "1.2.0", "Returns the number of members in a sorted set.", withStore((*Command).zcard)},
+	{ZRANK, -3, FLAG_READONLY | FLAG_FAST, keySpec{1, 1, 1}, "sorted-set", "2.0.0", "Returns the index of a member in a sorted set ordered by ascending scores.",
+		func(cmd *Command, srv *Server) bool { return cmd.zrank(srv.Store, false) }},
+	{ZREVRANK, -3, FLAG_READONLY | FLAG_FAST, keySpec{1, 1, 1}, "sorted-set", "2.0.0", "Returns the index of a member in a sorted set ordered by descending scores.",
+		func(cmd *Command, srv *Server) bool { return cmd.zrank(srv.Store, true) }},
+	{ZRANGE, -4, FLAG_READONLY, keySpec{1, 1, 1}, "sorted-set", "1.2.0", "Returns members in a sorted set within a range of indexes.",
+		func(cmd *Command, srv *Server) bool { return cmd.zrange(srv.Store, BY_RANK, false, false) }},
+	{ZREVRANGE, -4, FLAG_READONLY, keySpec{1, 1, 1}, "sorted-set", "1.2.0", "Returns members in a sorted set within a range of indexes in reverse order.",
+		func(cmd *Command, srv *Server) bool { return cmd.zrange(srv.Store, BY_RANK, true, true) }},
+	{ZRANGEBYSCORE, -4, FLAG_READONLY, keySpec{1, 1, 1}, "sorted-set", "1.0.5", "Returns members in a sorted set within a range of scores.",
+		func(cmd *Command, srv *Server) bool { return cmd.zrange(srv.Store, BY_SCORE, false, true) }},
+	{ZREVRANGEBYSCORE, -4, FLAG_READONLY, keySpec{1, 1, 1}, "sorted-set", "2.2.0", "Returns members in a sorted set within a range of scores in reverse order.",
+		func(cmd *Command, srv *Server) bool { return cmd.zrange(srv.Store, BY_SCORE, true, true) }},
+	{ZRANGEBYLEX, -4, FLAG_READONLY, keySpec{1, 1, 1}, "sorted-set", "2.8.9", "Returns members in a sorted set within a lexicographical range.",
+		func(cmd *Command, srv *Server) bool { return cmd.zrange(srv.Store, BY_LEX, false, true) }},
+	{ZPOPMIN, -2, FLAG_WRITE | FLAG_FAST, keySpec{1, 1, 1}, "sorted-set", "5.0.0", "Returns the lowest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.",
+		func(cmd *Command, srv *Server) bool { return cmd.zpop(srv.Store, false) }},
+	{ZPOPMAX, -2, FLAG_WRITE | FLAG_FAST, keySpec{1, 1, 1}, "sorted-set", "5.0.0", "Returns the highest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.",
+		func(cmd *Command, srv *Server) bool { return cmd.zpop(srv.Store, true) }},
+
+	// Pub/sub
+	{SUBSCRIBE, -2, FLAG_PUBSUB | FLAG_NOSCRIPT | FLAG_LOADING | FLAG_STALE, noKeys, "pubsub", "2.0.0", "Listens for messages published to channels.",
+		func(cmd *Command, srv *Server) bool { return cmd.subscribe(srv, false) }},
+	{PSUBSCRIBE, -2, FLAG_PUBSUB | FLAG_NOSCRIPT | FLAG_LOADING | FLAG_STALE, noKeys, "pubsub", "2.0.0", "Listens for messages published to channels that match one or more patterns.",
+		func(cmd *Command, srv *Server) bool { return cmd.subscribe(srv, true) }},
+	{UNSUBSCRIBE, -1, FLAG_PUBSUB | FLAG_NOSCRIPT | FLAG_LOADING | FLAG_STALE, noKeys, "pubsub", "2.0.0", "Stops listening to messages posted to channels.",
+		func(cmd *Command, srv *Server) bool { return cmd.unsubscribe(srv, false) }},
+	{PUNSUBSCRIBE, -1, FLAG_PUBSUB | FLAG_NOSCRIPT | FLAG_LOADING | FLAG_STALE, noKeys, "pubsub", "2.0.0", "Stops listening to messages published to channels that match one or more patterns.",
+		func(cmd *Command, srv *Server) bool { return cmd.unsubscribe(srv, true) }},
+	{PUBLISH, 3, FLAG_PUBSUB | FLAG_LOADING | FLAG_STALE | FLAG_FAST, noKeys, "pubsub", "2.0.0", "Posts a message to a channel.", (*Command).publish},
+	{PUBSUB, -2, FLAG_PUBSUB | FLAG_LOADING | FLAG_STALE, noKeys, "pubsub", "2.8.0", "A container for Pub/Sub commands.", (*Command).pubsub},
+
+	// Transactions
+	{MULTI, 1, FLAG_NOSCRIPT | FLAG_LOADING | FLAG_STALE | FLAG_FAST, noKeys, "transactions", "1.2.0", "Starts a transaction.",
+		func(cmd *Command, srv *Server) bool { return cmd.multi() }},
+	{EXEC, 1, FLAG_NOSCRIPT | FLAG_LOADING | FLAG_STALE, noKeys, "transactions", "1.2.0", "Executes all commands in a transaction.", (*Command).execTransaction},
+	{DISCARD, 1, FLAG_NOSCRIPT | FLAG_LOADING | FLAG_STALE | FLAG_FAST, noKeys, "transactions", "2.0.0", "Discards a transaction.", (*Command).discard},
+	{WATCH, -2, FLAG_NOSCRIPT | FLAG_LOADING | FLAG_STALE | FLAG_FAST, keySpec{1, -1, 1}, "transactions", "2.2.0", "Monitors changes to keys to determine the execution of a transaction.", (*Command).watch},
+	{UNWATCH, 1, FLAG_NOSCRIPT | FLAG_LOADING | FLAG_STALE | FLAG_FAST, noKeys, "transactions", "2.2.0", "Forgets about watched keys of a transaction.", (*Command).unwatch},
+
+	// Connection
+	{PING, -1, FLAG_FAST, noKeys, "connection", "1.0.0", "Returns the server's liveliness response.",
+		func(cmd *Command, srv *Server) bool { return cmd.ping(srv.Logger) }},
+	{ECHO, 2, FLAG_FAST, noKeys, "connection", "1.0.0", "Returns the given string.",
+		func(cmd *Command, srv *Server) bool { return cmd.echo(srv.Logger) }},
+	{QUIT, -1, FLAG_NOSCRIPT | FLAG_LOADING | FLAG_STALE | FLAG_FAST | FLAG_NO_AUTH, noKeys, "connection", "1.0.0", "Closes the connection.",
+		func(cmd *Command, srv *Server) bool { return cmd.quit(srv.Logger) }},
+	{AUTH, -2, FLAG_NOSCRIPT | FLAG_LOADING | FLAG_STALE | FLAG_FAST | FLAG_NO_AUTH, noKeys, "connection", "1.0.0", "Authenticates the connection.", (*Command).auth},
+	{HELLO, -1, FLAG_NOSCRIPT | FLAG_LOADING | FLAG_STALE | FLAG_FAST | FLAG_NO_AUTH, noKeys, "connection", "6.0.0", "Handshakes with the Redis server.", (*Command).hello},
+
+	// Server
+	{COMMAND, -1, FLAG_LOADING | FLAG_STALE, noKeys, "server", "2.8.13", "Returns detailed information about all commands.",
+		func(cmd *Command, srv *Server) bool { return cmd.command() }},
+	{ACL, -2, FLAG_ADMIN | FLAG_NOSCRIPT | FLAG_LOADING | FLAG_STALE, noKeys, "server", "6.0.0", "A container for Access List Control commands.", (*Command).acl},
+	{CONFIG, -2, FLAG_ADMIN | FLAG_NOSCRIPT | FLAG_LOADING | FLAG_STALE, noKeys, "server", "2.0.0", "A container for server configuration commands.", (*Command).config},
+	{INFO, -1, FLAG_LOADING | FLAG_STALE, noKeys, "server", "1.0.0", "Returns information and statistics about the server.", (*Command).info},
+	{SAVE, 1, FLAG_ADMIN | FLAG_NOSCRIPT, noKeys, "server", "1.0.0", "Synchronously saves the database(s) to disk.", (*Command).save},
+	{BGSAVE, 1, FLAG_ADMIN | FLAG_NOSCRIPT, noKeys, "server", "1.0.0", "Asynchronously saves the database(s) to disk.", (*Command).bgsave},
+	{LASTSAVE, 1, FLAG_LOADING | FLAG_STALE | FLAG_FAST, noKeys, "server", "1.0.0", "Returns the Unix timestamp of the last successful save to disk.", (*Command).lastsave},
+	{BGREWRITEAOF, 1, FLAG_ADMIN | FLAG_NOSCRIPT, noKeys, "server", "1.0.0", "Asynchronously rewrites the append-only file to disk.", (*Command).bgrewriteaof},
+	{REPLICAOF, 3, FLAG_ADMIN | FLAG_NOSCRIPT | FLAG_STALE, noKeys, "server", "5.0.0", "Configures a server as replica of another, or promotes it to a master.", (*Command).replicaof},
+	{SLAVEOF, 3, FLAG_ADMIN | FLAG_NOSCRIPT | FLAG_STALE, noKeys, "server", "1.0.0", "Sets a Redis server as a replica of another, or promotes it to being a master.", (*Command).replicaof},
+	{REPLCONF, -1, FLAG_ADMIN | FLAG_NOSCRIPT | FLAG_LOADING | FLAG_STALE, noKeys, "server", "3.0.0", "An internal command for configuring the replication stream.", (*Command).replconf},
+	{SYNC, 1, FLAG_ADMIN | FLAG_NOSCRIPT, noKeys, "server", "1.0.0", "An internal command used in replication.", (*Command).sync},
+	{PSYNC, 3, FLAG_ADMIN | FLAG_NOSCRIPT, noKeys, "server", "2.8.0", "An internal command used in replication.", (*Command).psync},
+}
+
+// Commands by upper case name, filled from commandTable
+// Handlers that look commands up can't be reached from commandTable's initializer, hence init
+var commands map[string]*commandSpec
+
+func init() {
+	commands = make(map[string]*commandSpec, len(commandTable))
+	for i := range commandTable {
+		commands[commandTable[i].name] = &commandTable[i]
+	}
+}
+
+// Find the command to run with these arguments
+// Return the error to reply with if it does not exist or got the wrong number of arguments
+func lookupCommand(args []string) (*commandSpec, string) {
+	spec, ok := commands[strings.ToUpper(args[0])]
+	if !ok {
+		// Like Redis, quote no more than 128 bytes of arguments
+		var quoted strings.Builder
+		for _, arg := range args[1:] {
+			if quoted.Len() >= 128 {
+				break
+			}
+			fmt.Fprintf(&quoted, "'%.*s' ", 128-quoted.Len(), arg)
+		}
+		return nil, "ERR unknown command '" + args[0] + "', with args beginning with: " + quoted.String()
+	}
+	if (spec.arity > 0 && len(args) != spec.arity) || len(args) < -spec.arity {
+		return nil, "ERR wrong number of arguments for '" + strings.ToLower(args[0]) + "' command"
+	}
+	return spec, ""
+}
+
+func (spec *commandSpec) has(flag commandFlag) bool {
+	return spec.flags&flag != 0
+}
+
+// ACL categories the command belongs to, derived from its group and flags like Redis does
+func (spec *commandSpec) categories() []string {
+	var out []string
+	if spec.has(FLAG_WRITE) {
+		out = append(out, "@write")
+	}
+	if spec.has(FLAG_READONLY) {
+		out = append(out, "@read")
+	}
+	if spec.has(FLAG_ADMIN) {
+		out = append(out, "@admin", "@dangerous")
+	}
+	if spec.has(FLAG_PUBSUB) && spec.group != "pubsub" {
+		out = append(out, "@pubsub")
+	}
+	if spec.has(FLAG_FAST) {
+		out = append(out, "@fast")
+	} else {
+		out = append(out, "@slow")
+	}
+	if spec.has(FLAG_BLOCKING) {
+		out = append(out, "@blocking")
+	}
+	if category, ok := groupCategories[spec.group]; ok {
+		out = append(out, category)
+	}
+	return out
+}
+
+// Keys among args according to the key positions of the command
+func (spec *commandSpec) keysOf(args []string) []string {
+	if spec.keys.first == 0 || spec.keys.first >= len(args) {
+		return nil
+	}
+	last := spec.keys.last
+	if last < 0 {
+		last += len(args)
+	}
+	var keys []string
+	for i := spec.keys.first; i <= last && i < len(args); i += spec.keys.step {
+		keys = append(keys, args[i])
+	}
+	return keys
+}
+
+const COMMAND = "COMMAND"
+
+// COMMAND [COUNT | INFO [name ...] | DOCS [name ...] | GETKEYS command [arg ...]]
+func (cmd *Command) command() bool {
+	if len(cmd.Args) == 1 {
+		names := commandNames()
+		cmd.writeArrayLen(len(names))
+		for _, name := range names {
+			cmd.writeCommandInfo(commands[name])
+		}
+		return true
+	}
+
+	switch sub := strings.ToUpper(cmd.Args[1]); {
+	case sub == "COUNT" && len(cmd.Args) == 2:
+		cmd.writeInt(int64(len(commands)))
+	case sub == "INFO":
+		names := cmd.Args[2:]
+		if len(names) == 0 {
+			names = commandNames()
+		}
+		cmd.writeArrayLen(len(names))
+		for _, name := range names {
+			if spec, ok := commands[strings.ToUpper(name)]; ok {
+				cmd.writeCommandInfo(spec)
+			} else {
+				cmd.writeNilArray()
+			}
+		}
+	case sub == "DOCS":
+		var specs []*commandSpec
+		names := cmd.Args[2:]
+		if len(names) == 0 {
+			names = commandNames()
+		}
+		for _, name := range names {
+			if spec, ok := commands[strings.ToUpper(name)]; ok {
+				specs = append(specs, spec)
+			}
+		}
+		cmd.writeMapLen(len(specs))
+		for _, spec := range specs {
+			cmd.writeBulk(strings.ToLower(spec.name))
+			cmd.writeMap([]string{"summary", spec.summary, "since", spec.since, "group", spec.group})
+		}
+	case sub == "GETKEYS" && len(cmd.Args) > 2:
+		spec, errMsg := lookupCommand(cmd.Args[2:])
+		if errMsg != "" {
+			cmd.writeError("ERR Invalid command specified")
+			return true
+		}
+		keys := spec.keysOf(cmd.Args[2:])
+		if len(keys) == 0 {
+			cmd.writeError("ERR The command has no key arguments")
+			return true
+		}
+		cmd.writeArray(keys)
+	default:
+		cmd.writeError("ERR unknown subcommand or wrong number of arguments for '" + cmd.Args[1] + "'. Try COMMAND HELP.")
+	}
+	return true
+}
+
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// name, arity, flags, first key, last key, step, ACL categories, tips, key specs and subcommands
+// Clients only use the first seven, the rest stays empty
+func (cmd *Command) writeCommandInfo(spec *commandSpec) {
+	cmd.writeArrayLen(10)
+	cmd.writeBulk(strings.ToLower(spec.name))
+	cmd.writeInt(int64(spec.arity))
+	var flags []string
+	for _, f := range flagNames {
+		if spec.has(f.flag) {
+			flags = append(flags, f.name)
+		}
+	}
+	cmd.writeSetLen(len(flags))
+	for _, flag := range flags {
+		cmd.writeSimple(flag)
+	}
+	cmd.writeInt(int64(spec.keys.first))
+	cmd.writeInt(int64(spec.keys.last))
+	cmd.writeInt(int64(spec.keys.step))
+	categories := spec.categories()
+	cmd.writeSetLen(len(categories))
+	for _, category := range categories {
+		cmd.writeSimple(category)
+	}
+	cmd.writeArray(nil)
+	cmd.writeArray(nil)
+	cmd.writeArray(nil)
+}
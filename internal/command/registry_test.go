@@ -0,0 +1,74 @@
+package command
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLookupCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected string // Error reply, empty if the command can run
+	}{
+		{"Exact arity", []string{"get", "a"}, ""},
+		{"Too many arguments", []string{"GET", "a", "b"}, "ERR wrong number of arguments for 'get' command"},
+		{"Minimum arity", []string{"del", "a", "b", "c"}, ""},
+		{"Below minimum arity", []string{"DEL"}, "ERR wrong number of arguments for 'del' command"},
+		{"Unknown command", []string{"nope", "a", "b"}, "ERR unknown command 'nope', with args beginning with: 'a' 'b' "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, errMsg := lookupCommand(tt.args)
+			if errMsg != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, errMsg)
+			}
+			if errMsg == "" && spec.name != strings.ToUpper(tt.args[0]) {
+				t.Errorf("Expected %s, got %s", strings.ToUpper(tt.args[0]), spec.name)
+			}
+		})
+	}
+}
+
+func TestKeysOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"Single key", []string{"GET", "a"}, []string{"a"}},
+		{"Every argument", []string{"DEL", "a", "b", "c"}, []string{"a", "b", "c"}},
+		{"Timeout after the keys", []string{"BLPOP", "a", "b", "0"}, []string{"a", "b"}},
+		{"Source and destination", []string{"LMOVE", "a", "b", "LEFT", "RIGHT"}, []string{"a", "b"}},
+		{"No keys", []string{"PING"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := commands[tt.args[0]].keysOf(tt.args)
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// Writes must be propagated and reads must not, a command can't be both
+func TestCommandTableFlags(t *testing.T) {
+	for _, spec := range commandTable {
+		if spec.has(FLAG_WRITE) && spec.has(FLAG_READONLY) {
+			t.Errorf("Expected %s to be either write or readonly", spec.name)
+		}
+		if spec.has(FLAG_DENYOOM) && !spec.has(FLAG_WRITE) {
+			t.Errorf("Expected %s to be a write since it is denyoom", spec.name)
+		}
+		if spec.arity == 0 || spec.handler == nil {
+			t.Errorf("Expected %s to have an arity and a handler", spec.name)
+		}
+		if _, ok := groupCategories[spec.group]; !ok {
+			t.Errorf("Expected group %q of %s to be an ACL category", spec.group, spec.name)
+		}
+	}
+}
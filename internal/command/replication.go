@@ -0,0 +1,101 @@
+package command
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/replication"
+)
+
+// REPLICAOF host port | REPLICAOF NO ONE
+func (cmd *Command) replicaof(srv *Server) bool {
+	srv.Logger.Info("Handle REPLICAOF", nil)
+
+	if strings.EqualFold(cmd.Args[1], "NO") && strings.EqualFold(cmd.Args[2], "ONE") {
+		srv.Replication.StopFollowing()
+		srv.Store.KeepExpired(false)
+		cmd.writeOK()
+		return true
+	}
+
+	port, err := strconv.Atoi(cmd.Args[2])
+	if err != nil || port < 0 || port > 65535 {
+		cmd.writeError("ERR Invalid master port")
+		return true
+	}
+	if err := srv.Replication.Follow(cmd.Args[1], cmd.Args[2]); err != nil {
+		if errors.Is(err, replication.ErrAlreadyFollowing) {
+			cmd.writeSimple("OK " + err.Error())
+			return true
+		}
+		cmd.writeError("ERR " + err.Error())
+		return true
+	}
+	srv.Store.KeepExpired(true)
+	// Blocked pops are writes, a replica only takes those from its leader
+	srv.unblockAll("UNBLOCKED force unblock from blocking operation, instance state changed (master -> replica?)")
+	cmd.writeOK()
+	return true
+}
+
+// Options a follower sends to its leader, e.g. REPLCONF listening-port 6381 or REPLCONF ACK 1024
+func (cmd *Command) replconf(srv *Server) bool {
+	if len(cmd.Args)%2 == 0 {
+		cmd.writeError("ERR syntax error")
+		return true
+	}
+	if cmd.Client == nil {
+		return true
+	}
+
+	for i := 1; i < len(cmd.Args); i += 2 {
+		option, value := strings.ToLower(cmd.Args[i]), cmd.Args[i+1]
+		switch option {
+		case "listening-port":
+			cmd.Client.listeningPort = value
+		case "ack":
+			// Acks are fire and forget, the follower never reads a reply to them
+			offset, err := strconv.ParseInt(value, 10, 64)
+			if err == nil && cmd.Client.replica != nil {
+				srv.Replication.Ack(cmd.Client.replica, offset)
+			}
+			return true
+		}
+	}
+	cmd.writeOK()
+	return true
+}
+
+// Sent by a follower to get a full copy of the keyspace followed by the command stream
+func (cmd *Command) sync(srv *Server) bool {
+	if cmd.Client == nil || cmd.Client.replica != nil {
+		return true
+	}
+	srv.Logger.Info("Handle SYNC", nil)
+
+	// No other command runs while we hold the lock
+	// so the snapshot lines up exactly with the start of the stream
+	cmd.Client.replica = srv.Replication.AddReplica(cmd.Client.Conn, cmd.Client.listeningPort, srv.Store.Snapshot())
+	return true
+}
+
+// PSYNC <replid> <offset>
+// Continue from offset if it is still in our backlog, otherwise fall back to a full resync
+// A follower that has never synced sends PSYNC ? -1
+func (cmd *Command) psync(srv *Server) bool {
+	if cmd.Client == nil || cmd.Client.replica != nil {
+		return true
+	}
+	srv.Logger.Info("Handle PSYNC", map[string]string{"replid": cmd.Args[1], "offset": cmd.Args[2]})
+
+	client := cmd.Client
+	if offset, err := strconv.ParseInt(cmd.Args[2], 10, 64); err == nil && cmd.Args[1] != "?" {
+		client.replica = srv.Replication.TryPartialSync(client.Conn, client.listeningPort, cmd.Args[1], offset)
+		if client.replica != nil {
+			return true
+		}
+	}
+	client.replica = srv.Replication.AddReplica(client.Conn, client.listeningPort, srv.Store.Snapshot())
+	return true
+}
@@ -0,0 +1,106 @@
+package command
+
+import (
+	"strings"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+)
+
+const (
+	WRONGTYPE    = "WRONGTYPE Operation against a key holding the wrong kind of value"
+	NOT_INTEGER  = "ERR value is not an integer or out of range"
+	SYNTAX_ERROR = "ERR syntax error"
+	OUT_OF_RANGE = "ERR index out of range"
+	NO_SUCH_KEY  = "ERR no such key"
+	NOT_POSITIVE = "ERR value is out of range, must be positive"
+	OOM          = "OOM command not allowed when used memory > 'maxmemory'."
+)
+
+// Every reply goes through a resp.Writer, handlers never write raw protocol
+func (cmd *Command) reply() resp.Writer {
+	return resp.NewWriter(cmd.Conn, cmd.protocol())
+}
+
+// Protocol picked by the client with HELLO
+// Commands without a client reply to nobody, RESP2 will do
+func (cmd *Command) protocol() int {
+	if cmd.Client == nil {
+		return resp.RESP2
+	}
+	return cmd.Client.proto
+}
+
+func (cmd *Command) writeOK() {
+	cmd.reply().SimpleString("OK")
+}
+
+func (cmd *Command) writeSimple(s string) {
+	cmd.reply().SimpleString(s)
+}
+
+// msg carries its own prefix, e.g. ERR or WRONGTYPE
+func (cmd *Command) writeError(msg string) {
+	cmd.failed = true
+	cmd.reply().Error(msg)
+}
+
+func (cmd *Command) writeArityError() {
+	cmd.writeError("ERR wrong number of arguments for '" + strings.ToLower(cmd.Args[0]) + "' command")
+}
+
+func (cmd *Command) writeInt(n int64) {
+	cmd.reply().Integer(n)
+}
+
+func (cmd *Command) writeBulk(s string) {
+	cmd.reply().Bulk(s)
+}
+
+func (cmd *Command) writeNil() {
+	cmd.reply().Null()
+}
+
+func (cmd *Command) writeDouble(f float64) {
+	cmd.reply().Double(f)
+}
+
+func (cmd *Command) writeVerbatim(s string) {
+	cmd.reply().Verbatim("txt", s)
+}
+
+func (cmd *Command) writeArray(items []string) {
+	cmd.reply().Array(items)
+}
+
+// Start an array whose n elements are written next
+func (cmd *Command) writeArrayLen(n int) {
+	cmd.reply().ArrayHeader(n)
+}
+
+// Key, value, key, value...
+func (cmd *Command) writeMap(pairs []string) {
+	cmd.reply().Map(pairs)
+}
+
+// Start a map whose n key-value pairs are written next
+func (cmd *Command) writeMapLen(n int) {
+	cmd.reply().MapHeader(n)
+}
+
+func (cmd *Command) writeSet(members []string) {
+	cmd.reply().Set(members)
+}
+
+// Start a set whose n members are written next
+func (cmd *Command) writeSetLen(n int) {
+	cmd.reply().SetHeader(n)
+}
+
+// Start an out-of-band message whose n elements are written next
+func (cmd *Command) writePushLen(n int) {
+	cmd.reply().PushHeader(n)
+}
+
+func (cmd *Command) writeNilArray() {
+	cmd.reply().NullArray()
+}
@@ -0,0 +1,102 @@
+package command
+
+import (
+	"net"
+	"sync"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/acl"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/aof"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/config"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/logger"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/pubsub"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/rdb"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/replication"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+)
+
+// Everything the handlers share across sessions
+type Server struct {
+	Logger *logger.Logger
+	Store  *store.InMemoryStore
+	RDB    *rdb.Snapshotter
+	AOF    *aof.Log // Nil when appendonly is off
+
+	Replication *replication.Manager
+	PubSub      *pubsub.Hub
+	ACL         *acl.ACL
+	Config      *config.Config
+
+	mu sync.Mutex // Commands run one at a time, like the single thread of Redis
+
+	clients map[*Client]struct{} // Every connected client
+	waiters map[string][]*waiter // Clients blocked on each key, in the order they arrived
+	txn     *txnState            // Set while EXEC runs
+	stats   serverStats
+}
+
+// Hold off commands, e.g. to touch the keyspace from outside a session
+func (srv *Server) Lock() {
+	srv.mu.Lock()
+}
+
+func (srv *Server) Unlock() {
+	srv.mu.Unlock()
+}
+
+// Hand a write command over to the AOF and our followers
+// Commands without a client were either replayed from the AOF
+// or already forwarded byte for byte from our leader's stream so they are not fed again
+func (srv *Server) propagate(args []string, client *Client) {
+	srv.emit(args, client != nil)
+}
+
+// Same as propagate, feed tells whether our followers get the command too
+func (srv *Server) emit(args []string, feed bool) {
+	// The first write of a transaction opens it, EXEC closes it once all of them are in
+	if srv.txn != nil && !srv.txn.propagated {
+		srv.txn.propagated = true
+		srv.emit([]string{MULTI}, feed)
+	}
+	if srv.AOF != nil {
+		if err := srv.AOF.Append(args); err != nil {
+			srv.Logger.Error(err, map[string]string{"command": args[0]})
+		}
+	}
+	if feed {
+		srv.Replication.Feed(args)
+	}
+}
+
+// Delete the keys the store found expired on our followers and in the AOF too
+// Followers never reclaim keys themselves, they wait for these DELs
+func (srv *Server) propagateExpired(feed bool) {
+	for _, key := range srv.Store.Reclaimed() {
+		srv.emit([]string{DEL, key}, feed)
+	}
+}
+
+// Make room under maxmemory before a write
+// Evicted keys are deleted on our followers and in the AOF too
+// Return false if memory is still over the limit
+func (srv *Server) evict(client *Client) bool {
+	keys, ok := srv.Store.Evict()
+	for _, key := range keys {
+		srv.propagate([]string{DEL, key}, client)
+	}
+	return ok
+}
+
+type txnState struct {
+	propagated bool // Whether MULTI was already sent to the AOF and followers
+}
+
+// Collect everything a handler writes so it can be sent in one go
+type replyBuffer struct {
+	net.Conn
+	buf []byte
+}
+
+func (r *replyBuffer) Write(b []byte) (int, error) {
+	r.buf = append(r.buf, b...)
+	return len(b), nil
+}
@@ -0,0 +1,338 @@
+package command
+
+import (
+	"strconv"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+)
+
+const (
+	SADD        = "SADD"
+	SREM        = "SREM"
+	SMEMBERS    = "SMEMBERS"
+	SISMEMBER   = "SISMEMBER"
+	SCARD       = "SCARD"
+	SINTER      = "SINTER"
+	SUNION      = "SUNION"
+	SDIFF       = "SDIFF"
+	SINTERSTORE = "SINTERSTORE"
+	SUNIONSTORE = "SUNIONSTORE"
+	SDIFFSTORE  = "SDIFFSTORE"
+	SRANDMEMBER = "SRANDMEMBER"
+	SPOP        = "SPOP"
+)
+
+// Set stored at key, nil if there is none
+// Reply with WRONGTYPE and return false if the key holds something else
+func (cmd *Command) lookupSet(db *store.InMemoryStore, key string) (*store.Set, bool) {
+	val, ok := db.Get(key)
+	if !ok {
+		return nil, true
+	}
+	s, ok := val.(*store.Set)
+	if !ok {
+		cmd.writeError(WRONGTYPE)
+		return nil, false
+	}
+	return s, true
+}
+
+// SADD key member [member ...]
+func (cmd *Command) sadd(db *store.InMemoryStore) bool {
+	key := cmd.Args[1]
+	s, ok := cmd.lookupSet(db, key)
+	if !ok {
+		return true
+	}
+	if s == nil {
+		s = store.NewSet()
+		db.Set(key, s)
+	}
+	added := 0
+	for _, m := range cmd.Args[2:] {
+		if s.Add(m) {
+			added++
+		}
+	}
+	if added > 0 {
+		db.Modified(key)
+	}
+	cmd.writeInt(int64(added))
+	return true
+}
+
+// SREM key member [member ...]
+func (cmd *Command) srem(db *store.InMemoryStore) bool {
+	key := cmd.Args[1]
+	s, ok := cmd.lookupSet(db, key)
+	if !ok {
+		return true
+	}
+	if s == nil {
+		cmd.writeInt(0)
+		return true
+	}
+	removed := 0
+	for _, m := range cmd.Args[2:] {
+		if s.Remove(m) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		cmd.setChanged(db, key, s)
+	}
+	cmd.writeInt(int64(removed))
+	return true
+}
+
+// SMEMBERS key
+func (cmd *Command) smembers(db *store.InMemoryStore) bool {
+	s, ok := cmd.lookupSet(db, cmd.Args[1])
+	if !ok {
+		return true
+	}
+	if s == nil {
+		cmd.writeSet(nil)
+		return true
+	}
+	cmd.writeSet(s.Members())
+	return true
+}
+
+// SISMEMBER key member
+func (cmd *Command) sismember(db *store.InMemoryStore) bool {
+	s, ok := cmd.lookupSet(db, cmd.Args[1])
+	if !ok {
+		return true
+	}
+	if s != nil && s.Has(cmd.Args[2]) {
+		cmd.writeInt(1)
+	} else {
+		cmd.writeInt(0)
+	}
+	return true
+}
+
+// SCARD key
+func (cmd *Command) scard(db *store.InMemoryStore) bool {
+	s, ok := cmd.lookupSet(db, cmd.Args[1])
+	if !ok {
+		return true
+	}
+	if s == nil {
+		cmd.writeInt(0)
+		return true
+	}
+	cmd.writeInt(int64(s.Len()))
+	return true
+}
+
+// SINTER/SUNION/SDIFF key [key ...]
+// The STORE variants take the destination first, write the result there and reply with its size
+func (cmd *Command) setAlgebra(db *store.InMemoryStore, op string, toStore bool) bool {
+	first := 1
+	if toStore {
+		first = 2
+	}
+	// Check every key before computing anything so a WRONGTYPE never leaves half a result
+	keys := cmd.Args[first:]
+	sets := make([]*store.Set, len(keys))
+	for i, key := range keys {
+		s, ok := cmd.lookupSet(db, key)
+		if !ok {
+			return true
+		}
+		sets[i] = s
+	}
+
+	var result *store.Set
+	switch op {
+	case SINTER:
+		result = intersect(sets)
+	case SUNION:
+		result = union(sets)
+	case SDIFF:
+		result = diff(sets)
+	}
+
+	if !toStore {
+		cmd.writeSet(result.Members())
+		return true
+	}
+	destination := cmd.Args[1]
+	if result.Len() == 0 {
+		db.Delete(destination)
+	} else {
+		db.Set(destination, result)
+	}
+	cmd.writeInt(int64(result.Len()))
+	return true
+}
+
+// Missing keys count as empty sets
+func intersect(sets []*store.Set) *store.Set {
+	result := store.NewSet()
+	smallest := sets[0]
+	for _, s := range sets {
+		if s == nil {
+			return result
+		}
+		if s.Len() < smallest.Len() {
+			smallest = s
+		}
+	}
+	// Walking the smallest set keeps it O(N*M) with N as small as possible
+	for _, m := range smallest.Members() {
+		inAll := true
+		for _, s := range sets {
+			if !s.Has(m) {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			result.Add(m)
+		}
+	}
+	return result
+}
+
+func union(sets []*store.Set) *store.Set {
+	result := store.NewSet()
+	for _, s := range sets {
+		if s == nil {
+			continue
+		}
+		for _, m := range s.Members() {
+			result.Add(m)
+		}
+	}
+	return result
+}
+
+// Members of the first set that are in none of the others
+func diff(sets []*store.Set) *store.Set {
+	result := store.NewSet()
+	if sets[0] == nil {
+		return result
+	}
+	for _, m := range sets[0].Members() {
+		found := false
+		for _, s := range sets[1:] {
+			if s != nil && s.Has(m) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result.Add(m)
+		}
+	}
+	return result
+}
+
+// SRANDMEMBER key [count]
+// A positive count returns distinct members, a negative one may repeat them
+func (cmd *Command) srandmember(db *store.InMemoryStore) bool {
+	if len(cmd.Args) > 3 {
+		cmd.writeArityError()
+		return true
+	}
+	var count int
+	if len(cmd.Args) == 3 {
+		n, err := strconv.Atoi(cmd.Args[2])
+		if err != nil {
+			cmd.writeError(NOT_INTEGER)
+			return true
+		}
+		count = n
+	}
+
+	s, ok := cmd.lookupSet(db, cmd.Args[1])
+	if !ok {
+		return true
+	}
+	if len(cmd.Args) == 2 {
+		if s == nil {
+			cmd.writeNil()
+			return true
+		}
+		cmd.writeBulk(s.Random())
+		return true
+	}
+
+	if s == nil || count == 0 {
+		cmd.writeArray(nil)
+		return true
+	}
+	if count > 0 {
+		cmd.writeArray(s.RandomDistinct(count))
+		return true
+	}
+	picked := make([]string, -count)
+	for i := range picked {
+		picked[i] = s.Random()
+	}
+	cmd.writeArray(picked)
+	return true
+}
+
+// SPOP key [count]
+func (cmd *Command) spop(db *store.InMemoryStore) bool {
+	if len(cmd.Args) > 3 {
+		cmd.writeArityError()
+		return true
+	}
+	count := -1 // No count means a single bulk reply instead of an array
+	if len(cmd.Args) == 3 {
+		n, err := strconv.Atoi(cmd.Args[2])
+		if err != nil || n < 0 {
+			cmd.writeError(NOT_POSITIVE)
+			return true
+		}
+		count = n
+	}
+
+	key := cmd.Args[1]
+	s, ok := cmd.lookupSet(db, key)
+	if !ok {
+		return true
+	}
+	if s == nil {
+		if count < 0 {
+			cmd.writeNil()
+		} else {
+			cmd.writeArray(nil)
+		}
+		return true
+	}
+
+	popped := s.RandomDistinct(max(count, 1))
+	if count == 0 {
+		popped = nil
+	}
+	for _, m := range popped {
+		s.Remove(m)
+	}
+	if len(popped) > 0 {
+		cmd.setChanged(db, key, s)
+		// Followers must remove the very same members
+		cmd.rewrite = append([]string{SREM, key}, popped...)
+	}
+
+	if count < 0 {
+		cmd.writeBulk(popped[0])
+	} else {
+		cmd.writeArray(popped)
+	}
+	return true
+}
+
+// Record a change to the set and drop the key once the set is empty
+func (cmd *Command) setChanged(db *store.InMemoryStore, key string, s *store.Set) {
+	if s.Len() == 0 {
+		db.Delete(key)
+		return
+	}
+	db.Modified(key)
+}
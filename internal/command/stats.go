@@ -0,0 +1,85 @@
+package command
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Counters INFO reports, zeroed by CONFIG RESETSTAT
+// Only touched with srv.mu held
+type serverStats struct {
+	connections uint64 // Clients accepted
+	commands    uint64 // Commands that ran
+	hits        uint64 // Keys read commands found
+	misses      uint64 // Keys read commands did not find
+	perCommand  map[string]*commandStats
+}
+
+// What INFO commandstats reports about a single command
+type commandStats struct {
+	calls    uint64
+	duration time.Duration // Spent in the handler, not waiting for a blocking command to be served
+	rejected uint64        // Refused before running, e.g. denied by an ACL rule or over maxmemory
+	failed   uint64        // Ran and replied with an error
+}
+
+func (st *serverStats) of(spec *commandSpec) *commandStats {
+	if st.perCommand == nil {
+		st.perCommand = make(map[string]*commandStats)
+	}
+	cs, ok := st.perCommand[spec.name]
+	if !ok {
+		cs = &commandStats{}
+		st.perCommand[spec.name] = cs
+	}
+	return cs
+}
+
+func (st *serverStats) called(spec *commandSpec, duration time.Duration, failed bool) {
+	st.commands++
+	cs := st.of(spec)
+	cs.calls++
+	cs.duration += duration
+	if failed {
+		cs.failed++
+	}
+}
+
+func (st *serverStats) rejected(spec *commandSpec) {
+	st.of(spec).rejected++
+}
+
+// Count the keys a read command asks for that exist, like Redis does on every read lookup
+func (srv *Server) lookedUp(keys []string) {
+	for _, key := range keys {
+		if _, ok := srv.Store.ExpireAt(key); ok {
+			srv.stats.hits++
+		} else {
+			srv.stats.misses++
+		}
+	}
+}
+
+// One line per command that was called or rejected, sorted by name
+func (srv *Server) commandStats() []string {
+	var names []string
+	for name := range srv.stats.perCommand {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var lines []string
+	for _, name := range names {
+		cs := srv.stats.perCommand[name]
+		usec := cs.duration.Microseconds()
+		perCall := 0.0
+		if cs.calls > 0 {
+			perCall = float64(cs.duration) / float64(time.Microsecond) / float64(cs.calls)
+		}
+		lines = append(lines, fmt.Sprintf("cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f,rejected_calls=%d,failed_calls=%d",
+			strings.ToLower(name), cs.calls, usec, perCall, cs.rejected, cs.failed))
+	}
+	return lines
+}
@@ -0,0 +1,70 @@
+package command
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCommandStats(t *testing.T) {
+	srv := newTestServer()
+	for _, args := range [][]string{
+		{"SET", "a", "1"},
+		{"GET", "a"},
+		{"GET", "b"},
+		{"LLEN", "a"},
+		{"GET", "a", "b"},
+	} {
+		runCommand(srv, args...)
+	}
+
+	if srv.stats.commands != 4 {
+		t.Errorf("Expected 4 commands, got %d", srv.stats.commands)
+	}
+	// LLEN found the key even though it holds a string
+	if srv.stats.hits != 2 || srv.stats.misses != 1 {
+		t.Errorf("Expected 2 hits and 1 miss, got %d and %d", srv.stats.hits, srv.stats.misses)
+	}
+	lines := srv.commandStats()
+	var names []string
+	for _, line := range lines {
+		name, _, _ := strings.Cut(line, ":")
+		names = append(names, name)
+	}
+	if !slices.Equal(names, []string{"cmdstat_get", "cmdstat_llen", "cmdstat_set"}) {
+		t.Fatalf("Expected get, llen and set, got %q", names)
+	}
+	if !strings.Contains(lines[0], "calls=2,") || !strings.HasSuffix(lines[0], "rejected_calls=1,failed_calls=0") {
+		t.Errorf("Expected 2 calls and 1 rejected, got %s", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "rejected_calls=0,failed_calls=1") {
+		t.Errorf("Expected 1 failed call, got %s", lines[1])
+	}
+
+	srv.resetStats()
+	if srv.stats.commands != 0 || len(srv.commandStats()) != 0 {
+		t.Errorf("Expected the stats to be reset")
+	}
+}
+
+func TestBytesToHuman(t *testing.T) {
+	tests := []struct {
+		n        uint64
+		expected string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1536, "1.50K"},
+		{100 << 20, "100.00M"},
+		{3 << 40, "3.00T"},
+		{2048 << 50, "2048.00P"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := bytesToHuman(tt.n); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
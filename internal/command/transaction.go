@@ -0,0 +1,145 @@
+package command
+
+const (
+	MULTI   = "MULTI"
+	EXEC    = "EXEC"
+	DISCARD = "DISCARD"
+	WATCH   = "WATCH"
+	UNWATCH = "UNWATCH"
+)
+
+// Commands that run right away even between MULTI and EXEC
+var transactionCommands = map[string]bool{
+	MULTI: true, EXEC: true, DISCARD: true, WATCH: true, QUIT: true,
+}
+
+// Whether the client sent MULTI and its commands are being queued
+func (c *Client) inMulti() bool {
+	return c != nil && c.multi != nil
+}
+
+// MULTI
+func (cmd *Command) multi() bool {
+	if cmd.Client == nil {
+		return true
+	}
+	if cmd.Client.inMulti() {
+		cmd.writeError("ERR MULTI calls can not be nested")
+		return true
+	}
+	cmd.Client.multi = []Command{}
+	cmd.Client.multiFailed = false
+	cmd.writeOK()
+	return true
+}
+
+// EXEC
+// Run every queued command with no other command in between,
+// unless a watched key changed since WATCH, in which case nothing runs
+func (cmd *Command) execTransaction(srv *Server) bool {
+	c := cmd.Client
+	if !c.inMulti() {
+		cmd.writeError("ERR EXEC without MULTI")
+		return true
+	}
+	queued := c.multi
+	c.multi = nil
+	defer srv.unwatchAll(c)
+	if c.multiFailed {
+		cmd.writeError("EXECABORT Transaction discarded because of previous errors.")
+		return true
+	}
+
+	for key, version := range c.watched {
+		if srv.Store.Version(key) != version {
+			cmd.writeNilArray()
+			return true
+		}
+	}
+
+	cmd.writeArrayLen(len(queued))
+	for i := range queued {
+		queued[i].Conn = cmd.Conn
+	}
+	srv.transaction(queued, c)
+	return true
+}
+
+// DISCARD
+func (cmd *Command) discard(srv *Server) bool {
+	if !cmd.Client.inMulti() {
+		cmd.writeError("ERR DISCARD without MULTI")
+		return true
+	}
+	cmd.Client.multi = nil
+	srv.unwatchAll(cmd.Client)
+	cmd.writeOK()
+	return true
+}
+
+// WATCH key [key ...]
+func (cmd *Command) watch(srv *Server) bool {
+	c := cmd.Client
+	if c == nil {
+		return true
+	}
+	if c.inMulti() {
+		cmd.writeError("ERR WATCH inside MULTI is not allowed")
+		return true
+	}
+	if c.watched == nil {
+		c.watched = make(map[string]uint64)
+	}
+	for _, key := range cmd.Args[1:] {
+		if _, ok := c.watched[key]; !ok {
+			c.watched[key] = srv.Store.Watch(key)
+		}
+	}
+	cmd.writeOK()
+	return true
+}
+
+// UNWATCH
+func (cmd *Command) unwatch(srv *Server) bool {
+	if cmd.Client != nil {
+		srv.unwatchAll(cmd.Client)
+	}
+	cmd.writeOK()
+	return true
+}
+
+func (srv *Server) unwatchAll(c *Client) {
+	for key := range c.watched {
+		srv.Store.Unwatch(key)
+	}
+	c.watched = nil
+}
+
+// Run the commands one after the other while holding srv.mu
+// Their writes reach the AOF and our followers wrapped in MULTI/EXEC so they are replayed atomically too
+func (srv *Server) transaction(cmds []Command, client *Client) {
+	srv.txn = &txnState{}
+	var keys []string
+	for i := range cmds {
+		cmd := &cmds[i]
+		cmd.inTransaction = true
+		cmd.run(srv)
+		if spec, errMsg := lookupCommand(cmd.Args); errMsg == "" {
+			keys = append(keys, spec.keysOf(cmd.Args)...)
+		}
+	}
+	if srv.txn.propagated {
+		srv.propagate([]string{EXEC}, client)
+	}
+	srv.txn = nil
+
+	// Clients blocked on the keys we pushed to are only served once the transaction is over
+	srv.serveBlocked(keys)
+}
+
+// Run a MULTI/EXEC block read from the AOF or our leader's stream
+func Transaction(srv *Server, cmds []Command) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.transaction(cmds, nil)
+}
@@ -0,0 +1,503 @@
+package command
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+)
+
+const (
+	ZADD             = "ZADD"
+	ZINCRBY          = "ZINCRBY"
+	ZREM             = "ZREM"
+	ZSCORE           = "ZSCORE"
+	ZCARD            = "ZCARD"
+	ZRANK            = "ZRANK"
+	ZREVRANK         = "ZREVRANK"
+	ZRANGE           = "ZRANGE"
+	ZREVRANGE        = "ZREVRANGE"
+	ZRANGEBYSCORE    = "ZRANGEBYSCORE"
+	ZREVRANGEBYSCORE = "ZREVRANGEBYSCORE"
+	ZRANGEBYLEX      = "ZRANGEBYLEX"
+	ZPOPMIN          = "ZPOPMIN"
+	ZPOPMAX          = "ZPOPMAX"
+)
+
+const NOT_FLOAT = "ERR value is not a valid float"
+
+// How ZRANGE interprets its start and stop arguments
+type zrangeKind int
+
+const (
+	BY_RANK zrangeKind = iota
+	BY_SCORE
+	BY_LEX
+)
+
+// Sorted set stored at key, nil if there is none
+// Reply with WRONGTYPE and return false if the key holds something else
+func (cmd *Command) lookupZSet(db *store.InMemoryStore, key string) (*store.ZSet, bool) {
+	val, ok := db.Get(key)
+	if !ok {
+		return nil, true
+	}
+	z, ok := val.(*store.ZSet)
+	if !ok {
+		cmd.writeError(WRONGTYPE)
+		return nil, false
+	}
+	return z, true
+}
+
+// ZADD key [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...]
+func (cmd *Command) zadd(db *store.InMemoryStore) bool {
+	var nx, xx, gt, lt, ch, incr bool
+	i := 2
+flags:
+	for ; i < len(cmd.Args); i++ {
+		switch strings.ToUpper(cmd.Args[i]) {
+		case "NX":
+			nx = true
+		case XX:
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		case "INCR":
+			incr = true
+		default:
+			break flags
+		}
+	}
+
+	pairs := cmd.Args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		cmd.writeError(SYNTAX_ERROR)
+		return true
+	}
+	if nx && xx {
+		cmd.writeError("ERR XX and NX options at the same time are not compatible")
+		return true
+	}
+	if (gt && lt) || (nx && (gt || lt)) {
+		cmd.writeError("ERR GT, LT, and/or NX options at the same time are not compatible")
+		return true
+	}
+	if incr && len(pairs) > 2 {
+		cmd.writeError("ERR INCR option supports a single increment-element pair")
+		return true
+	}
+	scores := make([]float64, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, ok := parseScore(pairs[j])
+		if !ok {
+			cmd.writeError(NOT_FLOAT)
+			return true
+		}
+		scores = append(scores, score)
+	}
+
+	key := cmd.Args[1]
+	z, ok := cmd.lookupZSet(db, key)
+	if !ok {
+		return true
+	}
+
+	added, changed := 0, 0
+	var result float64
+	updated := false // Whether the INCR member was actually touched
+	for j, score := range scores {
+		member := pairs[2*j+1]
+		var current float64
+		var exists bool
+		if z != nil {
+			current, exists = z.Score(member)
+		}
+		if (nx && exists) || (xx && !exists) {
+			continue
+		}
+		if incr {
+			score += current
+			if math.IsNaN(score) {
+				cmd.writeError("ERR resulting score is not a number (NaN)")
+				return true
+			}
+		}
+		if exists && ((gt && score <= current) || (lt && score >= current)) {
+			continue
+		}
+
+		if z == nil {
+			z = store.NewZSet()
+			db.Set(key, z)
+		}
+		if !exists {
+			added++
+		} else if score != current {
+			changed++
+		}
+		z.Add(member, score)
+		result, updated = score, true
+	}
+	if added+changed > 0 {
+		db.Modified(key)
+	}
+
+	if incr {
+		if !updated {
+			cmd.writeNil()
+			return true
+		}
+		cmd.writeDouble(result)
+		return true
+	}
+	if ch {
+		cmd.writeInt(int64(added + changed))
+		return true
+	}
+	cmd.writeInt(int64(added))
+	return true
+}
+
+// ZINCRBY key increment member
+func (cmd *Command) zincrby(db *store.InMemoryStore) bool {
+	incr, ok := parseScore(cmd.Args[2])
+	if !ok {
+		cmd.writeError(NOT_FLOAT)
+		return true
+	}
+	key, member := cmd.Args[1], cmd.Args[3]
+	z, ok := cmd.lookupZSet(db, key)
+	if !ok {
+		return true
+	}
+	if z == nil {
+		z = store.NewZSet()
+		db.Set(key, z)
+	}
+	current, _ := z.Score(member)
+	score := current + incr
+	if math.IsNaN(score) {
+		cmd.writeError("ERR resulting score is not a number (NaN)")
+		return true
+	}
+	z.Add(member, score)
+	db.Modified(key)
+	cmd.writeDouble(score)
+	return true
+}
+
+// ZREM key member [member ...]
+func (cmd *Command) zrem(db *store.InMemoryStore) bool {
+	key := cmd.Args[1]
+	z, ok := cmd.lookupZSet(db, key)
+	if !ok {
+		return true
+	}
+	if z == nil {
+		cmd.writeInt(0)
+		return true
+	}
+	removed := 0
+	for _, member := range cmd.Args[2:] {
+		if z.Remove(member) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		cmd.zsetChanged(db, key, z)
+	}
+	cmd.writeInt(int64(removed))
+	return true
+}
+
+// ZSCORE key member
+func (cmd *Command) zscore(db *store.InMemoryStore) bool {
+	z, ok := cmd.lookupZSet(db, cmd.Args[1])
+	if !ok {
+		return true
+	}
+	if z == nil {
+		cmd.writeNil()
+		return true
+	}
+	score, ok := z.Score(cmd.Args[2])
+	if !ok {
+		cmd.writeNil()
+		return true
+	}
+	cmd.writeDouble(score)
+	return true
+}
+
+// ZCARD key
+func (cmd *Command) zcard(db *store.InMemoryStore) bool {
+	z, ok := cmd.lookupZSet(db, cmd.Args[1])
+	if !ok {
+		return true
+	}
+	if z == nil {
+		cmd.writeInt(0)
+		return true
+	}
+	cmd.writeInt(int64(z.Len()))
+	return true
+}
+
+// ZRANK/ZREVRANK key member [WITHSCORE]
+func (cmd *Command) zrank(db *store.InMemoryStore, reverse bool) bool {
+	if len(cmd.Args) > 4 {
+		cmd.writeArityError()
+		return true
+	}
+	withScore := len(cmd.Args) == 4
+	if withScore && !strings.EqualFold(cmd.Args[3], "WITHSCORE") {
+		cmd.writeError(SYNTAX_ERROR)
+		return true
+	}
+	z, ok := cmd.lookupZSet(db, cmd.Args[1])
+	if !ok {
+		return true
+	}
+	var rank int
+	var found bool
+	if z != nil {
+		rank, found = z.Rank(cmd.Args[2], reverse)
+	}
+	if !found {
+		if withScore {
+			cmd.writeNilArray()
+		} else {
+			cmd.writeNil()
+		}
+		return true
+	}
+	if !withScore {
+		cmd.writeInt(int64(rank))
+		return true
+	}
+	score, _ := z.Score(cmd.Args[2])
+	cmd.writeArrayLen(2)
+	cmd.writeInt(int64(rank))
+	cmd.writeDouble(score)
+	return true
+}
+
+// ZRANGE key start stop [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+// ZREVRANGE, ZRANGEBYSCORE, ZREVRANGEBYSCORE and ZRANGEBYLEX are the same with the kind and direction fixed
+func (cmd *Command) zrange(db *store.InMemoryStore, kind zrangeKind, reverse bool, fixed bool) bool {
+	var withScores, limit bool
+	offset, count := 0, -1
+	for i := 4; i < len(cmd.Args); i++ {
+		switch option := strings.ToUpper(cmd.Args[i]); {
+		case option == "WITHSCORES":
+			withScores = true
+		case option == "LIMIT" && i+2 < len(cmd.Args):
+			var err1, err2 error
+			offset, err1 = strconv.Atoi(cmd.Args[i+1])
+			count, err2 = strconv.Atoi(cmd.Args[i+2])
+			if err1 != nil || err2 != nil {
+				cmd.writeError(NOT_INTEGER)
+				return true
+			}
+			limit = true
+			i += 2
+		case option == "BYSCORE" && !fixed:
+			kind = BY_SCORE
+		case option == "BYLEX" && !fixed:
+			kind = BY_LEX
+		case option == "REV" && !fixed:
+			reverse = true
+		default:
+			cmd.writeError(SYNTAX_ERROR)
+			return true
+		}
+	}
+	if limit && kind == BY_RANK {
+		cmd.writeError("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+		return true
+	}
+	if withScores && kind == BY_LEX {
+		cmd.writeError("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+		return true
+	}
+
+	// Score and lex ranges are given from max to min when reversed
+	lo, hi := cmd.Args[2], cmd.Args[3]
+	if reverse && kind != BY_RANK {
+		lo, hi = hi, lo
+	}
+	var r store.ZRange
+	switch kind {
+	case BY_SCORE:
+		sr, ok := parseScoreRange(lo, hi)
+		if !ok {
+			cmd.writeError("ERR min or max is not a float")
+			return true
+		}
+		r = sr
+	case BY_LEX:
+		lr, ok := parseLexRange(lo, hi)
+		if !ok {
+			cmd.writeError("ERR min or max not valid string range item")
+			return true
+		}
+		r = lr
+	}
+	start, err1 := strconv.Atoi(cmd.Args[2])
+	stop, err2 := strconv.Atoi(cmd.Args[3])
+	if kind == BY_RANK && (err1 != nil || err2 != nil) {
+		cmd.writeError(NOT_INTEGER)
+		return true
+	}
+
+	z, ok := cmd.lookupZSet(db, cmd.Args[1])
+	if !ok {
+		return true
+	}
+	if z == nil || offset < 0 {
+		cmd.writeArray(nil)
+		return true
+	}
+	var entries []store.ZEntry
+	if kind == BY_RANK {
+		start, stop = clampRange(start, stop, z.Len())
+		entries = z.RangeByRank(start, stop, reverse)
+	} else {
+		entries = z.RangeBy(r, reverse, offset, count)
+	}
+	cmd.writeEntries(entries, withScores)
+	return true
+}
+
+// ZPOPMIN/ZPOPMAX key [count]
+func (cmd *Command) zpop(db *store.InMemoryStore, highest bool) bool {
+	if len(cmd.Args) > 3 {
+		cmd.writeArityError()
+		return true
+	}
+	count := 1
+	if len(cmd.Args) == 3 {
+		n, err := strconv.Atoi(cmd.Args[2])
+		if err != nil || n < 0 {
+			cmd.writeError(NOT_POSITIVE)
+			return true
+		}
+		count = n
+	}
+
+	key := cmd.Args[1]
+	z, ok := cmd.lookupZSet(db, key)
+	if !ok {
+		return true
+	}
+	if z == nil || count == 0 {
+		cmd.writeArray(nil)
+		return true
+	}
+	popped := z.RangeByRank(0, min(count, z.Len())-1, highest)
+	for _, e := range popped {
+		z.Remove(e.Member)
+	}
+	cmd.zsetChanged(db, key, z)
+	// Without a count there is a single member, its score follows it even in RESP3
+	if len(cmd.Args) == 2 {
+		cmd.writeArrayLen(2)
+		cmd.writeBulk(popped[0].Member)
+		cmd.writeDouble(popped[0].Score)
+		return true
+	}
+	cmd.writeEntries(popped, true)
+	return true
+}
+
+// Members with their scores interleaved when asked to
+// RESP3 clients get a [member, score] pair for each instead
+func (cmd *Command) writeEntries(entries []store.ZEntry, withScores bool) {
+	if !withScores {
+		members := make([]string, 0, len(entries))
+		for _, e := range entries {
+			members = append(members, e.Member)
+		}
+		cmd.writeArray(members)
+		return
+	}
+	pairs := cmd.protocol() == resp.RESP3
+	if pairs {
+		cmd.writeArrayLen(len(entries))
+	} else {
+		cmd.writeArrayLen(2 * len(entries))
+	}
+	for _, e := range entries {
+		if pairs {
+			cmd.writeArrayLen(2)
+		}
+		cmd.writeBulk(e.Member)
+		cmd.writeDouble(e.Score)
+	}
+}
+
+// Record a change to the sorted set and drop the key once it is empty
+func (cmd *Command) zsetChanged(db *store.InMemoryStore, key string, z *store.ZSet) {
+	if z.Len() == 0 {
+		db.Delete(key)
+		return
+	}
+	db.Modified(key)
+}
+
+// Scores are doubles, inf and -inf included but not NaN
+func parseScore(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, false
+	}
+	return f, true
+}
+
+// Bounds are inclusive unless prefixed with (, e.g. (1.5 or -inf
+func parseScoreRange(min, max string) (store.ScoreRange, bool) {
+	var r store.ScoreRange
+	var ok1, ok2 bool
+	r.Min, r.MinExclusive, ok1 = parseScoreBound(min)
+	r.Max, r.MaxExclusive, ok2 = parseScoreBound(max)
+	return r, ok1 && ok2
+}
+
+func parseScoreBound(s string) (float64, bool, bool) {
+	exclusive := strings.HasPrefix(s, "(")
+	if exclusive {
+		s = s[1:]
+	}
+	f, ok := parseScore(s)
+	return f, exclusive, ok
+}
+
+// Bounds are - or + for the extremes, otherwise [ for inclusive or ( for exclusive followed by the value
+func parseLexRange(min, max string) (store.LexRange, bool) {
+	var r store.LexRange
+	var ok1, ok2 bool
+	r.Min, ok1 = parseLexBound(min)
+	r.Max, ok2 = parseLexBound(max)
+	return r, ok1 && ok2
+}
+
+func parseLexBound(s string) (store.LexBound, bool) {
+	switch {
+	case s == "-":
+		return store.LexBound{Inf: -1}, true
+	case s == "+":
+		return store.LexBound{Inf: 1}, true
+	case strings.HasPrefix(s, "["):
+		return store.LexBound{Value: s[1:]}, true
+	case strings.HasPrefix(s, "("):
+		return store.LexBound{Value: s[1:], Exclusive: true}, true
+	}
+	return store.LexBound{}, false
+}
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/helpers"
+)
+
+// Check a value and return it in the form CONFIG GET shows, e.g. bytes for a memory size
+// The error completes "argument must be ..." or similar
+type Parser func(s string) (string, error)
+
+// A setting of the server, the file, the command line and CONFIG SET refer to it by Name
+type Param struct {
+	Name    string
+	Parse   Parser
+	Default string
+	Mutable bool // Whether CONFIG SET may change it while the server runs
+	Usage   string
+}
+
+type param struct {
+	Param
+	value   string // As Parse returned it
+	raw     string // As it was given, so CONFIG REWRITE keeps 100mb rather than 104857600
+	initial string // Default as Parse returned it
+	apply   func(value string) error
+}
+
+// Every setting of the server and where they were loaded from
+type Config struct {
+	mu     sync.Mutex // Guard the fields below and the params
+	params []*param   // In the order they were declared, CONFIG REWRITE adds missing ones in it
+	byName map[string]*param
+	file   string // Empty unless settings were loaded from a file
+}
+
+func New(params []Param) *Config {
+	c := &Config{byName: make(map[string]*param, len(params))}
+	for _, p := range params {
+		value, err := p.Parse(p.Default)
+		if err != nil {
+			panic(fmt.Sprintf("config: bad default for %s: %v", p.Name, err))
+		}
+		param := &param{Param: p, value: value, raw: p.Default, initial: value}
+		c.params = append(c.params, param)
+		c.byName[p.Name] = param
+	}
+	return c
+}
+
+func Bool(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case "yes":
+		return "yes", nil
+	case "no":
+		return "no", nil
+	}
+	return "", fmt.Errorf("argument must be 'yes' or 'no'")
+}
+
+func String(s string) (string, error) {
+	return s, nil
+}
+
+// A size in bytes, with an optional unit like 100mb or 1g
+func Memory(s string) (string, error) {
+	n, err := helpers.ParseMemory(s)
+	if err != nil {
+		return "", fmt.Errorf("argument must be a memory value")
+	}
+	return strconv.FormatInt(n, 10), nil
+}
+
+func Int(min, max int64) Parser {
+	return func(s string) (string, error) {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("argument couldn't be parsed into an integer")
+		}
+		if n < min || n > max {
+			return "", fmt.Errorf("argument must be between %d and %d inclusive", min, max)
+		}
+		return strconv.FormatInt(n, 10), nil
+	}
+}
+
+func Enum(values ...string) Parser {
+	return func(s string) (string, error) {
+		lower := strings.ToLower(s)
+		if !slices.Contains(values, lower) {
+			return "", fmt.Errorf("argument(s) must be one of the following: %s", strings.Join(values, ", "))
+		}
+		return lower, nil
+	}
+}
+
+// Call apply with the new value whenever CONFIG SET changes the setting
+// so whatever depends on it changes too, an error leaves the setting as it was
+// apply runs while the settings are locked so it must not read them back
+func (c *Config) OnChange(name string, apply func(value string) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.byName[name].apply = apply
+}
+
+// The value of a setting, as CONFIG GET shows it
+func (c *Config) Get(name string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.byName[name].value
+}
+
+func (c *Config) GetBool(name string) bool {
+	return c.Get(name) == "yes"
+}
+
+func (c *Config) GetInt(name string) int64 {
+	n, _ := strconv.ParseInt(c.Get(name), 10, 64)
+	return n
+}
+
+// Path of the file the settings were loaded from, empty without one
+func (c *Config) File() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.file
+}
+
+// Name and value of every setting matching one of the glob patterns, sorted by name
+func (c *Config) Match(patterns ...string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var names []string
+	for name := range c.byName {
+		for _, pattern := range patterns {
+			if helpers.GlobMatch(strings.ToLower(pattern), name) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	slices.Sort(names)
+	pairs := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		pairs = append(pairs, name, c.byName[name].value)
+	}
+	return pairs
+}
+
+// Change settings given as name value pairs, like CONFIG SET
+// Either every setting changes or none does
+func (c *Config) Set(pairs ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(pairs)%2 != 0 {
+		return fmt.Errorf("wrong number of arguments for CONFIG SET")
+	}
+	type change struct {
+		p          *param
+		value, raw string
+	}
+	var changes []change
+	for i := 0; i < len(pairs); i += 2 {
+		name := strings.ToLower(pairs[i])
+		p, ok := c.byName[name]
+		if !ok {
+			return fmt.Errorf("Unknown option or number of arguments for CONFIG SET - '%s'", pairs[i])
+		}
+		if !p.Mutable {
+			return fmt.Errorf("CONFIG SET failed (possibly related to argument '%s') - can't set immutable config", name)
+		}
+		if slices.ContainsFunc(changes, func(ch change) bool { return ch.p == p }) {
+			return fmt.Errorf("CONFIG SET failed (possibly related to argument '%s') - duplicate parameter", name)
+		}
+		value, err := p.Parse(pairs[i+1])
+		if err != nil {
+			return fmt.Errorf("CONFIG SET failed (possibly related to argument '%s') - %s", name, err)
+		}
+		changes = append(changes, change{p, value, pairs[i+1]})
+	}
+
+	for i, ch := range changes {
+		if ch.p.apply != nil {
+			if err := ch.p.apply(ch.value); err != nil {
+				// Undo what was already applied
+				for _, done := range changes[:i] {
+					if done.p.apply != nil {
+						done.p.apply(done.p.value)
+					}
+				}
+				return fmt.Errorf("CONFIG SET failed (possibly related to argument '%s') - %s", ch.p.Name, err)
+			}
+		}
+	}
+	for _, ch := range changes {
+		ch.p.value, ch.p.raw = ch.value, ch.raw
+	}
+	return nil
+}
+
+// Set a setting before the server starts, when even immutable ones may change
+func (c *Config) set(name, raw string) error {
+	p, ok := c.byName[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown setting '%s'", name)
+	}
+	value, err := p.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %s", p.Name, err)
+	}
+	p.value, p.raw = value, raw
+	return nil
+}
+
+// Register a flag per setting, which overrides the file when the flags are parsed after it
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	for _, p := range c.params {
+		fs.Var(flagValue{c, p}, p.Name, p.Usage)
+	}
+}
+
+type flagValue struct {
+	c *Config
+	p *param
+}
+
+func (f flagValue) String() string {
+	if f.p == nil {
+		return "" // The zero value flag.PrintDefaults makes
+	}
+	return f.p.raw
+}
+
+func (f flagValue) Set(s string) error {
+	f.c.mu.Lock()
+	defer f.c.mu.Unlock()
+	// A boolean flag given without a value is set to true
+	if f.IsBoolFlag() {
+		switch s {
+		case "true":
+			s = "yes"
+		case "false":
+			s = "no"
+		}
+	}
+	return f.c.set(f.p.Name, s)
+}
+
+// Let -appendonly stand for -appendonly yes
+func (f flagValue) IsBoolFlag() bool {
+	return f.p != nil && reflect.ValueOf(f.p.Parse).Pointer() == reflect.ValueOf(Bool).Pointer()
+}
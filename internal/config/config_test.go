@@ -0,0 +1,159 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+var testParams = []Param{
+	{Name: "port", Parse: Int(0, 65535), Default: "6380"},
+	{Name: "appendonly", Parse: Bool, Default: "no"},
+	{Name: "loglevel", Parse: Enum("info", "error"), Default: "info", Mutable: true},
+	{Name: "maxmemory", Parse: Memory, Default: "0", Mutable: true},
+	{Name: "requirepass", Parse: String, Mutable: true},
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		name     string
+		pairs    []string
+		expected string // Empty when the settings change
+	}{
+		{"Mutable", []string{"loglevel", "ERROR"}, ""},
+		{"Several", []string{"maxmemory", "1kb", "requirepass", "secret"}, ""},
+		{"Unknown", []string{"nope", "1"}, "Unknown option or number of arguments for CONFIG SET - 'nope'"},
+		{"Immutable", []string{"port", "7000"}, "CONFIG SET failed (possibly related to argument 'port') - can't set immutable config"},
+		{"Duplicate", []string{"loglevel", "info", "LOGLEVEL", "error"}, "CONFIG SET failed (possibly related to argument 'loglevel') - duplicate parameter"},
+		{"Bad enum", []string{"loglevel", "debug"}, "CONFIG SET failed (possibly related to argument 'loglevel') - argument(s) must be one of the following: info, error"},
+		{"Bad memory", []string{"requirepass", "secret", "maxmemory", "lots"}, "CONFIG SET failed (possibly related to argument 'maxmemory') - argument must be a memory value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(testParams)
+			err := c.Set(tt.pairs...)
+			if tt.expected == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.expected {
+				t.Fatalf("Expected %q, got %v", tt.expected, err)
+			}
+			// Nothing changes when one of the settings is wrong
+			if got := c.Get("requirepass"); got != "" {
+				t.Errorf("Expected requirepass to be kept, got %q", got)
+			}
+		})
+	}
+}
+
+func TestOnChange(t *testing.T) {
+	c := New(testParams)
+	var applied []string
+	c.OnChange("loglevel", func(value string) error {
+		applied = append(applied, value)
+		return nil
+	})
+	c.OnChange("maxmemory", func(value string) error {
+		if value != "0" {
+			return errors.New("not enough memory")
+		}
+		return nil
+	})
+
+	if err := c.Set("loglevel", "error"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The failed change undoes the one applied before it
+	if err := c.Set("loglevel", "info", "maxmemory", "1mb"); err == nil {
+		t.Fatalf("Expected an error")
+	}
+	if !slices.Equal(applied, []string{"error", "info", "error"}) {
+		t.Errorf("Expected error, info then error, got %q", applied)
+	}
+	if got := c.Get("loglevel"); got != "error" {
+		t.Errorf("Expected error, got %s", got)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	c := New(testParams)
+	c.Set("maxmemory", "2kb")
+	expected := []string{"loglevel", "info", "maxmemory", "2048"}
+	if got := c.Match("MAX*", "loglevel", "max*"); !slices.Equal(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestFlags(t *testing.T) {
+	c := New(testParams)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	c.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-appendonly", "-port", "7000"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !c.GetBool("appendonly") || c.GetInt("port") != 7000 {
+		t.Errorf("Expected appendonly and port 7000, got %s and %s", c.Get("appendonly"), c.Get("port"))
+	}
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse([]string{"-port", "99999"}); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redis.conf")
+	c := New(testParams)
+	if err := c.Rewrite(); err != ErrNoFile {
+		t.Errorf("Expected %v, got %v", ErrNoFile, err)
+	}
+
+	original := "# Memory\nmaxmemory 100mb\n\n# Logging\nloglevel info\nloglevel error\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LoadFile(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := c.Get("loglevel"); got != "error" {
+		t.Errorf("Expected the last line to win, got %s", got)
+	}
+	if got := c.Get("maxmemory"); got != "104857600" {
+		t.Errorf("Expected 104857600, got %s", got)
+	}
+
+	c.Set("loglevel", "info", "requirepass", "a b")
+	if err := c.Rewrite(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rewritten, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# Memory\nmaxmemory 100mb\n\n# Logging\nloglevel info\n" +
+		REWRITE_SIGNATURE + "\nrequirepass \"a b\"\n"
+	if string(rewritten) != expected {
+		t.Errorf("Expected %q, got %q", expected, rewritten)
+	}
+
+	// The rewritten file reads back the same
+	c = New(testParams)
+	if err := c.LoadFile(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := c.Get("requirepass"); got != "a b" {
+		t.Errorf("Expected %q, got %q", "a b", got)
+	}
+
+	os.WriteFile(path, []byte("port 6380\nnope 1\n"), 0644)
+	if err := New(testParams).LoadFile(path); err == nil {
+		t.Errorf("Expected an error for the unknown setting")
+	}
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Comment above the settings CONFIG REWRITE had to add to the file
+const REWRITE_SIGNATURE = "# Generated by CONFIG REWRITE"
+
+var (
+	ErrNoFile          = errors.New("The server is running without a config file")
+	errBadDirective    = errors.New("Bad directive or wrong number of arguments")
+	errUnbalancedQuote = errors.New("Unbalanced quotes in configuration line")
+)
+
+// Read settings from a redis.conf style file, one "name value" per line
+// CONFIG REWRITE writes them back to it
+func (c *Config) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		args, err := splitLine(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		if len(args) < 2 {
+			return fmt.Errorf("%s:%d: %w", path, n, errBadDirective)
+		}
+		if err := c.set(args[0], strings.Join(args[1:], " ")); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	c.file = path
+	return nil
+}
+
+// Write the current settings to the file they were loaded from
+// Comments and unknown lines stay, settings missing from the file are only added if they changed
+func (c *Config) Rewrite() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == "" {
+		return ErrNoFile
+	}
+	data, err := os.ReadFile(c.file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	var lines []string
+	seen := make(map[string]bool)
+	signed := false
+	var existing []string
+	if content := strings.TrimRight(string(data), "\n"); content != "" {
+		existing = strings.Split(content, "\n")
+	}
+	for _, line := range existing {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == REWRITE_SIGNATURE {
+			signed = true
+		}
+		args, err := splitLine(trimmed)
+		if err != nil || len(args) == 0 || strings.HasPrefix(trimmed, "#") {
+			lines = append(lines, line)
+			continue
+		}
+		p, ok := c.byName[strings.ToLower(args[0])]
+		if !ok {
+			lines = append(lines, line)
+			continue
+		}
+		// The first line of a setting holds its value, repeating it would only confuse
+		if seen[p.Name] {
+			continue
+		}
+		seen[p.Name] = true
+		lines = append(lines, p.Name+" "+quote(p.raw))
+	}
+	for _, p := range c.params {
+		if seen[p.Name] || p.value == p.initial {
+			continue
+		}
+		if !signed {
+			lines = append(lines, REWRITE_SIGNATURE)
+			signed = true
+		}
+		lines = append(lines, p.Name+" "+quote(p.raw))
+	}
+	return writeFile(c.file, strings.Join(lines, "\n")+"\n")
+}
+
+// Replace the file in one go so a crash never leaves half a config behind
+func writeFile(path, content string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "temp-*.conf")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Split a line into arguments separated by spaces
+// Double or single quotes keep spaces in an argument, \" and \\ escape within double quotes
+func splitLine(line string) ([]string, error) {
+	var args []string
+	i := 0
+	for {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+
+		if q := line[i]; q == '"' || q == '\'' {
+			i++
+			var arg []byte
+			for i < len(line) && line[i] != q {
+				if q == '"' && line[i] == '\\' && i+1 < len(line) {
+					i++
+				}
+				arg = append(arg, line[i])
+				i++
+			}
+			if i == len(line) {
+				return nil, errUnbalancedQuote
+			}
+			i++
+			args = append(args, string(arg))
+			continue
+		}
+
+		start := i
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+		args = append(args, line[start:i])
+	}
+}
+
+// Quote a value when it would not read back as a single argument
+func quote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
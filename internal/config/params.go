@@ -0,0 +1,26 @@
+package config
+
+import "math"
+
+// Every setting the server knows, in the order CONFIG REWRITE appends them
+var Params = []Param{
+	{Name: "port", Parse: Int(0, 65535), Default: "6380", Usage: "port to listen on, 0 to only accept TLS connections"},
+	{Name: "tls-port", Parse: Int(0, 65535), Default: "0", Usage: "port to accept TLS connections on, 0 to disable TLS"},
+	{Name: "tls-cert-file", Parse: String, Usage: "certificate of the TLS port, in PEM"},
+	{Name: "tls-key-file", Parse: String, Usage: "private key of the TLS certificate, in PEM"},
+	{Name: "tls-ca-cert-file", Parse: String, Usage: "CA bundle that client and leader certificates are verified against, in PEM"},
+	{Name: "tls-auth-clients", Parse: Enum("no", "optional", "yes"), Default: "no", Usage: "whether TLS clients must present a certificate: yes, optional or no"},
+	{Name: "tls-replication", Parse: Bool, Default: "no", Usage: "follow leaders over TLS, announcing the TLS port"},
+	{Name: "loglevel", Parse: Enum("info", "error", "fatal", "off"), Default: "info", Mutable: true, Usage: "least severe level that is logged: info, error, fatal or off"},
+	{Name: "timeout", Parse: Int(0, math.MaxInt32), Default: "0", Mutable: true, Usage: "close a client after it is idle for this many seconds, 0 to never"},
+	{Name: "dbfilename", Parse: String, Default: "dump.rdb", Usage: "name of the snapshot file"},
+	{Name: "appendonly", Parse: Bool, Default: "no", Usage: "log every write command to an append only file"},
+	{Name: "appendfilename", Parse: String, Default: "appendonly.aof", Usage: "name of the append only file"},
+	{Name: "appendfsync", Parse: Enum("always", "everysec", "no"), Default: "everysec", Usage: "how often the append only file is synced to disk: always, everysec or no"},
+	{Name: "maxmemory", Parse: Memory, Default: "0", Mutable: true, Usage: "memory limit of the keyspace, e.g. 100mb, 0 for no limit"},
+	{Name: "maxmemory-policy", Parse: Enum("noeviction", "allkeys-lru", "allkeys-lfu", "allkeys-random", "volatile-lru", "volatile-lfu", "volatile-random", "volatile-ttl"), Default: "noeviction", Mutable: true, Usage: "how keys are evicted once maxmemory is reached"},
+	{Name: "requirepass", Parse: String, Mutable: true, Usage: "password of the default user, which otherwise needs none"},
+	{Name: "aclfile", Parse: String, Usage: "file to load users from, takes over requirepass"},
+	{Name: "masteruser", Parse: String, Mutable: true, Usage: "user to authenticate as with our leader"},
+	{Name: "masterauth", Parse: String, Mutable: true, Usage: "password to authenticate with our leader"},
+}
@@ -0,0 +1,86 @@
+package helpers
+
+// Match s against a glob-style pattern the way Redis does
+// * matches any sequence, ? any single byte, [abc] [^abc] [a-z] a class of bytes
+// and \ escapes the next character
+// On a mismatch only the last star is retried one byte further,
+// the earlier ones can't do better, so matching stays linear in practice
+func GlobMatch(pattern, s string) bool {
+	var starPattern, starS string // What follows the last star, and where in s it was tried
+	starred := false
+	for len(s) > 0 || len(pattern) > 0 {
+		if len(pattern) > 0 {
+			switch pattern[0] {
+			case '*':
+				// Consecutive stars are the same as one
+				for len(pattern) > 0 && pattern[0] == '*' {
+					pattern = pattern[1:]
+				}
+				if len(pattern) == 0 {
+					return true
+				}
+				starPattern, starS, starred = pattern, s, true
+				continue
+			case '?':
+				if len(s) > 0 {
+					s, pattern = s[1:], pattern[1:]
+					continue
+				}
+			case '[':
+				if len(s) > 0 {
+					if ok, rest := matchClass(pattern[1:], s[0]); ok {
+						s, pattern = s[1:], rest
+						continue
+					}
+				}
+			default:
+				literal := pattern
+				if literal[0] == '\\' && len(literal) > 1 {
+					literal = literal[1:]
+				}
+				if len(s) > 0 && literal[0] == s[0] {
+					s, pattern = s[1:], literal[1:]
+					continue
+				}
+			}
+		}
+		// Let the last star swallow one more byte and try again from there
+		if !starred || len(starS) == 0 {
+			return false
+		}
+		starS = starS[1:]
+		s, pattern = starS, starPattern
+	}
+	return true
+}
+
+// Match c against the class starting right after [
+// Return the rest of the pattern after the closing ], an unterminated class ends with the pattern
+func matchClass(pattern string, c byte) (bool, string) {
+	negate := len(pattern) > 0 && pattern[0] == '^'
+	if negate {
+		pattern = pattern[1:]
+	}
+	match := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) > 1:
+			match = match || pattern[1] == c
+			pattern = pattern[2:]
+		case len(pattern) > 2 && pattern[1] == '-':
+			lo, hi := pattern[0], pattern[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			match = match || (c >= lo && c <= hi)
+			pattern = pattern[3:]
+		default:
+			match = match || pattern[0] == c
+			pattern = pattern[1:]
+		}
+	}
+	if len(pattern) > 0 {
+		pattern = pattern[1:] // Closing ]
+	}
+	return match != negate, pattern
+}
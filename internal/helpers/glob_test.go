@@ -0,0 +1,47 @@
+package helpers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		s        string
+		expected bool
+	}{
+		{"*", "", true},
+		{"news.*", "news.sport", true},
+		{"news.*", "weather", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"a\\*b", "a*b", true},
+		{"a\\*b", "axb", false},
+		{"*a*b*", "xxaxxbxx", true},
+		{"*a*b", "xxaxxbxx", false},
+		{"user:[0-9]", "user:7", true},
+		{"[abc", "b", true},
+		{"a*", "b", false},
+		{"*?", "", false},
+		{"*.txt", "a.txt.txt", true},
+		{"a*b*c", "abcbc", true},
+		// Backtracking into every star would never finish on these
+		{"*a*a*a*a*a*a*a*a*a*a*a*ab", strings.Repeat("a", 40), false},
+		{"*a*a*a*a*a*a*a*a*a*a*a*ab", strings.Repeat("a", 40) + "b", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.s, func(t *testing.T) {
+			if got := GlobMatch(tt.pattern, tt.s); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
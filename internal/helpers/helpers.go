@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -91,3 +92,28 @@ func FormatDebugStack(stack string) string {
 
 	return formatted.String()
 }
+
+// Parse a memory size the way Redis config does, e.g. 100mb or 1gb
+// k, m and g are powers of 1000 while kb, mb and gb are powers of 1024
+func ParseMemory(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		scale  int64
+	}{
+		{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+		{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000}, {"b", 1},
+	}
+	lower := strings.ToLower(s)
+	scale := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(lower, u.suffix) {
+			lower, scale = strings.TrimSuffix(lower, u.suffix), u.scale
+			break
+		}
+	}
+	n, err := strconv.ParseInt(lower, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid memory size: %s", s)
+	}
+	return n * scale, nil
+}
@@ -0,0 +1,35 @@
+package helpers
+
+import "testing"
+
+func TestParseMemory(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		err      bool
+	}{
+		{"0", 0, false},
+		{"1024", 1024, false},
+		{"1k", 1000, false},
+		{"1kb", 1024, false},
+		{"100MB", 100 << 20, false},
+		{"2gb", 2 << 30, false},
+		{"3g", 3000000000, false},
+		{"10b", 10, false},
+		{"-1", 0, true},
+		{"mb", 0, true},
+		{"ten", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseMemory(tt.input)
+			if (err != nil) != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
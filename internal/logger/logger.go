@@ -60,6 +60,29 @@ func (l Level) String() string {
 	}
 }
 
+// The level named as in the loglevel setting
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "info":
+		return LevelInfo, nil
+	case "error":
+		return LevelError, nil
+	case "fatal":
+		return LevelFatal, nil
+	case "off":
+		return LevelOff, nil
+	default:
+		return 0, fmt.Errorf("invalid log level '%s'", s)
+	}
+}
+
+// Change the least severe level that is logged while the server runs
+func (l *Logger) SetMinLevel(level Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.config.MinLevel = level
+}
+
 // Have to be Write to satisfy the io.Writer interface
 func (l *Logger) Write(msg []byte) (n int, err error) {
 	return l.output(LevelError, string(msg), nil)
@@ -81,7 +104,10 @@ func (l *Logger) Fatal(err error, props map[string]string) {
 // TODO: Rewrite this without nested struct and prioritize early returns
 func (l *Logger) output(level Level, msg string, props map[string]string) (int, error) {
 	// No need to display level below error
-	if level < l.config.MinLevel {
+	l.mu.Lock()
+	minLevel := l.config.MinLevel
+	l.mu.Unlock()
+	if level < minLevel {
 		return 0, nil
 	}
 
@@ -2,18 +2,17 @@ package parser
 
 import (
 	"bufio"
-	"errors"
-	"io"
 	"net"
-	"strconv"
 
 	"gitlab.com/phamhonganh12062000/smolredis/internal/command"
 	"gitlab.com/phamhonganh12062000/smolredis/internal/logger"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
 )
 
 type Parser struct {
 	conn net.Conn
 	r    *bufio.Reader // Bufffered I/O
+	resp *resp.Reader  // Decode RESP commands out of r
 	// Used for inline parsing - interpret data as it is being read
 	// Without storing the input in memory first
 	line   []byte
@@ -22,9 +21,11 @@ type Parser struct {
 }
 
 func NewParser(conn net.Conn, logger *logger.Logger) *Parser {
+	r := bufio.NewReader(conn)
 	return &Parser{
 		conn:   conn,
-		r:      bufio.NewReader(conn),
+		r:      r,
+		resp:   resp.NewReader(r),
 		line:   make([]byte, 0),
 		pos:    0,
 		logger: logger,
@@ -32,24 +33,28 @@ func NewParser(conn net.Conn, logger *logger.Logger) *Parser {
 }
 
 func (p *Parser) Command(logger *logger.Logger) (command.Command, error) {
-	b, err := p.r.ReadByte()
+	b, err := p.r.Peek(1)
 	if err != nil {
 		return command.Command{}, err
 	}
-	if b == '*' {
+	if b[0] == resp.ARRAY {
 		logger.Info("resp array", nil)
-		return p.respArray()
+		args, err := p.resp.ReadCommand()
+		return command.Command{Args: args, Conn: p.conn}, err
+	}
 
-	} else {
-		line, err := p.readLine()
-		if err != nil {
-			return command.Command{}, err
-		}
-		p.pos = 0
-		p.line = append([]byte{}, b)
-		p.line = append(p.line, line...)
-		return p.inline()
+	line, err := p.readLine()
+	if err != nil {
+		return command.Command{}, err
 	}
+	p.pos = 0
+	p.line = line
+	return p.inline()
+}
+
+// Number of bytes read from the connection but not parsed yet
+func (p *Parser) Buffered() int {
+	return p.r.Buffered()
 }
 
 func (p *Parser) current() byte {
@@ -99,75 +104,12 @@ func (p *Parser) consumeString() (s []byte, err error) {
 	}
 
 	if p.current() != '"' {
-		return nil, errors.New("unbalanced quotes in request")
+		return nil, &resp.ProtocolError{Msg: "unbalanced quotes in request"}
 	}
 	p.advance()
 	return
 }
 
-// *3\r\n - Num of elems to be consumed
-// $3\r\n
-// SET\r\n
-// $4\r\n
-// name\r\n
-// $4\r\n
-// John\r\n
-
-// This represents: SET name John
-func (p *Parser) respArray() (command.Command, error) {
-	cmd := command.Command{}
-	elementStr, err := p.readLine()
-	if err != nil {
-		return cmd, err
-	}
-
-	// 1st line contains the number of elems to be consumed
-	elems, _ := strconv.Atoi(string(elementStr))
-
-	for range elems {
-		tp, err := p.r.ReadByte()
-		if err != nil {
-			return cmd, err
-		}
-		switch tp {
-		case ':': // Integer case
-			// :1000\r\n
-			arg, err := p.readLine()
-			if err != nil {
-				return cmd, err
-			}
-			cmd.Args = append(cmd.Args, string(arg))
-		case '$': // Bulk string case
-			// $4\r\n
-			// name\r\n
-			arg, err := p.readLine()
-			if err != nil {
-				return cmd, err
-			}
-			length, _ := strconv.Atoi(string(arg))
-			text := make([]byte, length)
-			// TODO: This is either dark magic or total bullshit
-			// Be sure to test this thoroughly
-			_, err = io.ReadFull(p.r, text) // Read exactly length bytes
-			if err != nil {
-				return cmd, err
-			}
-			// Discard the \r\n by reading them off to a to-be-discarded buffer
-			p.r.Read(make([]byte, 2))
-			cmd.Args = append(cmd.Args, string(text))
-		case '*':
-			// Read the next RESP array recursively
-			next, err := p.respArray()
-			if err != nil {
-				return cmd, err
-			}
-			cmd.Args = append(cmd.Args, next.Args...)
-		}
-	}
-
-	return cmd, nil
-}
-
 // Parse an inline message
 func (p *Parser) inline() (command.Command, error) {
 	// In case the user sends a ' GET a'
@@ -179,7 +121,7 @@ func (p *Parser) inline() (command.Command, error) {
 	for !p.atEnd() {
 		arg, err := p.consumeArg()
 		if err != nil {
-			return cmd, nil
+			return cmd, err
 		}
 		if arg != "" {
 			cmd.Args = append(cmd.Args, arg)
@@ -0,0 +1,227 @@
+package pubsub
+
+import (
+	"slices"
+	"sync"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/helpers"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+)
+
+// Messages a subscriber may have pending before it is considered too slow and dropped
+const SUBSCRIBER_BUFFER = 1024
+
+// Routes published messages to the connections subscribed to their channel
+type Hub struct {
+	mu       sync.Mutex // Guard the fields below and the subscriptions of every subscriber
+	channels map[string]map[*Subscriber]struct{}
+	patterns map[string]map[*Subscriber]struct{}
+}
+
+// One connection's side of the hub
+type Subscriber struct {
+	messages chan Message
+	overflow func() // Called once when messages is full, e.g. to close the connection
+	dropped  bool
+
+	channels map[string]struct{}
+	patterns map[string]struct{}
+}
+
+// A published message as delivered to one subscriber
+// Pattern is only set when it matched through a pattern subscription
+type Message struct {
+	Pattern string
+	Channel string
+	Payload string
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		channels: make(map[string]map[*Subscriber]struct{}),
+		patterns: make(map[string]map[*Subscriber]struct{}),
+	}
+}
+
+func NewSubscriber(overflow func()) *Subscriber {
+	return &Subscriber{
+		messages: make(chan Message, SUBSCRIBER_BUFFER),
+		overflow: overflow,
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+}
+
+// Messages waiting to be written to the connection
+func (s *Subscriber) Messages() <-chan Message {
+	return s.messages
+}
+
+// Return the number of subscriptions of s afterwards
+func (h *Hub) Subscribe(s *Subscriber, channel string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	add(h.channels, s.channels, s, channel)
+	return s.count()
+}
+
+func (h *Hub) PSubscribe(s *Subscriber, pattern string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	add(h.patterns, s.patterns, s, pattern)
+	return s.count()
+}
+
+func (h *Hub) Unsubscribe(s *Subscriber, channel string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	remove(h.channels, s.channels, s, channel)
+	return s.count()
+}
+
+func (h *Hub) PUnsubscribe(s *Subscriber, pattern string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	remove(h.patterns, s.patterns, s, pattern)
+	return s.count()
+}
+
+// Drop every subscription of s, e.g. once its connection is closed
+func (h *Hub) Remove(s *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for channel := range s.channels {
+		remove(h.channels, s.channels, s, channel)
+	}
+	for pattern := range s.patterns {
+		remove(h.patterns, s.patterns, s, pattern)
+	}
+}
+
+// Channels s is subscribed to, sorted
+func (h *Hub) ChannelsOf(s *Subscriber) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return sortedKeys(s.channels)
+}
+
+func (h *Hub) PatternsOf(s *Subscriber) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return sortedKeys(s.patterns)
+}
+
+// Number of channels and patterns s is subscribed to
+func (h *Hub) Count(s *Subscriber) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return s.count()
+}
+
+// Queue the message for every subscriber of the channel or of a matching pattern
+// Never blocks: a subscriber whose queue is full is dropped instead
+// Return the number of subscribers that got it
+func (h *Hub) Publish(channel, payload string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	receivers := 0
+	for s := range h.channels[channel] {
+		if s.deliver(Message{Channel: channel, Payload: payload}) {
+			receivers++
+		}
+	}
+	for pattern, subscribers := range h.patterns {
+		if !helpers.GlobMatch(pattern, channel) {
+			continue
+		}
+		for s := range subscribers {
+			if s.deliver(Message{Pattern: pattern, Channel: channel, Payload: payload}) {
+				receivers++
+			}
+		}
+	}
+	return receivers
+}
+
+// Channels with at least one subscriber, only those matching pattern if not empty
+func (h *Hub) Channels(pattern string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := []string{}
+	for channel := range h.channels {
+		if pattern == "" || helpers.GlobMatch(pattern, channel) {
+			out = append(out, channel)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Number of subscribers of the channel, pattern subscriptions not included
+func (h *Hub) NumSub(channel string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.channels[channel])
+}
+
+// Number of patterns with at least one subscriber
+func (h *Hub) NumPat() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.patterns)
+}
+
+// Must be called with the hub locked
+func (s *Subscriber) deliver(m Message) bool {
+	if s.dropped {
+		return false
+	}
+	select {
+	case s.messages <- m:
+		return true
+	default:
+		s.dropped = true
+		if s.overflow != nil {
+			go s.overflow()
+		}
+		return false
+	}
+}
+
+func (s *Subscriber) count() int {
+	return len(s.channels) + len(s.patterns)
+}
+
+func add(index map[string]map[*Subscriber]struct{}, own map[string]struct{}, s *Subscriber, name string) {
+	if index[name] == nil {
+		index[name] = make(map[*Subscriber]struct{})
+	}
+	index[name][s] = struct{}{}
+	own[name] = struct{}{}
+}
+
+func remove(index map[string]map[*Subscriber]struct{}, own map[string]struct{}, s *Subscriber, name string) {
+	delete(own, name)
+	delete(index[name], s)
+	if len(index[name]) == 0 {
+		delete(index, name)
+	}
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Push frame sent for the message in the given protocol, e.g. message <channel> <payload>
+func (m Message) Encode(proto int) []byte {
+	if m.Pattern != "" {
+		return resp.AppendPush(nil, proto, []string{"pmessage", m.Pattern, m.Channel, m.Payload})
+	}
+	return resp.AppendPush(nil, proto, []string{"message", m.Channel, m.Payload})
+}
@@ -0,0 +1,74 @@
+package pubsub
+
+import (
+	"slices"
+	"testing"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+)
+
+func TestPublish(t *testing.T) {
+	h := NewHub()
+	direct := NewSubscriber(nil)
+	pattern := NewSubscriber(nil)
+	h.Subscribe(direct, "news.sport")
+	h.PSubscribe(pattern, "news.*")
+
+	if n := h.Publish("news.sport", "goal"); n != 2 {
+		t.Fatalf("Expected 2 receivers, got %d", n)
+	}
+	if n := h.Publish("weather", "rain"); n != 0 {
+		t.Fatalf("Expected 0 receivers, got %d", n)
+	}
+
+	if m := <-direct.Messages(); m != (Message{Channel: "news.sport", Payload: "goal"}) {
+		t.Errorf("Unexpected message %+v", m)
+	}
+	if m := <-pattern.Messages(); m != (Message{Pattern: "news.*", Channel: "news.sport", Payload: "goal"}) {
+		t.Errorf("Unexpected message %+v", m)
+	}
+
+	if got := h.Channels(""); !slices.Equal(got, []string{"news.sport"}) {
+		t.Errorf("Expected [news.sport], got %v", got)
+	}
+	h.Remove(direct)
+	if n := h.NumSub("news.sport"); n != 0 {
+		t.Errorf("Expected 0 subscribers after removal, got %d", n)
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	h := NewHub()
+	dropped := make(chan struct{})
+	s := NewSubscriber(func() { close(dropped) })
+	h.Subscribe(s, "jobs")
+
+	// Nobody reads the messages, the publisher must still never block
+	for range SUBSCRIBER_BUFFER {
+		h.Publish("jobs", "x")
+	}
+	if n := h.Publish("jobs", "one too many"); n != 0 {
+		t.Errorf("Expected the slow subscriber to be skipped, got %d receivers", n)
+	}
+	<-dropped
+}
+
+func TestEncode(t *testing.T) {
+	m := Message{Pattern: "n*", Channel: "news", Payload: "hi"}
+	body := "$8\r\npmessage\r\n$2\r\nn*\r\n$4\r\nnews\r\n$2\r\nhi\r\n"
+	tests := []struct {
+		name     string
+		proto    int
+		expected string
+	}{
+		{"RESP2 array", resp.RESP2, "*4\r\n" + body},
+		{"RESP3 push", resp.RESP3, ">4\r\n" + body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(m.Encode(tt.proto)); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package rdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+)
+
+// Layout of a snapshot file:
+//
+// SMOLRDB0001                  magic + 4-digit version
+// [0xFC <int64 unix ms>]       optional absolute expiry of the next key
+// <type> <key> <value>         one record per key
+// ...
+// 0xFF                         end of file
+// <uint64 crc64>               checksum of everything before it
+//
+// Keys and string values are written as an uvarint length followed by the raw bytes
+// Collections start with their number of elements as an uvarint
+// Hashes are written as their fields and values interleaved
+// Sorted sets as their members each followed by its score as a float64
+const (
+	magic   = "SMOLRDB"
+	Version = 1
+
+	opExpireMs = 0xFC
+	opEOF      = 0xFF
+
+	typeString = 0x00
+	typeList   = 0x01
+	typeHash   = 0x02
+	typeSet    = 0x03
+	typeZSet   = 0x04
+)
+
+var crcTable = crc64.MakeTable(crc64.ECMA)
+
+var (
+	ErrBadMagic    = errors.New("rdb: not a smolredis snapshot")
+	ErrBadVersion  = errors.New("rdb: unsupported snapshot version")
+	ErrBadChecksum = errors.New("rdb: checksum mismatch")
+)
+
+type encoder struct {
+	out     io.Writer // Everything written here goes into the checksum too
+	scratch [binary.MaxVarintLen64]byte
+	err     error // First write error, every later write is a no-op
+}
+
+func (e *encoder) write(b []byte) {
+	if e.err == nil {
+		_, e.err = e.out.Write(b)
+	}
+}
+
+func (e *encoder) writeLen(n int) {
+	e.write(e.scratch[:binary.PutUvarint(e.scratch[:], uint64(n))])
+}
+
+func (e *encoder) writeString(s string) {
+	e.writeLen(len(s))
+	e.write([]byte(s))
+}
+
+func (e *encoder) writeStrings(values []string) {
+	e.writeLen(len(values))
+	for _, v := range values {
+		e.writeString(v)
+	}
+}
+
+// Doubles are stored as their IEEE 754 bits, little endian
+func (e *encoder) writeScore(f float64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(f))
+	e.write(b[:])
+}
+
+func (e *encoder) writeEntry(key string, entry store.Entry) error {
+	if !entry.ExpireAt.IsZero() {
+		var ms [9]byte
+		ms[0] = opExpireMs
+		binary.LittleEndian.PutUint64(ms[1:], uint64(entry.ExpireAt.UnixMilli()))
+		e.write(ms[:])
+	}
+
+	switch v := entry.Value.(type) {
+	case string:
+		e.write([]byte{typeString})
+		e.writeString(key)
+		e.writeString(v)
+	case *store.List:
+		e.write([]byte{typeList})
+		e.writeString(key)
+		e.writeStrings(v.Values())
+	case *store.Hash:
+		e.write([]byte{typeHash})
+		e.writeString(key)
+		e.writeStrings(v.Pairs())
+	case *store.Set:
+		e.write([]byte{typeSet})
+		e.writeString(key)
+		e.writeStrings(v.Members())
+	case *store.ZSet:
+		e.write([]byte{typeZSet})
+		e.writeString(key)
+		entries := v.Entries()
+		e.writeLen(len(entries))
+		for _, entry := range entries {
+			e.writeString(entry.Member)
+			e.writeScore(entry.Score)
+		}
+	default:
+		return fmt.Errorf("rdb: cannot encode value of type %T for key '%s'", v, key)
+	}
+	return e.err
+}
+
+// Serialize the entries into w using the snapshot format
+func Encode(w io.Writer, entries map[string]store.Entry) error {
+	bw := bufio.NewWriter(w)
+	h := crc64.New(crcTable)
+	e := &encoder{out: io.MultiWriter(bw, h)}
+
+	e.write(fmt.Appendf(nil, "%s%04d", magic, Version))
+	for key, entry := range entries {
+		if err := e.writeEntry(key, entry); err != nil {
+			return err
+		}
+	}
+	e.write([]byte{opEOF})
+	if e.err != nil {
+		return e.err
+	}
+
+	// The checksum itself is not part of the checksum
+	var sum [8]byte
+	binary.LittleEndian.PutUint64(sum[:], h.Sum64())
+	if _, err := bw.Write(sum[:]); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+type decoder struct {
+	r *bytes.Reader
+}
+
+func (d *decoder) readLen() (int, error) {
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	// Every element takes at least one byte, which bounds the allocations on corrupted input
+	if n > uint64(d.r.Len()) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return int(n), nil
+}
+
+func (d *decoder) readString() (string, error) {
+	n, err := d.readLen()
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return "", io.ErrUnexpectedEOF
+	}
+	return string(b), nil
+}
+
+func (d *decoder) readStrings() ([]string, error) {
+	n, err := d.readLen()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, n)
+	for range n {
+		v, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (d *decoder) readScore() (float64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(d.r, b[:]); err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b[:])), nil
+}
+
+func (d *decoder) readValue(kind byte) (any, error) {
+	switch kind {
+	case typeString:
+		return d.readString()
+	case typeList:
+		values, err := d.readStrings()
+		if err != nil {
+			return nil, err
+		}
+		return store.ListOf(values...), nil
+	case typeHash:
+		pairs, err := d.readStrings()
+		if err != nil {
+			return nil, err
+		}
+		if len(pairs)%2 != 0 {
+			return nil, fmt.Errorf("rdb: odd number of strings in hash")
+		}
+		h := store.NewHash()
+		for i := 0; i < len(pairs); i += 2 {
+			h.Set(pairs[i], pairs[i+1])
+		}
+		return h, nil
+	case typeSet:
+		members, err := d.readStrings()
+		if err != nil {
+			return nil, err
+		}
+		return store.SetOf(members...), nil
+	case typeZSet:
+		n, err := d.readLen()
+		if err != nil {
+			return nil, err
+		}
+		z := store.NewZSet()
+		for range n {
+			member, err := d.readString()
+			if err != nil {
+				return nil, err
+			}
+			score, err := d.readScore()
+			if err != nil {
+				return nil, err
+			}
+			z.Add(member, score)
+		}
+		return z, nil
+	default:
+		return nil, fmt.Errorf("rdb: unknown record type 0x%02x", kind)
+	}
+}
+
+// Parse a snapshot produced by Encode
+// Keys that already expired are skipped
+func Decode(r io.Reader) (map[string]store.Entry, error) {
+	// Snapshots are read whole so the checksum can be verified before touching anything
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	header := len(magic) + 4
+	if len(raw) < header+1+8 || string(raw[:len(magic)]) != magic {
+		return nil, ErrBadMagic
+	}
+	version, err := strconv.Atoi(string(raw[len(magic):header]))
+	if err != nil {
+		return nil, ErrBadMagic
+	}
+	if version < 1 || version > Version {
+		return nil, ErrBadVersion
+	}
+
+	body, sum := raw[:len(raw)-8], raw[len(raw)-8:]
+	if crc64.Checksum(body, crcTable) != binary.LittleEndian.Uint64(sum) {
+		return nil, ErrBadChecksum
+	}
+
+	d := &decoder{r: bytes.NewReader(body[header:])}
+	now := time.Now()
+	entries := make(map[string]store.Entry)
+	var expireAt time.Time
+	for {
+		op, err := d.r.ReadByte()
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		switch op {
+		case opEOF:
+			return entries, nil
+		case opExpireMs:
+			var ms [8]byte
+			if _, err := io.ReadFull(d.r, ms[:]); err != nil {
+				return nil, io.ErrUnexpectedEOF
+			}
+			expireAt = time.UnixMilli(int64(binary.LittleEndian.Uint64(ms[:])))
+			continue
+		}
+
+		key, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		val, err := d.readValue(op)
+		if err != nil {
+			return nil, err
+		}
+		if expireAt.IsZero() || now.Before(expireAt) {
+			entries[key] = store.Entry{Value: val, ExpireAt: expireAt}
+		}
+
+		// An expiry only applies to the record right after it
+		expireAt = time.Time{}
+	}
+}
@@ -0,0 +1,173 @@
+package rdb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/logger"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	expireAt := time.UnixMilli(time.Now().Add(time.Hour).UnixMilli())
+	user := store.NewHash()
+	user.Set("name", "John")
+	user.Set("visits", "3")
+	board := store.NewZSet()
+	board.Add("alice", 1.5)
+	board.Add("bob", math.Inf(-1))
+	entries := map[string]store.Entry{
+		"name":    {Value: "John"},
+		"empty":   {Value: ""},
+		"session": {Value: "abc\r\n123", ExpireAt: expireAt},
+		"queue":   {Value: store.ListOf("a", "", "c")},
+		"user":    {Value: user},
+		"online":  {Value: store.SetOf("alice", "bob")},
+		"board":   {Value: board},
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, entries); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(decoded) != len(entries) {
+		t.Fatalf("Expected %d entries, got %d", len(entries), len(decoded))
+	}
+	for key, want := range entries {
+		got, ok := decoded[key]
+		if !ok {
+			t.Errorf("Expected key %s to be present", key)
+			continue
+		}
+		if !reflect.DeepEqual(contents(got.Value), contents(want.Value)) {
+			t.Errorf("Expected value of %s to be %v, got %v", key, want.Value, got.Value)
+		}
+		if !got.ExpireAt.Equal(want.ExpireAt) {
+			t.Errorf("Expected expiry of %s to be %v, got %v", key, want.ExpireAt, got.ExpireAt)
+		}
+	}
+}
+
+// Collections are compared by their elements, not by how they are laid out in memory
+func contents(v any) any {
+	if l, ok := v.(*store.List); ok {
+		return l.Values()
+	}
+	if z, ok := v.(*store.ZSet); ok {
+		return z.Entries()
+	}
+	if s, ok := v.(*store.Set); ok {
+		members := s.Members()
+		slices.Sort(members)
+		return members
+	}
+	return v
+}
+
+func TestDecodeSkipsExpiredKeys(t *testing.T) {
+	entries := map[string]store.Entry{
+		"stale": {Value: "old", ExpireAt: time.Now().Add(-time.Second)},
+		"fresh": {Value: "new"},
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, entries); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := decoded["stale"]; ok {
+		t.Errorf("Expected expired key to be dropped")
+	}
+	if _, ok := decoded["fresh"]; !ok {
+		t.Errorf("Expected key without expiry to be loaded")
+	}
+}
+
+func TestDecodeRejectsCorruptedFiles(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, map[string]store.Entry{"key": {Value: "value"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	valid := buf.Bytes()
+
+	tests := []struct {
+		name     string
+		input    []byte
+		expected error
+	}{
+		{
+			name:     "Wrong magic",
+			input:    append([]byte("REDIS"), valid[5:]...),
+			expected: ErrBadMagic,
+		},
+		{
+			name:     "Future version",
+			input:    append([]byte("SMOLRDB9999"), valid[11:]...),
+			expected: ErrBadVersion,
+		},
+		{
+			name: "Flipped byte in the body",
+			input: func() []byte {
+				b := bytes.Clone(valid)
+				b[len(b)-10] ^= 0xFF
+				return b
+			}(),
+			expected: ErrBadChecksum,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(bytes.NewReader(tt.input))
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("Expected error %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+// A save on shutdown must not give up because of a BGSAVE still running
+func TestSaveAfterBackgroundSave(t *testing.T) {
+	log := logger.New(io.Discard, logger.LoggerConfig{MinLevel: logger.LevelOff})
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	db := store.NewInMemoryStore()
+	db.Set("a", "1")
+	s := NewSnapshotter(path, db, log)
+
+	if err := s.BackgroundSave(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	s.Wait()
+	if s.InProgress() {
+		t.Fatal("Expected the background save to be over")
+	}
+	db.Set("b", "2")
+	if err := s.Save(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	loaded := store.NewInMemoryStore()
+	if err := NewSnapshotter(path, loaded, log).Load(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v, _ := loaded.Get("b"); v != "2" {
+		t.Errorf("Expected 2, got %v", v)
+	}
+}
@@ -0,0 +1,176 @@
+package rdb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/logger"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+)
+
+var ErrSaveInProgress = errors.New("Background save already in progress")
+
+// Write snapshots of a store to a file on disk and load them back
+type Snapshotter struct {
+	path   string
+	store  *store.InMemoryStore
+	logger *logger.Logger
+
+	mu         sync.Mutex // Guard the fields below
+	saving     bool
+	saved      *sync.Cond // Broadcast whenever a save finishes
+	lastSave   time.Time
+	lastErr    error  // Of the last save, nil if it succeeded
+	saveDirty  uint64 // Changes to the store when the save in progress started
+	savedDirty uint64 // Changes to the store the file on disk includes
+}
+
+func NewSnapshotter(path string, store *store.InMemoryStore, logger *logger.Logger) *Snapshotter {
+	s := &Snapshotter{
+		path:     path,
+		store:    store,
+		logger:   logger,
+		lastSave: time.Now(),
+	}
+	s.saved = sync.NewCond(&s.mu)
+	return s
+}
+
+// Fill the store with the content of the snapshot file
+// A missing file is not an error, the server simply starts empty
+func (s *Snapshotter) Load() error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	entries, err := Decode(f)
+	if err != nil {
+		return err
+	}
+	s.store.Replace(entries)
+	s.mu.Lock()
+	s.savedDirty = s.store.Dirty()
+	s.mu.Unlock()
+	s.logger.Info("Snapshot loaded", map[string]string{"path": s.path, "keys": strconv.Itoa(len(entries))})
+	return nil
+}
+
+// Save the store in the foreground, blocking the caller until the file is on disk
+func (s *Snapshotter) Save() error {
+	if !s.begin() {
+		return ErrSaveInProgress
+	}
+	err := s.write(s.store.Snapshot())
+	s.finish(err)
+	return err
+}
+
+// Take a point-in-time copy of the store and write it out in a separate goroutine
+func (s *Snapshotter) BackgroundSave() error {
+	if !s.begin() {
+		return ErrSaveInProgress
+	}
+	snapshot := s.store.Snapshot()
+	go func() {
+		err := s.write(snapshot)
+		s.finish(err)
+		if err != nil {
+			s.logger.Error(err, map[string]string{"path": s.path})
+			return
+		}
+		s.logger.Info("Background saving terminated with success", map[string]string{"path": s.path})
+	}()
+	return nil
+}
+
+// Time of the last successful save
+func (s *Snapshotter) LastSave() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastSave
+}
+
+// Error of the last save, nil if it succeeded
+func (s *Snapshotter) LastError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// Number of changes made to the store since the last successful save
+func (s *Snapshotter) Changes() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.Dirty() - s.savedDirty
+}
+
+func (s *Snapshotter) InProgress() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saving
+}
+
+// Block until the save in progress, if any, is over
+// e.g. so a final Save on shutdown does not fail with ErrSaveInProgress
+func (s *Snapshotter) Wait() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.saving {
+		s.saved.Wait()
+	}
+}
+
+func (s *Snapshotter) begin() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.saving {
+		return false
+	}
+	s.saving = true
+	s.saveDirty = s.store.Dirty()
+	return true
+}
+
+func (s *Snapshotter) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saving = false
+	s.lastErr = err
+	if err == nil {
+		s.lastSave = time.Now()
+		s.savedDirty = s.saveDirty
+	}
+	s.saved.Broadcast()
+}
+
+// Write to a temp file first then rename it
+// so a crash in the middle of a save never corrupts the previous snapshot
+func (s *Snapshotter) write(entries map[string]store.Entry) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "temp-*.rdb")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if err := Encode(tmp, entries); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
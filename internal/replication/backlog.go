@@ -0,0 +1,53 @@
+package replication
+
+// Default size of the replication backlog, same as Redis
+const BACKLOG_SIZE = 1 << 20
+
+// Circular buffer holding the tail of the command stream
+// A follower that reconnects after a short outage gets the bytes it missed from here
+// instead of a whole new snapshot
+type backlog struct {
+	buf     []byte
+	idx     int   // Where the next byte goes
+	histlen int   // How much of buf holds real data
+	end     int64 // Stream offset right after the newest byte
+}
+
+// A backlog whose first byte will be at the given stream offset
+func newBacklog(size int, offset int64) *backlog {
+	return &backlog{buf: make([]byte, size), end: offset}
+}
+
+func (b *backlog) write(p []byte) {
+	b.end += int64(len(p))
+	// Only the tail of a write bigger than the whole buffer matters
+	if len(p) > len(b.buf) {
+		p = p[len(p)-len(b.buf):]
+	}
+	for len(p) > 0 {
+		n := copy(b.buf[b.idx:], p)
+		p = p[n:]
+		b.idx = (b.idx + n) % len(b.buf)
+		b.histlen = min(b.histlen+n, len(b.buf))
+	}
+}
+
+// Offset of the oldest byte still held
+func (b *backlog) start() int64 {
+	return b.end - int64(b.histlen)
+}
+
+// Copy of the stream from offset up to the newest byte
+// Return false if part of that range already left the buffer
+func (b *backlog) since(offset int64) ([]byte, bool) {
+	if offset < b.start() || offset > b.end {
+		return nil, false
+	}
+	n := int(b.end - offset)
+	out := make([]byte, n)
+	// Oldest wanted byte, counting back from the write position
+	from := (b.idx - n + len(b.buf)) % len(b.buf)
+	copied := copy(out, b.buf[from:])
+	copy(out[copied:], b.buf[:n-copied])
+	return out, true
+}
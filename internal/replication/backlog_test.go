@@ -0,0 +1,90 @@
+package replication
+
+import (
+	"testing"
+)
+
+func TestBacklogSince(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		start    int64
+		writes   []string
+		offset   int64
+		expected string
+		ok       bool
+	}{
+		{
+			name:     "Everything still held",
+			size:     16,
+			writes:   []string{"abc", "def"},
+			offset:   0,
+			expected: "abcdef",
+			ok:       true,
+		},
+		{
+			name:     "Follower is up to date",
+			size:     16,
+			writes:   []string{"abc"},
+			offset:   3,
+			expected: "",
+			ok:       true,
+		},
+		{
+			name:     "Wrapped around the end of the buffer",
+			size:     4,
+			writes:   []string{"abc", "def"},
+			offset:   3,
+			expected: "def",
+			ok:       true,
+		},
+		{
+			name:   "Offset already overwritten",
+			size:   4,
+			writes: []string{"abc", "def"},
+			offset: 1,
+			ok:     false,
+		},
+		{
+			name:   "Offset in the future",
+			size:   16,
+			writes: []string{"abc"},
+			offset: 4,
+			ok:     false,
+		},
+		{
+			name:     "Write bigger than the buffer",
+			size:     4,
+			writes:   []string{"abcdefgh"},
+			offset:   4,
+			expected: "efgh",
+			ok:       true,
+		},
+		{
+			name:     "Backlog started mid-stream after a full resync",
+			size:     8,
+			start:    100,
+			writes:   []string{"abc"},
+			offset:   101,
+			expected: "bc",
+			ok:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBacklog(tt.size, tt.start)
+			for _, w := range tt.writes {
+				b.write([]byte(w))
+			}
+
+			got, ok := b.since(tt.offset)
+			if ok != tt.ok {
+				t.Fatalf("Expected ok to be %v, got %v", tt.ok, ok)
+			}
+			if ok && string(got) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, string(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package replication
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/rdb"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+)
+
+const (
+	// Give up on the leader if it stays silent for that long, it pings us more often than that
+	LINK_TIMEOUT   = 60 * time.Second
+	RETRY_INTERVAL = time.Second
+	ACK_PERIOD     = time.Second
+)
+
+var ErrAlreadyFollowing = errors.New("Already connected to specified master")
+
+// Our connection to the leader, seen from the follower side
+type link struct {
+	host string
+	port string
+
+	lastIO atomic.Int64 // Unix time of the last data received from the leader
+
+	mu      sync.Mutex // Guard the fields below
+	conn    net.Conn
+	up      bool
+	syncing bool
+	stopped bool
+}
+
+// Start following the leader at host:port, dropping the current leader if any
+func (m *Manager) Follow(host, port string) error {
+	m.mu.Lock()
+	if m.link != nil && m.link.host == host && m.link.port == port {
+		m.mu.Unlock()
+		return ErrAlreadyFollowing
+	}
+	if m.link != nil {
+		m.link.stop()
+	}
+	l := &link{host: host, port: port}
+	m.link = l
+	m.mu.Unlock()
+
+	m.logger.Info("Following new leader", map[string]string{"leader": net.JoinHostPort(host, port)})
+	go m.follow(l)
+	return nil
+}
+
+// Become a leader again while keeping the data we got so far
+func (m *Manager) StopFollowing() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.link == nil {
+		return
+	}
+	m.link.stop()
+	m.link = nil
+	m.shiftReplID()
+	m.logger.Info("Stopped following the leader, now acting as a leader", map[string]string{"replid": m.replid})
+}
+
+// Keep the link alive by reconnecting until we are told to stop
+func (m *Manager) follow(l *link) {
+	for !l.isStopped() {
+		err := m.sync(l)
+		l.mu.Lock()
+		l.up, l.syncing = false, false
+		l.mu.Unlock()
+		if l.isStopped() {
+			return
+		}
+		if err != nil {
+			m.logger.Error(err, map[string]string{"leader": net.JoinHostPort(l.host, l.port)})
+		}
+		time.Sleep(RETRY_INTERVAL)
+	}
+}
+
+// Handshake, resynchronization, then apply the command stream until the connection breaks
+func (m *Manager) sync(l *link) error {
+	m.mu.Lock()
+	tlsConfig := m.tlsConfig
+	m.mu.Unlock()
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	addr := net.JoinHostPort(l.host, l.port)
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig(l.host))
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if !l.setConn(conn) {
+		return nil // Stopped while we were dialing
+	}
+
+	r := bufio.NewReader(&timeoutReader{conn: conn, link: l})
+	send := func(args ...string) (string, error) {
+		if _, err := conn.Write(resp.AppendArray(nil, args)); err != nil {
+			return "", err
+		}
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "-") {
+			return "", fmt.Errorf("leader replied to %s with %s", args[0], line[1:])
+		}
+		return line, nil
+	}
+
+	m.mu.Lock()
+	user, password := m.masterUser, m.masterAuth
+	m.mu.Unlock()
+	if password != "" {
+		auth := []string{"AUTH", password}
+		if user != "" {
+			auth = []string{"AUTH", user, password}
+		}
+		if _, err := send(auth...); err != nil {
+			return err
+		}
+	}
+	if _, err := send("PING"); err != nil {
+		return err
+	}
+	if _, err := send("REPLCONF", "listening-port", m.port); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.syncing = true
+	l.mu.Unlock()
+
+	// Our own history is the best guess of where we left off
+	// It is either the one of this leader or one it may know from before a promotion
+	m.mu.Lock()
+	replid, offset := m.replid, m.offset
+	m.mu.Unlock()
+	reply, err := send("PSYNC", replid, strconv.FormatInt(offset+1, 10))
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(reply)
+	switch {
+	// +FULLRESYNC <replid> <offset>
+	case len(fields) == 3 && fields[0] == "+FULLRESYNC":
+		offset, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("unexpected reply to PSYNC: %s", reply)
+		}
+		if err := m.fullSync(r, fields[1], offset); err != nil {
+			return err
+		}
+	// +CONTINUE [<new replid>]
+	case len(fields) >= 1 && fields[0] == "+CONTINUE":
+		m.mu.Lock()
+		if len(fields) == 2 && fields[1] != m.replid {
+			// The leader was promoted since we last talked to it
+			m.shiftReplID()
+			m.replid = fields[1]
+			m.dropReplicas()
+		}
+		m.mu.Unlock()
+		m.logger.Info("Partial resynchronization with leader succeeded", map[string]string{"offset": strconv.FormatInt(offset, 10)})
+	default:
+		return fmt.Errorf("unexpected reply to PSYNC: %s", reply)
+	}
+
+	l.mu.Lock()
+	l.up, l.syncing = true, false
+	l.mu.Unlock()
+
+	go m.ackLoop(conn)
+
+	// Pass the exact bytes we applied on to our own backlog and followers
+	// so the offsets stay the same down the chain
+	rec := &recorder{r: r}
+	var forwarded int64
+	return m.target.Apply(rec, func(n int64) {
+		raw := rec.take(int(n - forwarded))
+		forwarded = n
+		m.mu.Lock()
+		m.feed(raw)
+		m.mu.Unlock()
+	})
+}
+
+// Read the snapshot that follows +FULLRESYNC and make it our keyspace
+func (m *Manager) fullSync(r *bufio.Reader, replid string, offset int64) error {
+	// $<length>\r\n<snapshot>
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	size, err := strconv.Atoi(strings.TrimRight(strings.TrimPrefix(header, "$"), "\r\n"))
+	if !strings.HasPrefix(header, "$") || err != nil || size < 0 {
+		return fmt.Errorf("unexpected snapshot header: %q", header)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return err
+	}
+	snapshot, err := rdb.Decode(bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	m.target.Load(snapshot)
+
+	m.mu.Lock()
+	m.resetReplID(replid, offset)
+	m.dropReplicas()
+	m.mu.Unlock()
+
+	m.logger.Info("Full synchronization with leader succeeded", map[string]string{"keys": strconv.Itoa(len(snapshot))})
+	return nil
+}
+
+// Tell the leader how far we got so it can report our lag
+func (m *Manager) ackLoop(conn net.Conn) {
+	ticker := time.NewTicker(ACK_PERIOD)
+	defer ticker.Stop()
+	for range ticker.C {
+		ack := resp.AppendArray(nil, []string{"REPLCONF", "ACK", strconv.FormatInt(m.Offset(), 10)})
+		if _, err := conn.Write(ack); err != nil {
+			return
+		}
+	}
+}
+
+func (l *link) setConn(conn net.Conn) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopped {
+		return false
+	}
+	l.conn = conn
+	return true
+}
+
+func (l *link) stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stopped = true
+	if l.conn != nil {
+		l.conn.Close()
+	}
+}
+
+func (l *link) isStopped() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stopped
+}
+
+func (l *link) info(offset int64) []string {
+	l.mu.Lock()
+	up, syncing := l.up, l.syncing
+	l.mu.Unlock()
+
+	status := "down"
+	if up {
+		status = "up"
+	}
+	lastIO := int64(-1)
+	if t := l.lastIO.Load(); t > 0 {
+		lastIO = time.Now().Unix() - t
+	}
+	return []string{
+		"master_host:" + l.host,
+		"master_port:" + l.port,
+		"master_link_status:" + status,
+		fmt.Sprintf("master_last_io_seconds_ago:%d", lastIO),
+		fmt.Sprintf("master_sync_in_progress:%d", boolToInt(syncing)),
+		fmt.Sprintf("slave_repl_offset:%d", offset),
+		"slave_read_only:1",
+	}
+}
+
+// Break out of a read when the leader stays silent for too long
+// and remember when we last heard from it
+type timeoutReader struct {
+	conn net.Conn
+	link *link
+}
+
+func (t *timeoutReader) Read(b []byte) (int, error) {
+	t.conn.SetReadDeadline(time.Now().Add(LINK_TIMEOUT))
+	n, err := t.conn.Read(b)
+	if n > 0 {
+		t.link.lastIO.Store(time.Now().Unix())
+	}
+	return n, err
+}
+
+// Keep a copy of everything read so the bytes of each applied command can be taken back out
+type recorder struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (rec *recorder) Read(b []byte) (int, error) {
+	n, err := rec.r.Read(b)
+	rec.buf = append(rec.buf, b[:n]...)
+	return n, err
+}
+
+// Remove and return the oldest n recorded bytes
+func (rec *recorder) take(n int) []byte {
+	out := bytes.Clone(rec.buf[:n])
+	rec.buf = rec.buf[n:]
+	return out
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,196 @@
+package replication
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/rdb"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+)
+
+// A follower that gets too far behind is disconnected instead of eating all the memory
+const MAX_PENDING_BYTES = 64 << 20
+
+const (
+	STATE_SEND_BULK = "send_bulk" // Still transferring the snapshot
+	STATE_ONLINE    = "online"    // Receiving the command stream
+)
+
+// A follower connected to us, seen from the leader side
+type Replica struct {
+	conn          net.Conn
+	listeningPort string
+
+	mu        sync.Mutex // Guard the fields below
+	pending   []byte     // Command stream not written to the connection yet
+	closed    bool
+	state     string
+	ackOffset int64
+	ackTime   time.Time
+
+	wake chan struct{} // Signal the sender that pending has data
+}
+
+// Send the command to every follower
+func (m *Manager) Feed(args []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.feed(resp.AppendArray(nil, args))
+}
+
+// Add raw command stream to the backlog and to every follower
+// Must be called with m.mu held
+func (m *Manager) feed(record []byte) {
+	m.offset += int64(len(record))
+	m.backlog.write(record)
+	for r := range m.replicas {
+		if !r.enqueue(record) {
+			m.logger.Info("Disconnecting replica that fell too far behind", map[string]string{"replica": r.conn.RemoteAddr().String()})
+			delete(m.replicas, r)
+			r.conn.Close()
+		}
+	}
+}
+
+// Turn a client connection into a follower that starts from a full copy of the keyspace
+// The snapshot must be taken at the same point of the command stream as this call,
+// i.e. while no other command runs, so the follower neither misses nor repeats a write
+func (m *Manager) AddReplica(conn net.Conn, listeningPort string, snapshot map[string]store.Entry) *Replica {
+	r := newReplica(conn, listeningPort, STATE_SEND_BULK)
+
+	m.mu.Lock()
+	m.replicas[r] = struct{}{}
+	replid, offset := m.replid, m.offset
+	m.mu.Unlock()
+
+	m.logger.Info("Starting full resynchronization with replica", map[string]string{"replica": conn.RemoteAddr().String()})
+	go m.send(r, fmt.Appendf(nil, "+FULLRESYNC %s %d\r\n", replid, offset), snapshot)
+	return r
+}
+
+// Turn a client connection into a follower that continues where it left off
+// offset is the first byte of the stream the follower is missing, counted from 1 like Redis does
+// Return nil if the follower has to do a full resync instead
+func (m *Manager) TryPartialSync(conn net.Conn, listeningPort string, replid string, offset int64) *Replica {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Either our own history or the one of the leader we were promoted from, up to where we diverged
+	if replid != m.replid && (replid != m.replid2 || offset > m.secondOffset) {
+		return nil
+	}
+	missing, ok := m.backlog.since(offset - 1)
+	if !ok {
+		return nil
+	}
+
+	r := newReplica(conn, listeningPort, STATE_ONLINE)
+	r.pending = missing
+	m.replicas[r] = struct{}{}
+
+	m.logger.Info("Partial resynchronization accepted", map[string]string{
+		"replica": conn.RemoteAddr().String(),
+		"bytes":   fmt.Sprint(len(missing)),
+	})
+	go m.send(r, fmt.Appendf(nil, "+CONTINUE %s\r\n", m.replid), nil)
+	return r
+}
+
+func (m *Manager) RemoveReplica(r *Replica) {
+	m.mu.Lock()
+	delete(m.replicas, r)
+	m.mu.Unlock()
+
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	r.signal()
+}
+
+// Record the offset a follower says it has processed
+func (m *Manager) Ack(r *Replica, offset int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ackOffset = offset
+	r.ackTime = time.Now()
+}
+
+func newReplica(conn net.Conn, listeningPort string, state string) *Replica {
+	return &Replica{
+		conn:          conn,
+		listeningPort: listeningPort,
+		state:         state,
+		ackTime:       time.Now(),
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// Write the header and the snapshot if any, then keep streaming commands until the follower goes away
+func (m *Manager) send(r *Replica, header []byte, snapshot map[string]store.Entry) {
+	defer r.conn.Close()
+
+	if snapshot != nil {
+		var payload bytes.Buffer
+		if err := rdb.Encode(&payload, snapshot); err != nil {
+			m.logger.Error(err, map[string]string{"replica": r.conn.RemoteAddr().String()})
+			return
+		}
+		// Same framing as a bulk string but without the trailing CRLF
+		header = fmt.Appendf(header, "$%d\r\n", payload.Len())
+		header = append(header, payload.Bytes()...)
+	}
+	if _, err := r.conn.Write(header); err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	r.state = STATE_ONLINE
+	r.mu.Unlock()
+	m.logger.Info("Synchronization with replica succeeded", map[string]string{"replica": r.conn.RemoteAddr().String()})
+
+	// Anything already pending, e.g. the backlog of a partial resync, goes out right away
+	r.signal()
+	for range r.wake {
+		r.mu.Lock()
+		buf, closed := r.pending, r.closed
+		r.pending = nil
+		r.mu.Unlock()
+
+		if closed {
+			return
+		}
+		if _, err := r.conn.Write(buf); err != nil {
+			return
+		}
+	}
+}
+
+func (r *Replica) enqueue(record []byte) bool {
+	r.mu.Lock()
+	if len(r.pending)+len(record) > MAX_PENDING_BYTES {
+		r.mu.Unlock()
+		return false
+	}
+	r.pending = append(r.pending, record...)
+	r.mu.Unlock()
+	r.signal()
+	return true
+}
+
+func (r *Replica) signal() {
+	select {
+	case r.wake <- struct{}{}:
+	default: // The sender is already due to wake up
+	}
+}
+
+func (r *Replica) info(now time.Time) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return fmt.Sprintf("ip=%s,port=%s,state=%s,offset=%d,lag=%d",
+		hostOf(r.conn), r.listeningPort, r.state, r.ackOffset, int64(now.Sub(r.ackTime).Seconds()))
+}
@@ -0,0 +1,191 @@
+package replication
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/logger"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+)
+
+const (
+	ROLE_MASTER  = "master"
+	ROLE_REPLICA = "slave" // Name used by INFO replication
+)
+
+// How often the leader pings its followers so they can tell the link is alive
+const PING_PERIOD = 10 * time.Second
+
+// What a follower needs from the server to mirror its leader
+type Target interface {
+	// Swap the whole keyspace with the snapshot sent by the leader
+	Load(snapshot map[string]store.Entry)
+	// Execute the command stream coming from the leader
+	// applied is called with the number of bytes of complete commands executed so far
+	Apply(r io.Reader, applied func(n int64)) error
+}
+
+// Both sides of leader-follower replication
+// Every server is a leader, some of them also follow another one
+//
+// The replication ID names a history of the keyspace and the offset tells how far into that history we are.
+// A follower adopts the ID and offset of its leader, so the pair is also what it uses to ask for a partial resync.
+type Manager struct {
+	port   string // Announced to our leader so it knows where we listen
+	logger *logger.Logger
+	target Target
+
+	mu       sync.Mutex // Guard the fields below
+	replid   string
+	offset   int64 // Bytes of command stream produced (or applied, for a follower) so far
+	backlog  *backlog
+	replicas map[*Replica]struct{}
+	link     *link // Nil unless we follow another server
+
+	// Credentials sent to our leader before anything else, when it wants a password
+	masterUser string
+	masterAuth string
+	// TLS settings to dial our leader with, nil to dial in clear text
+	tlsConfig func(serverName string) *tls.Config
+
+	// History we inherited from our previous leader after a promotion
+	// Its followers can still partially resync with us up to secondOffset
+	replid2      string
+	secondOffset int64
+}
+
+func New(port string, logger *logger.Logger, target Target) *Manager {
+	m := &Manager{
+		port:         port,
+		logger:       logger,
+		target:       target,
+		replid:       newReplID(),
+		backlog:      newBacklog(BACKLOG_SIZE, 0),
+		replicas:     make(map[*Replica]struct{}),
+		replid2:      "0000000000000000000000000000000000000000",
+		secondOffset: -1,
+	}
+	go m.pingLoop()
+	return m
+}
+
+// Authenticate as user with password on the leaders we follow from now on
+// Without a user, the password is the one of the leader's default user
+func (m *Manager) SetAuth(user, password string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.masterUser, m.masterAuth = user, password
+}
+
+// Dial the leaders we follow from now on over TLS
+func (m *Manager) SetTLS(config func(serverName string) *tls.Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tlsConfig = config
+}
+
+func (m *Manager) IsReplica() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.link != nil
+}
+
+func (m *Manager) Offset() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.offset
+}
+
+// Lines of INFO replication in key:value form
+func (m *Manager) Info() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var lines []string
+	if m.link == nil {
+		lines = append(lines, "role:"+ROLE_MASTER)
+	} else {
+		lines = append(lines, "role:"+ROLE_REPLICA)
+		lines = append(lines, m.link.info(m.offset)...)
+	}
+
+	lines = append(lines, fmt.Sprintf("connected_slaves:%d", len(m.replicas)))
+	i := 0
+	for r := range m.replicas {
+		lines = append(lines, fmt.Sprintf("slave%d:%s", i, r.info(time.Now())))
+		i++
+	}
+	lines = append(lines,
+		"master_replid:"+m.replid,
+		"master_replid2:"+m.replid2,
+		fmt.Sprintf("master_repl_offset:%d", m.offset),
+		fmt.Sprintf("second_repl_offset:%d", m.secondOffset),
+		"repl_backlog_active:1",
+		fmt.Sprintf("repl_backlog_size:%d", len(m.backlog.buf)),
+		// Redis counts offsets from 1
+		fmt.Sprintf("repl_backlog_first_byte_offset:%d", m.backlog.start()+1),
+		fmt.Sprintf("repl_backlog_histlen:%d", m.backlog.histlen),
+	)
+	return lines
+}
+
+// Start a new history, e.g. once we stop following a leader
+// The old one is kept as the secondary ID so the followers we share with our former leader can continue
+func (m *Manager) shiftReplID() {
+	m.replid2 = m.replid
+	m.secondOffset = m.offset + 1
+	m.replid = newReplID()
+}
+
+// Adopt the history of our leader after a full resync
+func (m *Manager) resetReplID(replid string, offset int64) {
+	m.replid = replid
+	m.offset = offset
+	m.replid2 = "0000000000000000000000000000000000000000"
+	m.secondOffset = -1
+	m.backlog = newBacklog(BACKLOG_SIZE, offset)
+}
+
+// Our followers are based on a history that is not ours anymore, they have to sync again
+func (m *Manager) dropReplicas() {
+	for r := range m.replicas {
+		delete(m.replicas, r)
+		r.conn.Close()
+	}
+}
+
+// 40 random hex characters, like Redis run IDs
+func newReplID() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (m *Manager) pingLoop() {
+	ticker := time.NewTicker(PING_PERIOD)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.mu.Lock()
+		// A follower forwards the pings of its own leader instead
+		if len(m.replicas) > 0 && m.link == nil {
+			m.feed(resp.AppendArray(nil, []string{"PING"}))
+		}
+		m.mu.Unlock()
+	}
+}
+
+// Remote address without the port
+func hostOf(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
@@ -0,0 +1,206 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"strconv"
+)
+
+type Reader struct {
+	r *bufio.Reader
+}
+
+// Share the buffered reader so bytes read ahead are not lost to other readers of r
+func NewReader(r *bufio.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// Decode the next value, whatever its type
+func (r *Reader) ReadValue() (Value, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return Value{}, err
+	}
+	if len(line) == 0 {
+		return Value{}, protocolError("empty line")
+	}
+
+	v := Value{Type: line[0]}
+	body := string(line[1:])
+	switch v.Type {
+	case SIMPLE_STRING, ERROR, BIG_NUMBER:
+		v.Str = body
+	case INTEGER:
+		if v.Int, err = strconv.ParseInt(body, 10, 64); err != nil {
+			return Value{}, protocolError("invalid integer")
+		}
+	case DOUBLE:
+		if v.Float, err = parseDouble(body); err != nil {
+			return Value{}, protocolError("invalid double")
+		}
+	case BOOLEAN:
+		if body != "t" && body != "f" {
+			return Value{}, protocolError("invalid boolean")
+		}
+		v.Bool = body == "t"
+	case NULL:
+		if body != "" {
+			return Value{}, protocolError("invalid null")
+		}
+		v.Null = true
+	case BULK_STRING, BLOB_ERROR, VERBATIM_STRING:
+		n, err := parseLen(body, MAX_BULK_LEN)
+		if err != nil || (n == -1 && v.Type != BULK_STRING) {
+			return Value{}, protocolError("invalid bulk length")
+		}
+		if n == -1 {
+			v.Null = true
+			break
+		}
+		if v.Str, err = r.readBulk(n); err != nil {
+			return Value{}, err
+		}
+		// txt:payload
+		if v.Type == VERBATIM_STRING {
+			if len(v.Str) < 4 || v.Str[3] != ':' {
+				return Value{}, protocolError("invalid verbatim string")
+			}
+			v.Str = v.Str[4:]
+		}
+	case ARRAY, SET, PUSH, MAP, ATTRIBUTE:
+		n, err := parseLen(body, MAX_MULTIBULK_LEN)
+		if err != nil || (n == -1 && v.Type != ARRAY) {
+			return Value{}, protocolError("invalid multibulk length")
+		}
+		if n == -1 {
+			v.Null = true
+			break
+		}
+		if v.Type == MAP || v.Type == ATTRIBUTE {
+			n *= 2
+		}
+		v.Array = make([]Value, n)
+		for i := range v.Array {
+			if v.Array[i], err = r.ReadValue(); err != nil {
+				return Value{}, err
+			}
+		}
+		// An attribute only decorates the value that follows it
+		if v.Type == ATTRIBUTE {
+			next, err := r.ReadValue()
+			if err != nil {
+				return Value{}, err
+			}
+			next.Attrs = v.Array
+			return next, nil
+		}
+	default:
+		return Value{}, protocolError("unexpected type '%c'", v.Type)
+	}
+	return v, nil
+}
+
+// Decode a command as clients send it: an array of bulk strings
+// An empty or null array is a command without arguments, which callers skip
+func (r *Reader) ReadCommand() ([]string, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != ARRAY {
+		return nil, protocolError("expected '*', got '%s'", firstByte(line))
+	}
+	n, err := parseLen(string(line[1:]), MAX_MULTIBULK_LEN)
+	if err != nil {
+		return nil, protocolError("invalid multibulk length")
+	}
+
+	args := make([]string, 0, max(n, 0))
+	for range n {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != BULK_STRING {
+			return nil, protocolError("expected '$', got '%s'", firstByte(line))
+		}
+		size, err := parseLen(string(line[1:]), MAX_BULK_LEN)
+		if err != nil || size < 0 {
+			return nil, protocolError("invalid bulk length")
+		}
+		arg, err := r.readBulk(size)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// Line without its CRLF, which must be there
+func (r *Reader) readLine() ([]byte, error) {
+	line, err := r.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, protocolError("too big line")
+	}
+	if err != nil {
+		if err == io.EOF && len(line) > 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, protocolError("expected CRLF at the end of the line")
+	}
+	return line[:len(line)-2], nil
+}
+
+// n bytes followed by CRLF
+// The buffer grows as the payload arrives instead of trusting the announced length
+// so a peer can't make us allocate MAX_BULK_LEN with a header alone
+func (r *Reader) readBulk(n int) (string, error) {
+	var buf bytes.Buffer
+	buf.Grow(min(n+2, BULK_CHUNK))
+	if _, err := io.CopyN(&buf, r.r, int64(n+2)); err != nil {
+		if err == io.EOF {
+			return "", io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	b := buf.Bytes()
+	if b[n] != '\r' || b[n+1] != '\n' {
+		return "", protocolError("expected CRLF after bulk string")
+	}
+	return string(b[:n]), nil
+}
+
+// Length of a bulk string or array, -1 for null, never above limit
+func parseLen(s string, limit int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < -1 || n > limit {
+		return 0, strconv.ErrRange
+	}
+	return n, nil
+}
+
+// inf, -inf and nan are spelled out
+func parseDouble(s string) (float64, error) {
+	switch s {
+	case "inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	case "nan":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func firstByte(line []byte) string {
+	if len(line) == 0 {
+		return ""
+	}
+	return string(line[:1])
+}
@@ -0,0 +1,69 @@
+// Package resp reads and writes the Redis serialization protocol, versions 2 and 3
+// https://redis.io/docs/latest/develop/reference/protocol-spec/
+package resp
+
+import "fmt"
+
+// Protocol versions a client can pick with HELLO
+const (
+	RESP2 = 2
+	RESP3 = 3
+)
+
+// Type byte that starts every value
+const (
+	SIMPLE_STRING = '+'
+	ERROR         = '-'
+	INTEGER       = ':'
+	BULK_STRING   = '$'
+	ARRAY         = '*'
+
+	// RESP3 only
+	NULL            = '_'
+	DOUBLE          = ','
+	BOOLEAN         = '#'
+	BLOB_ERROR      = '!'
+	VERBATIM_STRING = '='
+	BIG_NUMBER      = '('
+	MAP             = '%'
+	SET             = '~'
+	ATTRIBUTE       = '|'
+	PUSH            = '>'
+)
+
+// Same limits as Redis: proto-max-bulk-len and the longest multibulk it accepts
+const (
+	MAX_BULK_LEN      = 512 << 20
+	MAX_MULTIBULK_LEN = 1024 * 1024
+	BULK_CHUNK        = 64 * 1024 // Most a bulk string reserves before its payload arrives
+)
+
+// A decoded value
+// Str holds every kind of string and error as well as big numbers, verbatim strings without their format,
+// Int integers, Float doubles, Bool booleans and Array the elements of aggregates
+// Maps and attributes are flattened into Array as key, value, key, value...
+// Null is set for _ as well as the RESP2 null bulk string $-1 and null array *-1
+type Value struct {
+	Type  byte
+	Str   string
+	Int   int64
+	Float float64
+	Bool  bool
+	Array []Value
+	Null  bool
+	Attrs []Value // Attribute sent right before the value, if any
+}
+
+// Malformed input, the connection can't be trusted after it
+// Its message is what Redis replies with before closing the connection
+type ProtocolError struct {
+	Msg string
+}
+
+func (e *ProtocolError) Error() string {
+	return "Protocol error: " + e.Msg
+}
+
+func protocolError(format string, args ...any) error {
+	return &ProtocolError{Msg: fmt.Sprintf(format, args...)}
+}
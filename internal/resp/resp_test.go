@@ -0,0 +1,178 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func newReader(input string) *Reader {
+	return NewReader(bufio.NewReader(strings.NewReader(input)))
+}
+
+func TestReadValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Value
+	}{
+		{"Simple string", "+OK\r\n", Value{Type: SIMPLE_STRING, Str: "OK"}},
+		{"Error", "-ERR boom\r\n", Value{Type: ERROR, Str: "ERR boom"}},
+		{"Integer", ":-42\r\n", Value{Type: INTEGER, Int: -42}},
+		{"Bulk string", "$5\r\nhello\r\n", Value{Type: BULK_STRING, Str: "hello"}},
+		{"Bulk string with CRLF inside", "$4\r\na\r\nb\r\n", Value{Type: BULK_STRING, Str: "a\r\nb"}},
+		{"Empty bulk string", "$0\r\n\r\n", Value{Type: BULK_STRING}},
+		{"Null bulk string", "$-1\r\n", Value{Type: BULK_STRING, Null: true}},
+		{"Null array", "*-1\r\n", Value{Type: ARRAY, Null: true}},
+		{"Nested array", "*2\r\n:1\r\n*1\r\n$1\r\na\r\n", Value{Type: ARRAY, Array: []Value{
+			{Type: INTEGER, Int: 1},
+			{Type: ARRAY, Array: []Value{{Type: BULK_STRING, Str: "a"}}},
+		}}},
+		{"Null", "_\r\n", Value{Type: NULL, Null: true}},
+		{"Double", ",-1.5\r\n", Value{Type: DOUBLE, Float: -1.5}},
+		{"Infinite double", ",inf\r\n", Value{Type: DOUBLE, Float: math.Inf(1)}},
+		{"Boolean", "#t\r\n", Value{Type: BOOLEAN, Bool: true}},
+		{"Big number", "(3492890328409238509324850943850943825024385\r\n", Value{Type: BIG_NUMBER, Str: "3492890328409238509324850943850943825024385"}},
+		{"Blob error", "!5\r\nERR x\r\n", Value{Type: BLOB_ERROR, Str: "ERR x"}},
+		{"Verbatim string", "=9\r\ntxt:hello\r\n", Value{Type: VERBATIM_STRING, Str: "hello"}},
+		{"Map", "%1\r\n+a\r\n:1\r\n", Value{Type: MAP, Array: []Value{{Type: SIMPLE_STRING, Str: "a"}, {Type: INTEGER, Int: 1}}}},
+		{"Set", "~1\r\n+a\r\n", Value{Type: SET, Array: []Value{{Type: SIMPLE_STRING, Str: "a"}}}},
+		{"Push", ">2\r\n+message\r\n+hi\r\n", Value{Type: PUSH, Array: []Value{{Type: SIMPLE_STRING, Str: "message"}, {Type: SIMPLE_STRING, Str: "hi"}}}},
+		{"Attribute", "|1\r\n+ttl\r\n:10\r\n:1\r\n", Value{Type: INTEGER, Int: 1, Attrs: []Value{{Type: SIMPLE_STRING, Str: "ttl"}, {Type: INTEGER, Int: 10}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newReader(tt.input).ReadValue()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestReadCommand(t *testing.T) {
+	got, err := newReader("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$11\r\nhello world\r\n").ReadCommand()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"SET", "key", "hello world"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestReadCommandErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string // Message of the protocol error, empty for a truncated command
+	}{
+		{"Bad multibulk length", "*abc\r\n", "invalid multibulk length"},
+		{"Multibulk length too big", "*1048577\r\n", "invalid multibulk length"},
+		{"Integer instead of bulk", "*1\r\n:1\r\n", "expected '$', got ':'"},
+		{"Bad bulk length", "*1\r\n$x\r\n", "invalid bulk length"},
+		{"Null bulk in a command", "*1\r\n$-1\r\n", "invalid bulk length"},
+		{"Negative bulk length", "*1\r\n$-2\r\n", "invalid bulk length"},
+		{"Bulk longer than announced", "*1\r\n$3\r\nPINGX\r\n", "expected CRLF after bulk string"},
+		{"Missing CR", "*1\n", "expected CRLF at the end of the line"},
+		{"Truncated bulk", "*1\r\n$4\r\nPI", ""},
+		{"Truncated line", "*1\r\n$4", ""},
+		{"Truncated huge bulk", "*1\r\n$536870912\r\nPI", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newReader(tt.input).ReadCommand()
+			if tt.expected == "" {
+				if !errors.Is(err, io.ErrUnexpectedEOF) {
+					t.Errorf("Expected %v, got %v", io.ErrUnexpectedEOF, err)
+				}
+				return
+			}
+			var protoErr *ProtocolError
+			if !errors.As(err, &protoErr) {
+				t.Fatalf("Expected a protocol error, got %v", err)
+			}
+			if protoErr.Msg != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, protoErr.Msg)
+			}
+		})
+	}
+}
+
+func TestReadBulkGrowsWithPayload(t *testing.T) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := newReader("$536870912\r\nPI").ReadValue()
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+	// Only the announced length is huge, the payload is two bytes
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("Expected at most %d bytes allocated, got %d", 1<<20, allocated)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		proto    int
+		write    func(w Writer)
+		expected string
+	}{
+		{"Simple string", RESP2, func(w Writer) { w.SimpleString("PONG") }, "+PONG\r\n"},
+		{"Error", RESP2, func(w Writer) { w.Error("ERR boom") }, "-ERR boom\r\n"},
+		{"Integer", RESP2, func(w Writer) { w.Integer(-7) }, ":-7\r\n"},
+		{"Bulk string", RESP2, func(w Writer) { w.Bulk("hello") }, "$5\r\nhello\r\n"},
+		{"Null", RESP2, func(w Writer) { w.Null() }, "$-1\r\n"},
+		{"Array", RESP2, func(w Writer) { w.Array([]string{"a", ""}) }, "*2\r\n$1\r\na\r\n$0\r\n\r\n"},
+		{"Array header", RESP2, func(w Writer) { w.ArrayHeader(2); w.Integer(1); w.Null() }, "*2\r\n:1\r\n$-1\r\n"},
+		{"Null array", RESP2, func(w Writer) { w.NullArray() }, "*-1\r\n"},
+		{"Double as bulk", RESP2, func(w Writer) { w.Double(1.5) }, "$3\r\n1.5\r\n"},
+		{"Verbatim as bulk", RESP2, func(w Writer) { w.Verbatim("txt", "hi") }, "$2\r\nhi\r\n"},
+		{"Map as array", RESP2, func(w Writer) { w.Map([]string{"a", "1"}) }, "*2\r\n$1\r\na\r\n$1\r\n1\r\n"},
+		{"Set as array", RESP2, func(w Writer) { w.Set([]string{"a"}) }, "*1\r\n$1\r\na\r\n"},
+		{"Push as array", RESP2, func(w Writer) { w.PushHeader(1); w.Bulk("a") }, "*1\r\n$1\r\na\r\n"},
+		{"RESP3 null", RESP3, func(w Writer) { w.Null() }, "_\r\n"},
+		{"RESP3 null array", RESP3, func(w Writer) { w.NullArray() }, "_\r\n"},
+		{"RESP3 double", RESP3, func(w Writer) { w.Double(math.Inf(-1)) }, ",-inf\r\n"},
+		{"RESP3 verbatim", RESP3, func(w Writer) { w.Verbatim("txt", "hi") }, "=6\r\ntxt:hi\r\n"},
+		{"RESP3 map", RESP3, func(w Writer) { w.Map([]string{"a", "1"}) }, "%1\r\n$1\r\na\r\n$1\r\n1\r\n"},
+		{"RESP3 set", RESP3, func(w Writer) { w.Set([]string{"a"}) }, "~1\r\n$1\r\na\r\n"},
+		{"RESP3 push", RESP3, func(w Writer) { w.PushHeader(1); w.Bulk("a") }, ">1\r\n$1\r\na\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.write(NewWriter(&buf, tt.proto))
+			if buf.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, buf.String())
+			}
+
+			// Whatever we write must read back
+			if _, err := newReader(buf.String()).ReadValue(); err != nil {
+				t.Errorf("Unexpected error reading back %q: %v", buf.String(), err)
+			}
+		})
+	}
+}
+
+func TestAppendArray(t *testing.T) {
+	got := string(AppendArray([]byte("+OK\r\n"), []string{"SET", "key", "hello world"}))
+	expected := "+OK\r\n*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$11\r\nhello world\r\n"
+	if got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,190 @@
+package resp
+
+import (
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Typed reply writer, every method writes one complete value
+// except the headers of aggregates whose elements follow
+// RESP3 types are downgraded to their RESP2 equivalent for RESP2 clients
+type Writer struct {
+	w     io.Writer
+	proto int
+}
+
+func NewWriter(w io.Writer, proto int) Writer {
+	return Writer{w: w, proto: proto}
+}
+
+// +s, s must not contain CR or LF
+func (w Writer) SimpleString(s string) {
+	w.w.Write(append(append([]byte{SIMPLE_STRING}, s...), '\r', '\n'))
+}
+
+// -msg, msg carries its own prefix, e.g. ERR or WRONGTYPE
+// Line breaks, e.g. from arguments quoted in the message, are turned into spaces so the reply stays on one line
+func (w Writer) Error(msg string) {
+	msg = strings.NewReplacer("\r", " ", "\n", " ").Replace(msg)
+	w.w.Write(append(append([]byte{ERROR}, msg...), '\r', '\n'))
+}
+
+func (w Writer) Integer(n int64) {
+	w.w.Write(AppendInteger(nil, n))
+}
+
+func (w Writer) Bulk(s string) {
+	w.w.Write(AppendBulk(nil, s))
+}
+
+// Null, e.g. for a missing key, $-1 in RESP2
+func (w Writer) Null() {
+	if w.proto == RESP3 {
+		w.w.Write([]byte("_\r\n"))
+		return
+	}
+	w.w.Write([]byte("$-1\r\n"))
+}
+
+// Double, e.g. a score, a bulk string in RESP2
+func (w Writer) Double(f float64) {
+	if w.proto == RESP3 {
+		w.w.Write(append(append([]byte{DOUBLE}, formatDouble(f)...), '\r', '\n'))
+		return
+	}
+	w.Bulk(formatDouble(f))
+}
+
+// Text meant for humans, e.g. INFO, format is three letters like txt or mkd
+// Bulk string in RESP2
+func (w Writer) Verbatim(format string, s string) {
+	if w.proto == RESP3 {
+		buf := appendHeader(nil, VERBATIM_STRING, len(format)+1+len(s))
+		buf = append(buf, format...)
+		buf = append(buf, ':')
+		buf = append(buf, s...)
+		w.w.Write(append(buf, '\r', '\n'))
+		return
+	}
+	w.Bulk(s)
+}
+
+// Array of bulk strings
+func (w Writer) Array(items []string) {
+	w.w.Write(AppendArray(nil, items))
+}
+
+// Start an array of n elements written next
+func (w Writer) ArrayHeader(n int) {
+	w.w.Write(appendHeader(nil, ARRAY, n))
+}
+
+// Start a map of n key-value pairs written next, an array of 2n elements in RESP2
+func (w Writer) MapHeader(n int) {
+	if w.proto == RESP3 {
+		w.w.Write(appendHeader(nil, MAP, n))
+		return
+	}
+	w.ArrayHeader(2 * n)
+}
+
+// Map of bulk strings given as key, value, key, value...
+func (w Writer) Map(pairs []string) {
+	w.MapHeader(len(pairs) / 2)
+	for _, item := range pairs {
+		w.Bulk(item)
+	}
+}
+
+// Start a set of n members written next, an array in RESP2
+func (w Writer) SetHeader(n int) {
+	if w.proto == RESP3 {
+		w.w.Write(appendHeader(nil, SET, n))
+		return
+	}
+	w.ArrayHeader(n)
+}
+
+// Set of bulk strings
+func (w Writer) Set(members []string) {
+	buf := appendHeader(nil, ARRAY, len(members))
+	if w.proto == RESP3 {
+		buf[0] = SET
+	}
+	for _, member := range members {
+		buf = AppendBulk(buf, member)
+	}
+	w.w.Write(buf)
+}
+
+// Start an out-of-band message of n elements written next, an array in RESP2
+func (w Writer) PushHeader(n int) {
+	if w.proto == RESP3 {
+		w.w.Write(appendHeader(nil, PUSH, n))
+		return
+	}
+	w.ArrayHeader(n)
+}
+
+// Null array, e.g. for an aborted transaction, the same as Null in RESP3
+func (w Writer) NullArray() {
+	if w.proto == RESP3 {
+		w.Null()
+		return
+	}
+	w.w.Write([]byte("*-1\r\n"))
+}
+
+func AppendInteger(buf []byte, n int64) []byte {
+	buf = append(buf, INTEGER)
+	buf = strconv.AppendInt(buf, n, 10)
+	return append(buf, '\r', '\n')
+}
+
+func AppendBulk(buf []byte, s string) []byte {
+	buf = appendHeader(buf, BULK_STRING, len(s))
+	buf = append(buf, s...)
+	return append(buf, '\r', '\n')
+}
+
+// Array of bulk strings, which is also how commands are sent
+func AppendArray(buf []byte, items []string) []byte {
+	buf = appendHeader(buf, ARRAY, len(items))
+	for _, item := range items {
+		buf = AppendBulk(buf, item)
+	}
+	return buf
+}
+
+// Out-of-band message of bulk strings for the given protocol, e.g. a published message
+func AppendPush(buf []byte, proto int, items []string) []byte {
+	if proto != RESP3 {
+		return AppendArray(buf, items)
+	}
+	buf = appendHeader(buf, PUSH, len(items))
+	for _, item := range items {
+		buf = AppendBulk(buf, item)
+	}
+	return buf
+}
+
+func appendHeader(buf []byte, kind byte, n int) []byte {
+	buf = append(buf, kind)
+	buf = strconv.AppendInt(buf, int64(n), 10)
+	return append(buf, '\r', '\n')
+}
+
+// Shortest representation that reads back as the same double, like Redis does
+func formatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
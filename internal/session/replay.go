@@ -0,0 +1,77 @@
+package session
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/command"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/parser"
+)
+
+// Stand-in for a client connection
+// Commands are read from a file and replies go nowhere
+type replayConn struct {
+	net.Conn
+	r io.Reader
+	n int64 // Bytes read so far
+}
+
+func (c *replayConn) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *replayConn) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// Execute every command stored in r as if a client sent them
+// Return the number of bytes that made up complete commands
+// applied, if not nil, is told that number after each command
+func Replay(r io.Reader, srv *command.Server, applied func(n int64)) (int64, error) {
+	conn := &replayConn{r: r}
+	p := parser.NewParser(conn, srv.Logger)
+
+	var n int64
+	var batch []command.Command // Commands of a MULTI block, nil outside of one
+	for {
+		cmd, err := p.Command(srv.Logger)
+		consumed := conn.n - int64(p.Buffered())
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				// Stopping right at a command boundary is a clean end of file
+				if consumed == n {
+					return n, nil
+				}
+				return n, io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+
+		// A transaction only counts as applied once its EXEC is in
+		name := ""
+		if len(cmd.Args) > 0 {
+			name = strings.ToUpper(cmd.Args[0])
+		}
+		switch {
+		case name == command.MULTI:
+			batch = []command.Command{}
+			continue
+		case name == command.EXEC && batch != nil:
+			command.Transaction(srv, batch)
+			batch = nil
+		case batch != nil:
+			batch = append(batch, cmd)
+			continue
+		default:
+			cmd.Handle(srv)
+		}
+		n = consumed
+		if applied != nil {
+			applied(n)
+		}
+	}
+}
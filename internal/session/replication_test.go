@@ -0,0 +1,137 @@
+package session
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/acl"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/command"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/logger"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/replication"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+)
+
+// Applies what the leader sends the way the server binary does
+type target struct {
+	srv *command.Server
+}
+
+func (t *target) Load(snapshot map[string]store.Entry) {
+	t.srv.Lock()
+	defer t.srv.Unlock()
+	t.srv.Store.Replace(snapshot)
+}
+
+func (t *target) Apply(r io.Reader, applied func(n int64)) error {
+	_, err := Replay(r, t.srv, applied)
+	return err
+}
+
+// Start a server on loopback and return its address
+func listen(t *testing.T) (*command.Server, *net.TCPAddr) {
+	t.Helper()
+	log := logger.New(io.Discard, logger.LoggerConfig{MinLevel: logger.LevelOff})
+	srv := &command.Server{Logger: log, Store: store.NewInMemoryStore(), ACL: acl.New(command.ACLCatalog)}
+	srv.Replication = replication.New("0", log, &target{srv: srv})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go Start(conn, srv)
+		}
+	}()
+	return srv, listener.Addr().(*net.TCPAddr)
+}
+
+// Connect to a server and return a function sending it an inline command
+// and returning the raw reply, which is a single line or a bulk string
+func dial(t *testing.T, addr *net.TCPAddr) func(args ...string) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	r := bufio.NewReader(conn)
+	return func(args ...string) string {
+		t.Helper()
+		conn.SetDeadline(time.Now().Add(time.Second))
+		if _, err := fmt.Fprintf(conn, "%s\r\n", strings.Join(args, " ")); err != nil {
+			t.Fatal(err)
+		}
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if line[0] != '$' || err != nil || n < 0 {
+			return line
+		}
+		bulk := make([]byte, n+2)
+		if _, err := io.ReadFull(r, bulk); err != nil {
+			t.Fatal(err)
+		}
+		return line + string(bulk)
+	}
+}
+
+// Poll until the reply to the command is the expected one
+func eventually(t *testing.T, do func(args ...string) string, expected string, args ...string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := do(args...)
+		if got == expected {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %q for %q, got %q", expected, args, got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFollowLeader(t *testing.T) {
+	_, leaderAddr := listen(t)
+	_, followerAddr := listen(t)
+	leader, follower := dial(t, leaderAddr), dial(t, followerAddr)
+
+	// Written before the follower connects, so only the snapshot carries it
+	leader("SET", "a", "1")
+	follower("SET", "stale", "x")
+
+	if got := follower("REPLICAOF", leaderAddr.IP.String(), strconv.Itoa(leaderAddr.Port)); got != "+OK\r\n" {
+		t.Fatalf("Expected OK, got %q", got)
+	}
+	eventually(t, follower, "$1\r\n1\r\n", "GET", "a")
+	// The snapshot replaces the whole keyspace
+	eventually(t, follower, "$-1\r\n", "GET", "stale")
+
+	// Then the stream carries every write
+	leader("SET", "b", "2")
+	leader("DEL", "a")
+	eventually(t, follower, "$1\r\n2\r\n", "GET", "b")
+	eventually(t, follower, "$-1\r\n", "GET", "a")
+
+	// Clients may read from a follower but not write to it
+	if got := follower("SET", "c", "3"); got != "-READONLY You can't write against a read only replica.\r\n" {
+		t.Errorf("Expected a READONLY error, got %q", got)
+	}
+	if got := follower("GET", "c"); got != "$-1\r\n" {
+		t.Errorf("Expected c to be missing, got %q", got)
+	}
+}
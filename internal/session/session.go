@@ -1,21 +1,35 @@
 package session
 
 import (
+	"errors"
 	"fmt"
 	"net"
 
+	"gitlab.com/phamhonganh12062000/smolredis/internal/command"
 	"gitlab.com/phamhonganh12062000/smolredis/internal/logger"
 	"gitlab.com/phamhonganh12062000/smolredis/internal/parser"
-	"gitlab.com/phamhonganh12062000/smolredis/internal/store"
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
 )
 
+// A parsed command, or the error that ended the stream of commands
+type request struct {
+	cmd command.Command
+	err error
+}
+
 // Handle the client's session
 // Parse and execute commands
 // Then write responses back to the client
-func Start(conn net.Conn, logger *logger.Logger, store *store.InMemoryStore) {
+func Start(conn net.Conn, srv *command.Server) {
+	logger := srv.Logger
+	client := command.NewClient(conn)
+	srv.Connect(client)
+	stop := make(chan struct{})
 	// Ensure the connection will ALWAYS be closed
 	defer func() {
 		logger.Info("Closing connection", map[string]string{"connection": conn.LocalAddr().String()})
+		close(stop)
+		srv.Disconnect(client)
 		conn.Close()
 	}()
 
@@ -27,18 +41,64 @@ func Start(conn net.Conn, logger *logger.Logger, store *store.InMemoryStore) {
 		}
 	}()
 
-	p := parser.NewParser(conn, logger)
+	// Commands are read on their own goroutine so we notice the client leaving
+	// even while we are busy waiting on its behalf, e.g. for BLPOP
+	requests := make(chan request)
+	go read(parser.NewParser(conn, logger), logger, client, requests, stop)
+
+	for {
+		select {
+		// Messages are written from here too so they never land in the middle of a reply
+		case msg := <-client.Messages():
+			if _, err := conn.Write(msg.Encode(client.Protocol())); err != nil {
+				return
+			}
+		case req, ok := <-requests:
+			if !ok {
+				return
+			}
+			if req.err != nil {
+				// Like Redis, tell the client what was wrong with its input before hanging up
+				var protoErr *resp.ProtocolError
+				if errors.As(req.err, &protoErr) {
+					logger.Error(fmt.Errorf("Error: %s", req.err), nil)
+					resp.NewWriter(conn, client.Protocol()).Error("ERR " + protoErr.Error())
+				}
+				return
+			}
+			cmd := req.cmd
+			cmd.Client = client
+			// End of a session
+			if !cmd.Handle(srv) {
+				return
+			}
+		}
+	}
+}
+
+// Parse commands until the connection breaks or the session stops
+func read(p *parser.Parser, logger *logger.Logger, client *command.Client, requests chan<- request, stop <-chan struct{}) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error(fmt.Errorf("Error: %s", err), nil)
+		}
+		client.MarkDisconnected()
+		close(requests)
+	}()
 
 	for {
 		cmd, err := p.Command(logger)
 		if err != nil {
-			logger.Error(fmt.Errorf("Error: %s", err), nil)
-			conn.Write([]uint8("-ERR " + err.Error() + "\r\n"))
-			break
+			// The session may be stuck waiting for something, let it know first
+			client.MarkDisconnected()
 		}
-		// End of a session
-		if !cmd.Handle(logger, store) {
-			break
+		select {
+		case requests <- request{cmd: cmd, err: err}:
+		case <-stop:
+			return
+		}
+		if err != nil {
+			return
 		}
 	}
 }
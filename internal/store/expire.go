@@ -0,0 +1,38 @@
+package store
+
+import "time"
+
+// Reclaim the expired keys among up to n keys with an expiration time picked at random
+// Return how many keys were looked at and how many of them were reclaimed
+// so the caller can tell whether another round is worth it, like the active expire cycle of Redis
+func (s *InMemoryStore) ExpireSample(n int) (sampled, expired int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	// Map iteration starts at a random position, which is all the randomness we need
+	for key := range s.expires {
+		if sampled == n {
+			break
+		}
+		sampled++
+		if s.data[key].expired(now) {
+			s.expire(key)
+			expired++
+		}
+	}
+	return sampled, expired
+}
+
+// Number of keys with an expiration time, including the expired ones that have not been reclaimed yet
+func (s *InMemoryStore) Expiring() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.expires)
+}
+
+// Number of keys reclaimed since the start or the last ResetStats because their time was up
+func (s *InMemoryStore) Expired() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.expired
+}
@@ -0,0 +1,78 @@
+package store
+
+import "maps"
+
+// Field-value pairs stored under a single key
+// Fields come back in no particular order, like in Redis
+type Hash struct {
+	fields map[string]string
+	bytes  int // Total length of the fields and values, for memory accounting
+}
+
+func NewHash() *Hash {
+	return &Hash{fields: make(map[string]string)}
+}
+
+func (h *Hash) Len() int {
+	return len(h.fields)
+}
+
+func (h *Hash) Get(field string) (string, bool) {
+	v, ok := h.fields[field]
+	return v, ok
+}
+
+// Return true if the field did not exist before
+func (h *Hash) Set(field, v string) bool {
+	old, exists := h.fields[field]
+	if !exists {
+		h.bytes += len(field)
+	}
+	h.bytes += len(v) - len(old)
+	h.fields[field] = v
+	return !exists
+}
+
+func (h *Hash) Delete(field string) bool {
+	v, ok := h.fields[field]
+	if !ok {
+		return false
+	}
+	h.bytes -= len(field) + len(v)
+	delete(h.fields, field)
+	return true
+}
+
+// Fields and values interleaved, i.e. field1 value1 field2 value2 ...
+func (h *Hash) Pairs() []string {
+	out := make([]string, 0, 2*len(h.fields))
+	for f, v := range h.fields {
+		out = append(out, f, v)
+	}
+	return out
+}
+
+func (h *Hash) Keys() []string {
+	out := make([]string, 0, len(h.fields))
+	for f := range h.fields {
+		out = append(out, f)
+	}
+	return out
+}
+
+func (h *Hash) Values() []string {
+	out := make([]string, 0, len(h.fields))
+	for _, v := range h.fields {
+		out = append(out, v)
+	}
+	return out
+}
+
+func (h *Hash) Clone() any {
+	return &Hash{fields: maps.Clone(h.fields), bytes: h.bytes}
+}
+
+// Approximate number of bytes held by the hash
+func (h *Hash) Size() int {
+	return len(h.fields)*HASH_FIELD_OVERHEAD + h.bytes
+}
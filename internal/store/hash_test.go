@@ -0,0 +1,80 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHashSetDelete(t *testing.T) {
+	h := NewHash()
+	if !h.Set("a", "1") || !h.Set("b", "2") {
+		t.Error("Expected new fields to be counted")
+	}
+	if h.Set("a", "3") {
+		t.Error("Expected overwriting a field to return false")
+	}
+	if h.Len() != 2 {
+		t.Fatalf("Expected 2 fields, got %d", h.Len())
+	}
+	if v, _ := h.Get("a"); v != "3" {
+		t.Errorf("Expected 3, got %s", v)
+	}
+	if !h.Delete("a") || h.Delete("a") || h.Delete("missing") {
+		t.Error("Expected a to be deleted exactly once")
+	}
+	if _, ok := h.Get("a"); ok {
+		t.Error("Expected a to be gone")
+	}
+	if !h.Delete("b") || h.Len() != 0 {
+		t.Errorf("Expected an empty hash, got %d fields", h.Len())
+	}
+}
+
+func TestHashPairs(t *testing.T) {
+	h := NewHash()
+	h.Set("a", "1")
+	h.Set("b", "2")
+
+	keys := h.Keys()
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"a", "b"}) {
+		t.Errorf("Expected [a b], got %v", keys)
+	}
+	values := h.Values()
+	slices.Sort(values)
+	if !slices.Equal(values, []string{"1", "2"}) {
+		t.Errorf("Expected [1 2], got %v", values)
+	}
+	// Each field is followed by its own value whatever the order
+	pairs := h.Pairs()
+	if len(pairs) != 4 {
+		t.Fatalf("Expected 4 items, got %v", pairs)
+	}
+	for i := 0; i < len(pairs); i += 2 {
+		if v, _ := h.Get(pairs[i]); v != pairs[i+1] {
+			t.Errorf("Expected %s after %s, got %s", v, pairs[i], pairs[i+1])
+		}
+	}
+}
+
+func TestHashSize(t *testing.T) {
+	h := NewHash()
+	h.Set("field", "value")
+	h.Set("field", "longer value")
+	h.Set("f", "")
+	expected := 2*HASH_FIELD_OVERHEAD + len("field") + len("longer value") + len("f")
+	if h.Size() != expected {
+		t.Errorf("Expected %d, got %d", expected, h.Size())
+	}
+
+	// The clone keeps its own fields and accounting
+	clone := h.Clone().(*Hash)
+	h.Delete("field")
+	h.Delete("f")
+	if h.Size() != 0 {
+		t.Errorf("Expected 0, got %d", h.Size())
+	}
+	if clone.Size() != expected || clone.Len() != 2 {
+		t.Errorf("Expected the clone to keep 2 fields and %d bytes, got %d and %d", expected, clone.Len(), clone.Size())
+	}
+}
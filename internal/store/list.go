@@ -0,0 +1,184 @@
+package store
+
+// Double-ended queue of strings backed by a ring buffer
+// Pushing and popping at both ends is O(1), so is access by index
+type List struct {
+	items []string
+	head  int // Position of the first element in items
+	size  int
+	bytes int // Total length of the elements, for memory accounting
+}
+
+func NewList() *List {
+	return &List{items: make([]string, 4)}
+}
+
+// Build a list holding the given values, first one at the head
+func ListOf(values ...string) *List {
+	l := &List{items: make([]string, max(len(values), 4))}
+	l.size = copy(l.items, values)
+	for _, v := range values {
+		l.bytes += len(v)
+	}
+	return l
+}
+
+func (l *List) Len() int {
+	return l.size
+}
+
+func (l *List) PushLeft(v string) {
+	l.grow()
+	l.head = (l.head - 1 + len(l.items)) % len(l.items)
+	l.items[l.head] = v
+	l.size++
+	l.bytes += len(v)
+}
+
+func (l *List) PushRight(v string) {
+	l.grow()
+	l.items[(l.head+l.size)%len(l.items)] = v
+	l.size++
+	l.bytes += len(v)
+}
+
+func (l *List) PopLeft() (string, bool) {
+	if l.size == 0 {
+		return "", false
+	}
+	v := l.items[l.head]
+	l.items[l.head] = "" // Let the GC reclaim the string
+	l.head = (l.head + 1) % len(l.items)
+	l.size--
+	l.bytes -= len(v)
+	return v, true
+}
+
+func (l *List) PopRight() (string, bool) {
+	if l.size == 0 {
+		return "", false
+	}
+	i := (l.head + l.size - 1) % len(l.items)
+	v := l.items[i]
+	l.items[i] = ""
+	l.size--
+	l.bytes -= len(v)
+	return v, true
+}
+
+// Element at position i, 0 <= i < Len()
+func (l *List) Index(i int) string {
+	return l.items[(l.head+i)%len(l.items)]
+}
+
+func (l *List) Set(i int, v string) {
+	i = (l.head + i) % len(l.items)
+	l.bytes += len(v) - len(l.items[i])
+	l.items[i] = v
+}
+
+// Approximate number of bytes held by the list
+func (l *List) Size() int {
+	return len(l.items)*STRING_OVERHEAD + l.bytes
+}
+
+// Elements from start to stop, both inclusive and already within bounds
+func (l *List) Range(start, stop int) []string {
+	if start > stop {
+		return []string{}
+	}
+	out := make([]string, 0, stop-start+1)
+	for i := start; i <= stop; i++ {
+		out = append(out, l.Index(i))
+	}
+	return out
+}
+
+func (l *List) Values() []string {
+	return l.Range(0, l.size-1)
+}
+
+// Keep only the elements from start to stop, both inclusive and already within bounds
+func (l *List) Trim(start, stop int) {
+	l.reset(l.Range(start, stop))
+}
+
+// Remove up to count occurrences of v, from the head when count > 0, from the tail when count < 0
+// and all of them when count == 0
+// Return how many were removed
+func (l *List) Remove(count int, v string) int {
+	values := l.Values()
+	kept := make([]string, 0, len(values))
+	removed := 0
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+
+	if count >= 0 {
+		for _, item := range values {
+			if item == v && (limit == 0 || removed < limit) {
+				removed++
+				continue
+			}
+			kept = append(kept, item)
+		}
+	} else {
+		for i := len(values) - 1; i >= 0; i-- {
+			if values[i] == v && removed < limit {
+				removed++
+				continue
+			}
+			kept = append(kept, values[i])
+		}
+		// We walked backwards
+		for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+			kept[i], kept[j] = kept[j], kept[i]
+		}
+	}
+
+	if removed > 0 {
+		l.reset(kept)
+	}
+	return removed
+}
+
+// Insert v right before or after the first occurrence of pivot
+// Return the new length, or -1 if pivot is not in the list
+func (l *List) Insert(pivot, v string, before bool) int {
+	values := l.Values()
+	for i, item := range values {
+		if item != pivot {
+			continue
+		}
+		if !before {
+			i++
+		}
+		values = append(values[:i], append([]string{v}, values[i:]...)...)
+		l.reset(values)
+		return l.size
+	}
+	return -1
+}
+
+// Deep copy so a snapshot is not affected by later writes
+func (l *List) Clone() any {
+	return ListOf(l.Values()...)
+}
+
+func (l *List) reset(values []string) {
+	*l = *ListOf(values...)
+}
+
+// Double the buffer when it is full, unwrapping the elements on the way
+func (l *List) grow() {
+	if l.size < len(l.items) {
+		return
+	}
+	items := make([]string, len(l.items)*2)
+	for i := 0; i < l.size; i++ {
+		items[i] = l.Index(i)
+	}
+	l.items = items
+	l.head = 0
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestListPushPop(t *testing.T) {
+	l := NewList()
+	// Enough elements to wrap around and grow the buffer a few times
+	for i := range 10 {
+		l.PushRight(string(rune('a' + i)))
+		l.PushLeft(string(rune('A' + i)))
+	}
+	if l.Len() != 20 {
+		t.Fatalf("Expected length 20, got %d", l.Len())
+	}
+	if v, _ := l.PopLeft(); v != "J" {
+		t.Errorf("Expected J, got %s", v)
+	}
+	if v, _ := l.PopRight(); v != "j" {
+		t.Errorf("Expected j, got %s", v)
+	}
+	if l.Index(0) != "I" || l.Index(l.Len()-1) != "i" {
+		t.Errorf("Expected I...i, got %s...%s", l.Index(0), l.Index(l.Len()-1))
+	}
+
+	empty := NewList()
+	if _, ok := empty.PopLeft(); ok {
+		t.Error("Expected pop from an empty list to fail")
+	}
+}
+
+func TestListRemove(t *testing.T) {
+	tests := []struct {
+		name     string
+		count    int
+		expected []string
+		removed  int
+	}{
+		{"From head", 2, []string{"b", "x", "c"}, 2},
+		{"From tail", -2, []string{"x", "b", "c"}, 2},
+		{"All", 0, []string{"b", "c"}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := ListOf("x", "b", "x", "x", "c")
+			removed := l.Remove(tt.count, "x")
+			if removed != tt.removed {
+				t.Errorf("Expected %d removed, got %d", tt.removed, removed)
+			}
+			if !slices.Equal(l.Values(), tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, l.Values())
+			}
+		})
+	}
+}
+
+func TestListInsertAndTrim(t *testing.T) {
+	l := ListOf("a", "c")
+	if n := l.Insert("c", "b", true); n != 3 {
+		t.Errorf("Expected length 3, got %d", n)
+	}
+	if n := l.Insert("missing", "z", false); n != -1 {
+		t.Errorf("Expected -1, got %d", n)
+	}
+	l.Trim(1, 2)
+	if !slices.Equal(l.Values(), []string{"b", "c"}) {
+		t.Errorf("Expected [b c], got %v", l.Values())
+	}
+}
@@ -0,0 +1,189 @@
+package store
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Rough per-item costs on top of the bytes of the strings themselves
+// They only need to be in the right ballpark for maxmemory to be useful
+const (
+	ENTRY_OVERHEAD       = 96 // Map slot, Entry and key header
+	STRING_OVERHEAD      = 16 // Header of a string in a slice
+	HASH_FIELD_OVERHEAD  = 48 // Map slot with two string headers
+	SET_MEMBER_OVERHEAD  = 56 // Index slot and slice element
+	ZSET_MEMBER_OVERHEAD = 96 // Score map slot and skiplist node
+)
+
+const (
+	EVICTION_SAMPLES = 5 // Keys looked at to pick each victim, like maxmemory-samples
+
+	LFU_INIT_VAL   = 5 // Counter of a new key so it is not evicted right away
+	LFU_LOG_FACTOR = 10
+	LFU_DECAY_TIME = time.Minute // The counter loses one for every period without access
+)
+
+// What to do when a write needs memory beyond maxmemory
+type Policy string
+
+const (
+	NO_EVICTION     Policy = "noeviction"
+	ALLKEYS_LRU     Policy = "allkeys-lru"
+	ALLKEYS_LFU     Policy = "allkeys-lfu"
+	ALLKEYS_RANDOM  Policy = "allkeys-random"
+	VOLATILE_LRU    Policy = "volatile-lru"
+	VOLATILE_LFU    Policy = "volatile-lfu"
+	VOLATILE_RANDOM Policy = "volatile-random"
+	VOLATILE_TTL    Policy = "volatile-ttl"
+)
+
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case NO_EVICTION, ALLKEYS_LRU, ALLKEYS_LFU, ALLKEYS_RANDOM, VOLATILE_LRU, VOLATILE_LFU, VOLATILE_RANDOM, VOLATILE_TTL:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid maxmemory policy: %s", s)
+}
+
+// Values that keep track of their own size as they change in place
+type sizer interface {
+	Size() int
+}
+
+// Approximate number of bytes a key and its value take
+func sizeOf(key string, value any) int {
+	n := ENTRY_OVERHEAD + len(key)
+	switch v := value.(type) {
+	case string:
+		n += len(v)
+	case sizer:
+		n += v.Size()
+	}
+	return n
+}
+
+// Limit the memory used by the keyspace, 0 means no limit
+func (s *InMemoryStore) SetMaxMemory(limit int64, policy Policy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxMemory = limit
+	s.policy = policy
+}
+
+func (s *InMemoryStore) MaxMemory() (int64, Policy) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.maxMemory, s.policy
+}
+
+// Approximate number of bytes taken by every key and value
+func (s *InMemoryStore) UsedMemory() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.used
+}
+
+// Number of keys removed since the start or the last ResetStats to stay under maxmemory
+func (s *InMemoryStore) Evicted() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.evicted
+}
+
+// Remove keys according to the policy until the memory used is back under the limit
+// Return the removed keys, and false if the limit is still exceeded
+// because the policy forbids evictions or no key qualifies
+func (s *InMemoryStore) Evict() ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var evicted []string
+	for s.maxMemory > 0 && s.used > s.maxMemory {
+		key, ok := s.victim(time.Now())
+		if !ok {
+			return evicted, false
+		}
+		s.remove(key)
+		s.evicted++
+		if w, ok := s.watched[key]; ok {
+			w.version++
+		}
+		evicted = append(evicted, key)
+	}
+	return evicted, true
+}
+
+// Pick the key to evict among a few sampled ones, an approximation of the policy like in Redis
+// Must be called with s.mu held
+func (s *InMemoryStore) victim(now time.Time) (string, bool) {
+	var keys []string
+	switch s.policy {
+	case ALLKEYS_RANDOM:
+		keys = sampleKeys(s.data, 1)
+	case VOLATILE_RANDOM:
+		keys = sampleKeys(s.expires, 1)
+	case ALLKEYS_LRU, ALLKEYS_LFU:
+		keys = sampleKeys(s.data, EVICTION_SAMPLES)
+	case VOLATILE_LRU, VOLATILE_LFU, VOLATILE_TTL:
+		keys = sampleKeys(s.expires, EVICTION_SAMPLES)
+	}
+	if len(keys) == 0 {
+		return "", false
+	}
+	best := keys[0]
+	for _, key := range keys[1:] {
+		if s.evictsBefore(key, best, now) {
+			best = key
+		}
+	}
+	return best, true
+}
+
+// Up to n keys of m, starting from a random one since map iteration does
+func sampleKeys[V any](m map[string]V, n int) []string {
+	keys := make([]string, 0, n)
+	for key := range m {
+		if len(keys) == n {
+			break
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Whether the policy would rather see key a go than key b
+// Must be called with s.mu held
+func (s *InMemoryStore) evictsBefore(a, b string, now time.Time) bool {
+	ea, eb := s.data[a], s.data[b]
+	switch s.policy {
+	case VOLATILE_TTL:
+		return ea.ExpireAt.Before(eb.ExpireAt)
+	case ALLKEYS_LFU, VOLATILE_LFU:
+		fa, fb := ea.frequency(now), eb.frequency(now)
+		return fa < fb || (fa == fb && ea.accessed.Before(eb.accessed))
+	default:
+		return ea.accessed.Before(eb.accessed)
+	}
+}
+
+// Record an access for LRU and LFU
+func (e *Entry) touch(now time.Time) {
+	e.freq = e.frequency(now)
+	// Logarithmic counter: the higher it is, the less likely it grows
+	if e.freq < 255 {
+		base := max(int(e.freq)-LFU_INIT_VAL, 0)
+		if rand.Float64() < 1/float64(base*LFU_LOG_FACTOR+1) {
+			e.freq++
+		}
+	}
+	e.accessed = now
+}
+
+// Access frequency counter after decaying it for the time spent without access
+func (e *Entry) frequency(now time.Time) uint8 {
+	periods := now.Sub(e.accessed) / LFU_DECAY_TIME
+	if periods >= time.Duration(e.freq) {
+		return 0
+	}
+	return e.freq - uint8(periods)
+}
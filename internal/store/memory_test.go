@@ -0,0 +1,78 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUsedMemory(t *testing.T) {
+	s := NewInMemoryStore()
+	s.Set("s", "value")
+	l := NewList()
+	s.Set("l", l)
+	base := s.UsedMemory()
+
+	l.PushRight("some element")
+	s.Modified("l")
+	if s.UsedMemory() <= base {
+		t.Errorf("Expected used memory to grow past %d, got %d", base, s.UsedMemory())
+	}
+	l.PopRight()
+	s.Modified("l")
+	if s.UsedMemory() != base {
+		t.Errorf("Expected used memory back to %d, got %d", base, s.UsedMemory())
+	}
+
+	s.Delete("s")
+	s.Delete("l")
+	if s.UsedMemory() != 0 {
+		t.Errorf("Expected no memory used, got %d", s.UsedMemory())
+	}
+}
+
+func TestEvict(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		evicted string // Empty when nothing can be evicted
+	}{
+		{"noeviction", NO_EVICTION, ""},
+		{"allkeys-lru evicts the least recently used key", ALLKEYS_LRU, "old"},
+		{"allkeys-lfu evicts the least frequently used key", ALLKEYS_LFU, "old"},
+		{"volatile-ttl evicts the key closest to expire", VOLATILE_TTL, "soon"},
+		{"volatile-lru only evicts keys with a TTL", VOLATILE_LRU, "soon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewInMemoryStore()
+			s.Set("old", "v")
+			s.Set("soon", "v")
+			s.SetExpiry("soon", time.Now().Add(time.Minute))
+			s.Set("later", "v")
+			s.SetExpiry("later", time.Now().Add(time.Hour))
+			s.data["old"].accessed = time.Now().Add(-time.Hour)
+			s.data["old"].freq = 0
+			for range 100 {
+				s.Get("soon")
+				s.Get("later")
+			}
+
+			// Room for two keys out of three
+			s.SetMaxMemory(s.UsedMemory()-1, tt.policy)
+			keys, ok := s.Evict()
+			if tt.evicted == "" {
+				if ok || len(keys) != 0 {
+					t.Errorf("Expected no eviction, got %v", keys)
+				}
+				return
+			}
+			if !ok || len(keys) != 1 || keys[0] != tt.evicted {
+				t.Errorf("Expected %s to be evicted, got %v", tt.evicted, keys)
+			}
+			if s.Evicted() != 1 {
+				t.Errorf("Expected 1 eviction counted, got %d", s.Evicted())
+			}
+		})
+	}
+}
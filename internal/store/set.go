@@ -0,0 +1,90 @@
+package store
+
+import "math/rand/v2"
+
+// Unordered collection of unique strings
+// Members also live in a slice so one can be picked at random in O(1)
+type Set struct {
+	index   map[string]int // Position of each member in members
+	members []string
+	bytes   int // Total length of the members, for memory accounting
+}
+
+func NewSet() *Set {
+	return &Set{index: make(map[string]int)}
+}
+
+func SetOf(members ...string) *Set {
+	s := NewSet()
+	for _, m := range members {
+		s.Add(m)
+	}
+	return s
+}
+
+func (s *Set) Len() int {
+	return len(s.members)
+}
+
+// Return true if m was not a member yet
+func (s *Set) Add(m string) bool {
+	if _, ok := s.index[m]; ok {
+		return false
+	}
+	s.index[m] = len(s.members)
+	s.members = append(s.members, m)
+	s.bytes += len(m)
+	return true
+}
+
+func (s *Set) Remove(m string) bool {
+	i, ok := s.index[m]
+	if !ok {
+		return false
+	}
+	// Move the last member into the hole
+	last := len(s.members) - 1
+	s.members[i] = s.members[last]
+	s.index[s.members[i]] = i
+	s.members = s.members[:last]
+	delete(s.index, m)
+	s.bytes -= len(m)
+	return true
+}
+
+func (s *Set) Has(m string) bool {
+	_, ok := s.index[m]
+	return ok
+}
+
+func (s *Set) Members() []string {
+	return append([]string(nil), s.members...)
+}
+
+// Any member, the set must not be empty
+func (s *Set) Random() string {
+	return s.members[rand.IntN(len(s.members))]
+}
+
+// Up to n distinct members picked at random
+func (s *Set) RandomDistinct(n int) []string {
+	if n >= len(s.members) {
+		return s.Members()
+	}
+	// Partial Fisher-Yates on a copy
+	picked := s.Members()
+	for i := range n {
+		j := i + rand.IntN(len(picked)-i)
+		picked[i], picked[j] = picked[j], picked[i]
+	}
+	return picked[:n]
+}
+
+func (s *Set) Clone() any {
+	return SetOf(s.members...)
+}
+
+// Approximate number of bytes held by the set
+func (s *Set) Size() int {
+	return len(s.members)*SET_MEMBER_OVERHEAD + s.bytes
+}
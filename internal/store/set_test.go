@@ -0,0 +1,55 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSetAddRemove(t *testing.T) {
+	s := SetOf("a", "b", "c", "a")
+	if s.Len() != 3 {
+		t.Fatalf("Expected 3 members, got %d", s.Len())
+	}
+	if s.Add("b") {
+		t.Error("Expected adding an existing member to return false")
+	}
+	if !s.Remove("a") || s.Remove("a") {
+		t.Error("Expected a to be removed exactly once")
+	}
+	// The last member was moved into the hole, it must still be found
+	if !s.Has("c") || !s.Has("b") || s.Has("a") {
+		t.Errorf("Expected members [b c], got %v", s.Members())
+	}
+}
+
+func TestSetRandomDistinct(t *testing.T) {
+	s := SetOf("a", "b", "c", "d", "e")
+
+	tests := []struct {
+		name     string
+		n        int
+		expected int
+	}{
+		{"Fewer than members", 3, 3},
+		{"More than members", 10, 5},
+		{"None", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			picked := s.RandomDistinct(tt.n)
+			if len(picked) != tt.expected {
+				t.Fatalf("Expected %d members, got %d", tt.expected, len(picked))
+			}
+			slices.Sort(picked)
+			if len(slices.Compact(picked)) != tt.expected {
+				t.Errorf("Expected distinct members, got %v", picked)
+			}
+			for _, m := range picked {
+				if !s.Has(m) {
+					t.Errorf("Expected %s to be a member", m)
+				}
+			}
+		})
+	}
+}
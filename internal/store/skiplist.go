@@ -0,0 +1,205 @@
+package store
+
+import "math/rand/v2"
+
+// Same parameters as Redis: enough levels for 2^64 elements,
+// each level holding a quarter of the nodes of the one below
+const (
+	SKIPLIST_MAX_LEVEL = 32
+	SKIPLIST_P         = 0.25
+)
+
+// Nodes are ordered by score, then by member
+// Every link records how many nodes it skips so ranks can be computed in O(log n)
+type skiplist struct {
+	header *skipNode
+	tail   *skipNode
+	length int
+	level  int
+}
+
+type skipNode struct {
+	member   string
+	score    float64
+	backward *skipNode
+	levels   []skipLevel
+}
+
+type skipLevel struct {
+	forward *skipNode
+	span    int // Number of nodes between this one and forward, forward included
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		header: &skipNode{levels: make([]skipLevel, SKIPLIST_MAX_LEVEL)},
+		level:  1,
+	}
+}
+
+func randomLevel() int {
+	level := 1
+	for level < SKIPLIST_MAX_LEVEL && rand.Float64() < SKIPLIST_P {
+		level++
+	}
+	return level
+}
+
+// Whether n comes before (score, member)
+func (n *skipNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+// Whether n comes after (score, member)
+func (n *skipNode) after(score float64, member string) bool {
+	return n.score > score || (n.score == score && n.member > member)
+}
+
+// The member must not be in the list yet
+func (sl *skiplist) insert(score float64, member string) {
+	var update [SKIPLIST_MAX_LEVEL]*skipNode
+	var rank [SKIPLIST_MAX_LEVEL]int
+
+	// Find the last node before the new one on each level, and its rank
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		if i < sl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			rank[i] += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > sl.level {
+		for i := sl.level; i < level; i++ {
+			update[i] = sl.header
+			update[i].levels[i].span = sl.length
+		}
+		sl.level = level
+	}
+
+	x = &skipNode{member: member, score: score, levels: make([]skipLevel, level)}
+	for i := range level {
+		x.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = x
+		// rank[0] - rank[i] nodes sit between update[i] and the new node
+		x.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+	// Links above the new node now skip one more node
+	for i := level; i < sl.level; i++ {
+		update[i].levels[i].span++
+	}
+
+	if update[0] != sl.header {
+		x.backward = update[0]
+	}
+	if x.levels[0].forward != nil {
+		x.levels[0].forward.backward = x
+	} else {
+		sl.tail = x
+	}
+	sl.length++
+}
+
+func (sl *skiplist) delete(score float64, member string) bool {
+	var update [SKIPLIST_MAX_LEVEL]*skipNode
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.levels[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+	for i := range sl.level {
+		if update[i].levels[i].forward == x {
+			update[i].levels[i].span += x.levels[i].span - 1
+			update[i].levels[i].forward = x.levels[i].forward
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+	if x.levels[0].forward != nil {
+		x.levels[0].forward.backward = x.backward
+	} else {
+		sl.tail = x.backward
+	}
+	for sl.level > 1 && sl.header.levels[sl.level-1].forward == nil {
+		sl.level--
+	}
+	sl.length--
+	return true
+}
+
+// Position of the node counted from 1, 0 if it is not in the list
+func (sl *skiplist) rank(score float64, member string) int {
+	rank := 0
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !x.levels[i].forward.after(score, member) {
+			rank += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if x != sl.header && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// Node at a position counted from 1, nil if out of range
+func (sl *skiplist) nodeAt(rank int) *skipNode {
+	traversed := 0
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= rank {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if traversed == rank {
+			if x == sl.header {
+				return nil
+			}
+			return x
+		}
+	}
+	return nil
+}
+
+// First node within the range, nil if there is none
+func (sl *skiplist) firstIn(r ZRange) *skipNode {
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !r.aboveMin(x.levels[i].forward) {
+			x = x.levels[i].forward
+		}
+	}
+	x = x.levels[0].forward
+	if x == nil || !r.belowMax(x) {
+		return nil
+	}
+	return x
+}
+
+// Last node within the range, nil if there is none
+func (sl *skiplist) lastIn(r ZRange) *skipNode {
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && r.belowMax(x.levels[i].forward) {
+			x = x.levels[i].forward
+		}
+	}
+	if x == sl.header || !r.aboveMin(x) {
+		return nil
+	}
+	return x
+}
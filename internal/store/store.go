@@ -1,11 +1,319 @@
 package store
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
+// A single key-value pair with its metadata
+type Entry struct {
+	Value    any
+	ExpireAt time.Time // Zero value means the key never expires
+
+	// Bookkeeping for maxmemory
+	size     int       // Approximate bytes taken by the key and value, see sizeOf
+	accessed time.Time // Last time the key was read or written, for LRU
+	freq     uint8     // Logarithmic access counter, for LFU
+}
+
+// Values that are changed in place, like lists, have to be copied for snapshots
+type cloner interface {
+	Clone() any
+}
+
+func (e *Entry) expired(now time.Time) bool {
+	return !e.ExpireAt.IsZero() && !now.Before(e.ExpireAt)
+}
+
+// Values that are changed in place must only be touched by commands,
+// which run one at a time, and every change must be reported with Modified
 type InMemoryStore struct {
-	Data sync.Map
+	mu      sync.RWMutex
+	data    map[string]*Entry
+	expires map[string]struct{} // Keys of data with an expiration time, sampled by ExpireSample
+	dirty   uint64              // Number of changes made to the keyspace, only ever grows
+	expired uint64              // Number of keys reclaimed because their time was up
+	// Keys reclaimed since the last call to Reclaimed, deleted on followers and in the AOF too
+	reclaimed []string
+	// Set on followers, which hide expired keys until their leader deletes them
+	keepExpired bool
+
+	used      int64 // Sum of the size of every entry
+	maxMemory int64 // 0 means no limit
+	policy    Policy
+	evicted   uint64 // Number of keys removed to stay under maxMemory
+
+	watched map[string]*watch // Keys some client is watching, see Watch
+}
+
+// Changes to a watched key, whether it exists or not
+type watch struct {
+	refs    int
+	version uint64
 }
 
 func NewInMemoryStore() *InMemoryStore {
-	return &InMemoryStore{}
+	return &InMemoryStore{
+		data:    make(map[string]*Entry),
+		expires: make(map[string]struct{}),
+		watched: make(map[string]*watch),
+		policy:  NO_EVICTION,
+	}
+}
+
+// Return the value of a key if it exists and has not expired yet
+// An expired key is reclaimed on the spot
+func (s *InMemoryStore) Get(key string) (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	e, ok := s.lookup(key, now)
+	if !ok {
+		return nil, false
+	}
+	e.touch(now)
+	return e.Value, true
+}
+
+// Store a value under a key, dropping any expiration the key had before
+func (s *InMemoryStore) Set(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(key)
+	s.data[key] = newEntry(value, time.Now())
+	s.changed(key)
+}
+
+// Attach an absolute expiration time to an existing key, the zero time removes it
+func (s *InMemoryStore) SetExpiry(key string, at time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.lookup(key, time.Now())
+	if !ok {
+		return false
+	}
+	e.ExpireAt = at
+	if at.IsZero() {
+		delete(s.expires, key)
+	} else {
+		s.expires[key] = struct{}{}
+	}
+	s.changed(key)
+	return true
+}
+
+// Expiration time of a live key, zero if it never expires
+func (s *InMemoryStore) ExpireAt(key string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.lookup(key, time.Now())
+	if !ok {
+		return time.Time{}, false
+	}
+	return e.ExpireAt, true
+}
+
+// Remove a key and report whether it was there
+func (s *InMemoryStore) Delete(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.lookup(key, time.Now()); !ok {
+		// The DEL our leader sends for a key whose time was up
+		if _, held := s.data[key]; held {
+			s.remove(key)
+			s.expired++
+			s.changed(key)
+		}
+		return false
+	}
+	s.remove(key)
+	s.changed(key)
+	return true
+}
+
+func newEntry(value any, now time.Time) *Entry {
+	return &Entry{Value: value, accessed: now, freq: LFU_INIT_VAL}
+}
+
+// Must be called with s.mu held
+func (s *InMemoryStore) remove(key string) {
+	if e, ok := s.data[key]; ok {
+		s.used -= int64(e.size)
+		delete(s.data, key)
+		delete(s.expires, key)
+	}
+}
+
+// Must be called with s.mu held
+// Return the entry of a live key, reclaiming it first if its time is up
+func (s *InMemoryStore) lookup(key string, now time.Time) (*Entry, bool) {
+	e, ok := s.data[key]
+	if !ok {
+		return nil, false
+	}
+	if e.expired(now) {
+		if !s.keepExpired {
+			s.expire(key)
+		}
+		return nil, false
+	}
+	return e, true
+}
+
+// Must be called with s.mu held
+// An expiration is not a change made by a command so dirty stays put,
+// but a client watching the key still has to see it go
+func (s *InMemoryStore) expire(key string) {
+	s.remove(key)
+	s.expired++
+	s.reclaimed = append(s.reclaimed, key)
+	if w, ok := s.watched[key]; ok {
+		w.version++
+	}
+}
+
+// Return the keys reclaimed because their time was up since the last call
+func (s *InMemoryStore) Reclaimed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := s.reclaimed
+	s.reclaimed = nil
+	return keys
+}
+
+// Hide expired keys instead of reclaiming them, like a follower does
+// so its keyspace only ever changes through the commands of its leader
+func (s *InMemoryStore) KeepExpired(keep bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keepExpired = keep
+}
+
+// Record an in-place change of the value held by key
+func (s *InMemoryStore) Modified(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changed(key)
+}
+
+// Must be called with s.mu held
+func (s *InMemoryStore) changed(key string) {
+	s.dirty++
+	if w, ok := s.watched[key]; ok {
+		w.version++
+	}
+	// Values changed in place report their new size through sizer
+	if e, ok := s.data[key]; ok {
+		size := sizeOf(key, e.Value)
+		s.used += int64(size - e.size)
+		e.size = size
+	}
+}
+
+// Start tracking changes to key and return its current version
+// Every Watch must be paired with an Unwatch
+func (s *InMemoryStore) Watch(key string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := s.watched[key]
+	if !ok {
+		w = &watch{}
+		s.watched[key] = w
+	}
+	w.refs++
+	return w.version
+}
+
+func (s *InMemoryStore) Unwatch(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := s.watched[key]
+	if !ok {
+		return
+	}
+	w.refs--
+	if w.refs == 0 {
+		delete(s.watched, key)
+	}
+}
+
+// Grows every time a watched key changes
+func (s *InMemoryStore) Version(key string) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if w, ok := s.watched[key]; ok {
+		return w.version
+	}
+	return 0
+}
+
+// Compare the values before and after running a command to tell whether it changed anything
+func (s *InMemoryStore) Dirty() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dirty
+}
+
+// Number of keys including the expired ones that have not been reclaimed yet
+func (s *InMemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.data)
+}
+
+// Zero the counters of expired and evicted keys, like CONFIG RESETSTAT
+func (s *InMemoryStore) ResetStats() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expired, s.evicted = 0, 0
+}
+
+// Copy every live entry at a single point in time
+// so it can be serialized while clients keep writing to the store
+// Must be called while no command runs since values may be copied
+func (s *InMemoryStore) Snapshot() map[string]Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	snapshot := make(map[string]Entry, len(s.data))
+	for key, e := range s.data {
+		if e.expired(now) {
+			continue
+		}
+		copied := *e
+		if c, ok := e.Value.(cloner); ok {
+			copied.Value = c.Clone()
+		}
+		snapshot[key] = copied
+	}
+	return snapshot
+}
+
+// Swap the whole keyspace with the given entries, e.g. after loading a snapshot
+func (s *InMemoryStore) Replace(entries map[string]Entry) {
+	data := make(map[string]*Entry, len(entries))
+	expires := make(map[string]struct{})
+	now := time.Now()
+	var used int64
+	for key, e := range entries {
+		entry := newEntry(e.Value, now)
+		entry.ExpireAt = e.ExpireAt
+		entry.size = sizeOf(key, e.Value)
+		used += int64(entry.size)
+		data[key] = entry
+		if !e.ExpireAt.IsZero() {
+			expires[key] = struct{}{}
+		}
+	}
+	s.mu.Lock()
+	s.data = data
+	s.expires = expires
+	s.reclaimed = nil // They were part of the keyspace we just dropped
+	s.used = used
+	s.dirty += uint64(len(data))
+	// Any watched key may have changed
+	for _, w := range s.watched {
+		w.version++
+	}
+	s.mu.Unlock()
 }
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	s := NewInMemoryStore()
+	version := s.Watch("balance")
+
+	s.Set("other", "1")
+	if s.Version("balance") != version {
+		t.Fatal("Expected a change to another key to leave the version alone")
+	}
+
+	// A key that did not exist when watched counts as changed once created
+	s.Set("balance", "10")
+	if s.Version("balance") == version {
+		t.Fatal("Expected the version to change after SET")
+	}
+
+	version = s.Version("balance")
+	s.Delete("balance")
+	if s.Version("balance") == version {
+		t.Fatal("Expected the version to change after DEL")
+	}
+
+	s.Unwatch("balance")
+	if len(s.watched) != 0 {
+		t.Errorf("Expected no watched keys left, got %d", len(s.watched))
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	past := time.Now().Add(-time.Second)
+	tests := []struct {
+		name  string
+		setup func(s *InMemoryStore)
+		want  bool
+	}{
+		{"expired", func(s *InMemoryStore) {
+			s.Set("k", "v")
+			s.SetExpiry("k", past)
+		}, false},
+		{"overwritten without TTL", func(s *InMemoryStore) {
+			s.Set("k", "v")
+			s.SetExpiry("k", time.Now().Add(time.Millisecond))
+			s.Set("k", "w")
+			time.Sleep(2 * time.Millisecond)
+		}, true},
+		{"persisted", func(s *InMemoryStore) {
+			s.Set("k", "v")
+			s.SetExpiry("k", time.Now().Add(time.Millisecond))
+			s.SetExpiry("k", time.Time{})
+			time.Sleep(2 * time.Millisecond)
+		}, true},
+		{"not expired yet", func(s *InMemoryStore) {
+			s.Set("k", "v")
+			s.SetExpiry("k", time.Now().Add(time.Hour))
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewInMemoryStore()
+			tt.setup(s)
+			if _, ok := s.Get("k"); ok != tt.want {
+				t.Errorf("Expected key to exist: %v, got %v", tt.want, ok)
+			}
+			// Expired keys are reclaimed on access
+			if !tt.want && s.Len() != 0 {
+				t.Errorf("Expected the expired key to be reclaimed, got %d keys", s.Len())
+			}
+		})
+	}
+}
+
+func TestExpireSample(t *testing.T) {
+	s := NewInMemoryStore()
+	for i := range 10 {
+		key := strconv.Itoa(i)
+		s.Set(key, "v")
+		if i%2 == 0 {
+			s.SetExpiry(key, time.Now().Add(-time.Second))
+		} else {
+			s.SetExpiry(key, time.Now().Add(time.Hour))
+		}
+	}
+	s.Set("forever", "v")
+
+	sampled, expired := s.ExpireSample(20)
+	if sampled != 10 || expired != 5 {
+		t.Errorf("Expected 10 sampled and 5 expired, got %d and %d", sampled, expired)
+	}
+	if s.Len() != 6 {
+		t.Errorf("Expected 6 keys left, got %d", s.Len())
+	}
+	if s.Expired() != 5 {
+		t.Errorf("Expected 5 expired keys counted, got %d", s.Expired())
+	}
+	if s.Dirty() != 21 {
+		t.Errorf("Expected expirations to leave dirty alone, got %d", s.Dirty())
+	}
+}
+
+func TestKeepExpired(t *testing.T) {
+	s := NewInMemoryStore()
+	s.KeepExpired(true)
+	s.Set("k", "v")
+	s.SetExpiry("k", time.Now().Add(-time.Second))
+
+	// Hidden but kept until the leader deletes it
+	if _, ok := s.Get("k"); ok {
+		t.Error("Expected the expired key to be hidden")
+	}
+	if s.Len() != 1 || len(s.Reclaimed()) != 0 {
+		t.Fatalf("Expected the key to be kept, got %d keys", s.Len())
+	}
+	dirty := s.Dirty()
+	if s.Delete("k") {
+		t.Error("Expected DEL to report the expired key as missing")
+	}
+	if s.Len() != 0 || s.Dirty() == dirty {
+		t.Errorf("Expected DEL to remove the key as a change, got %d keys", s.Len())
+	}
+
+	// A leader reclaims it on access and reports it
+	s.KeepExpired(false)
+	s.Set("k", "v")
+	s.SetExpiry("k", time.Now().Add(-time.Second))
+	s.Get("k")
+	if got := s.Reclaimed(); len(got) != 1 || got[0] != "k" {
+		t.Errorf("Expected [k], got %v", got)
+	}
+	if got := s.Reclaimed(); len(got) != 0 {
+		t.Errorf("Expected nothing left, got %v", got)
+	}
+}
@@ -0,0 +1,190 @@
+package store
+
+import "strings"
+
+// Set of unique members ordered by score
+// The map answers score lookups in O(1), the skiplist keeps the order
+type ZSet struct {
+	scores map[string]float64
+	list   *skiplist
+	bytes  int // Total length of the members, for memory accounting
+}
+
+type ZEntry struct {
+	Member string
+	Score  float64
+}
+
+func NewZSet() *ZSet {
+	return &ZSet{scores: make(map[string]float64), list: newSkiplist()}
+}
+
+func (z *ZSet) Len() int {
+	return len(z.scores)
+}
+
+func (z *ZSet) Score(member string) (float64, bool) {
+	score, ok := z.scores[member]
+	return score, ok
+}
+
+// Insert the member or update its score
+// Return true if the member is new
+func (z *ZSet) Add(member string, score float64) bool {
+	old, exists := z.scores[member]
+	if exists {
+		if old == score {
+			return false
+		}
+		z.list.delete(old, member)
+	}
+	z.list.insert(score, member)
+	z.scores[member] = score
+	if !exists {
+		z.bytes += len(member)
+	}
+	return !exists
+}
+
+func (z *ZSet) Remove(member string) bool {
+	score, ok := z.scores[member]
+	if !ok {
+		return false
+	}
+	z.list.delete(score, member)
+	delete(z.scores, member)
+	z.bytes -= len(member)
+	return true
+}
+
+// Position of the member counted from 0, from the highest score when reverse is set
+func (z *ZSet) Rank(member string, reverse bool) (int, bool) {
+	score, ok := z.scores[member]
+	if !ok {
+		return 0, false
+	}
+	rank := z.list.rank(score, member)
+	if reverse {
+		return z.Len() - rank, true
+	}
+	return rank - 1, true
+}
+
+// Entries from position start to stop, both inclusive and already within bounds
+func (z *ZSet) RangeByRank(start, stop int, reverse bool) []ZEntry {
+	if start > stop {
+		return []ZEntry{}
+	}
+	out := make([]ZEntry, 0, stop-start+1)
+	if reverse {
+		for x := z.list.nodeAt(z.Len() - start); x != nil && len(out) < cap(out); x = x.backward {
+			out = append(out, ZEntry{x.member, x.score})
+		}
+		return out
+	}
+	for x := z.list.nodeAt(start + 1); x != nil && len(out) < cap(out); x = x.levels[0].forward {
+		out = append(out, ZEntry{x.member, x.score})
+	}
+	return out
+}
+
+// Entries within the range, skipping the first offset ones and returning at most count, all of them if count < 0
+func (z *ZSet) RangeBy(r ZRange, reverse bool, offset, count int) []ZEntry {
+	out := []ZEntry{}
+	var x *skipNode
+	if reverse {
+		x = z.list.lastIn(r)
+	} else {
+		x = z.list.firstIn(r)
+	}
+	for x != nil && (count < 0 || len(out) < count) && r.aboveMin(x) && r.belowMax(x) {
+		if offset > 0 {
+			offset--
+		} else {
+			out = append(out, ZEntry{x.member, x.score})
+		}
+		if reverse {
+			x = x.backward
+		} else {
+			x = x.levels[0].forward
+		}
+	}
+	return out
+}
+
+// All entries from the lowest score to the highest
+func (z *ZSet) Entries() []ZEntry {
+	return z.RangeByRank(0, z.Len()-1, false)
+}
+
+func (z *ZSet) Clone() any {
+	c := NewZSet()
+	for _, e := range z.Entries() {
+		c.Add(e.Member, e.Score)
+	}
+	return c
+}
+
+// Approximate number of bytes held by the sorted set
+func (z *ZSet) Size() int {
+	return len(z.scores)*ZSET_MEMBER_OVERHEAD + z.bytes
+}
+
+// Interval of a sorted set, see ScoreRange and LexRange
+type ZRange interface {
+	aboveMin(n *skipNode) bool
+	belowMax(n *skipNode) bool
+}
+
+type ScoreRange struct {
+	Min, Max                   float64
+	MinExclusive, MaxExclusive bool
+}
+
+func (r ScoreRange) aboveMin(n *skipNode) bool {
+	if r.MinExclusive {
+		return n.score > r.Min
+	}
+	return n.score >= r.Min
+}
+
+func (r ScoreRange) belowMax(n *skipNode) bool {
+	if r.MaxExclusive {
+		return n.score < r.Max
+	}
+	return n.score <= r.Max
+}
+
+// Bound of a lexicographic range, Inf is -1 for "-" and 1 for "+"
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	Inf       int
+}
+
+// Only meaningful when every member has the same score
+type LexRange struct {
+	Min, Max LexBound
+}
+
+func (r LexRange) aboveMin(n *skipNode) bool {
+	switch r.Min.Inf {
+	case -1:
+		return true
+	case 1:
+		return false
+	}
+	cmp := strings.Compare(n.member, r.Min.Value)
+	return cmp > 0 || (cmp == 0 && !r.Min.Exclusive)
+}
+
+func (r LexRange) belowMax(n *skipNode) bool {
+	switch r.Max.Inf {
+	case 1:
+		return true
+	case -1:
+		return false
+	}
+	cmp := strings.Compare(n.member, r.Max.Value)
+	return cmp < 0 || (cmp == 0 && !r.Max.Exclusive)
+}
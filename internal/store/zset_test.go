@@ -0,0 +1,85 @@
+package store
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func members(entries []ZEntry) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = e.Member
+	}
+	return out
+}
+
+func TestZSetOrderAndRank(t *testing.T) {
+	z := NewZSet()
+	// Enough members to get a few levels in the skiplist
+	want := make([]string, 0, 200)
+	for i := range 200 {
+		want = append(want, fmt.Sprintf("m%03d", i))
+	}
+	for _, i := range rand.Perm(200) {
+		z.Add(want[i], float64(i))
+	}
+	// Moving a member keeps a single copy of it
+	z.Add("m000", 1000)
+	z.Add("m000", 0)
+
+	if got := members(z.Entries()); !slices.Equal(got, want) {
+		t.Fatalf("Expected members in score order, got %v", got)
+	}
+	for i, m := range want {
+		rank, ok := z.Rank(m, false)
+		if !ok || rank != i {
+			t.Fatalf("Expected rank of %s to be %d, got %d", m, i, rank)
+		}
+		rev, _ := z.Rank(m, true)
+		if rev != len(want)-1-i {
+			t.Fatalf("Expected reverse rank of %s to be %d, got %d", m, len(want)-1-i, rev)
+		}
+	}
+
+	for i := 0; i < 200; i += 2 {
+		z.Remove(want[i])
+	}
+	if z.Len() != 100 {
+		t.Fatalf("Expected 100 members, got %d", z.Len())
+	}
+	if rank, _ := z.Rank("m199", false); rank != 99 {
+		t.Errorf("Expected rank of m199 to be 99, got %d", rank)
+	}
+}
+
+func TestZSetRanges(t *testing.T) {
+	z := NewZSet()
+	for i, m := range []string{"a", "b", "c", "d", "e"} {
+		z.Add(m, float64(i+1))
+	}
+
+	tests := []struct {
+		name     string
+		got      []ZEntry
+		expected []string
+	}{
+		{"By rank", z.RangeByRank(1, 3, false), []string{"b", "c", "d"}},
+		{"By rank reversed", z.RangeByRank(0, 1, true), []string{"e", "d"}},
+		{"By score", z.RangeBy(ScoreRange{Min: 2, Max: 4}, false, 0, -1), []string{"b", "c", "d"}},
+		{"By score exclusive", z.RangeBy(ScoreRange{Min: 2, Max: 4, MinExclusive: true, MaxExclusive: true}, false, 0, -1), []string{"c"}},
+		{"By score reversed with limit", z.RangeBy(ScoreRange{Min: 1, Max: 5}, true, 1, 2), []string{"d", "c"}},
+		{"By score empty", z.RangeBy(ScoreRange{Min: 6, Max: 10}, false, 0, -1), []string{}},
+		{"By lex", z.RangeBy(LexRange{Min: LexBound{Value: "b"}, Max: LexBound{Value: "d", Exclusive: true}}, false, 0, -1), []string{"b", "c"}},
+		{"By lex unbounded", z.RangeBy(LexRange{Min: LexBound{Inf: -1}, Max: LexBound{Inf: 1}}, true, 0, 2), []string{"e", "d"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := members(tt.got); !slices.Equal(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
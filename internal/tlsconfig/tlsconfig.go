@@ -0,0 +1,189 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/logger"
+)
+
+// How often certificate files are checked for changes
+const WATCH_INTERVAL = 5 * time.Second
+
+var (
+	ErrNoCertificate = errors.New("tls: tls-cert-file and tls-key-file are required")
+	ErrNoCA          = errors.New("tls: verifying client certificates requires tls-ca-cert-file")
+)
+
+// Where certificates are read from
+// CertFile and KeyFile are the server certificate, or the client one when dialing
+// CAFile holds the certificates that sign the other side's
+type Files struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+}
+
+// Map tls-auth-clients to what the handshake asks of clients
+func ParseAuthClients(s string) (tls.ClientAuthType, error) {
+	switch s {
+	case "no":
+		return tls.NoClientCert, nil
+	case "optional":
+		return tls.VerifyClientCertIfGiven, nil
+	case "yes":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return 0, fmt.Errorf("invalid tls-auth-clients '%s'", s)
+	}
+}
+
+// Certificates of the TLS port, read again from disk whenever the files change
+// so they can be renewed without restarting the server
+type Reloader struct {
+	files      Files
+	clientAuth tls.ClientAuthType
+	logger     *logger.Logger
+
+	mu       sync.RWMutex // Guard the fields below
+	cert     *tls.Certificate
+	pool     *x509.CertPool // Nil without a CA file
+	modTimes [3]time.Time   // Of the cert, key and CA files when last loaded
+}
+
+func NewReloader(files Files, clientAuth tls.ClientAuthType, logger *logger.Logger) (*Reloader, error) {
+	if files.CertFile == "" || files.KeyFile == "" {
+		return nil, ErrNoCertificate
+	}
+	if clientAuth != tls.NoClientCert && files.CAFile == "" {
+		return nil, ErrNoCA
+	}
+	r := &Reloader{files: files, clientAuth: clientAuth, logger: logger}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Read every file again
+// The previous certificates stay in use if any of them is broken
+func (r *Reloader) Reload() error {
+	modTimes := r.stat()
+	cert, err := tls.LoadX509KeyPair(r.files.CertFile, r.files.KeyFile)
+	if err != nil {
+		return err
+	}
+	var pool *x509.CertPool
+	if r.files.CAFile != "" {
+		if pool, err = loadPool(r.files.CAFile); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert, r.pool, r.modTimes = &cert, pool, modTimes
+	return nil
+}
+
+func (r *Reloader) stat() [3]time.Time {
+	var modTimes [3]time.Time
+	for i, path := range []string{r.files.CertFile, r.files.KeyFile, r.files.CAFile} {
+		if info, err := os.Stat(path); err == nil {
+			modTimes[i] = info.ModTime()
+		}
+	}
+	return modTimes
+}
+
+// Reload the certificates whenever their files change, for as long as the server runs
+func (r *Reloader) Watch() {
+	ticker := time.NewTicker(WATCH_INTERVAL)
+	defer ticker.Stop()
+	for range ticker.C {
+		r.mu.RLock()
+		changed := r.stat() != r.modTimes
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		// A renewal may be caught half written, the next tick tries again
+		if err := r.Reload(); err != nil {
+			r.logger.Error(err, map[string]string{"path": r.files.CertFile})
+			continue
+		}
+		r.logger.Info("TLS certificates reloaded", map[string]string{"path": r.files.CertFile})
+	}
+}
+
+// For the TLS listener, every handshake picks up the latest certificates
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+				ClientAuth:   r.clientAuth,
+				ClientCAs:    r.pool,
+			}, nil
+		},
+	}
+}
+
+// For dialing our leader, which is trusted if signed by the CA
+// The server certificate doubles as the client one in case the leader asks for it
+func (r *Reloader) ClientConfig(serverName string) *tls.Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		ServerName:   serverName,
+		Certificates: []tls.Certificate{*r.cert},
+		RootCAs:      r.pool,
+	}
+}
+
+// For the command line tools, any file may be left empty
+// Without a CA file the system roots are trusted
+func ClientConfig(files Files, serverName string, insecure bool) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         serverName,
+		InsecureSkipVerify: insecure,
+	}
+	if files.CertFile != "" || files.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if files.CAFile != "" {
+		pool, err := loadPool(files.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+func loadPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("tls: no certificate found in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,212 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/logger"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	ca := &testCA{cert: cert, key: key, dir: t.TempDir()}
+	writePEM(t, filepath.Join(ca.dir, "ca.crt"), "CERTIFICATE", der)
+	return ca
+}
+
+// Write a certificate signed by the CA and its key, return their paths
+func (ca *testCA) issue(t *testing.T, name string, serial int64) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath, keyPath := filepath.Join(ca.dir, name+".crt"), filepath.Join(ca.dir, name+".key")
+	writePEM(t, certPath, "CERTIFICATE", der)
+	writePEM(t, keyPath, "EC PRIVATE KEY", keyDER)
+	return certPath, keyPath
+}
+
+func writePEM(t *testing.T, path, kind string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Complete a handshake with a server using r, return the certificate it presented
+func handshake(t *testing.T, r *Reloader, client *tls.Config) (*x509.Certificate, error) {
+	t.Helper()
+	l, err := tls.Listen("tcp", "127.0.0.1:0", r.ServerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// The server only checks the client certificate once the client reads
+		conn.(*tls.Conn).Handshake()
+		conn.Write([]byte("+OK\r\n"))
+	}()
+
+	conn, err := tls.Dial("tcp", l.Addr().String(), client)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := io.ReadFull(conn, make([]byte, 5)); err != nil {
+		return nil, err
+	}
+	return conn.ConnectionState().PeerCertificates[0], nil
+}
+
+func TestServerConfig(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, "server", 2)
+	clientCert, clientKey := ca.issue(t, "client", 3)
+	files := Files{CertFile: serverCert, KeyFile: serverKey, CAFile: filepath.Join(ca.dir, "ca.crt")}
+
+	tests := []struct {
+		name        string
+		authClients string
+		withCert    bool
+		ok          bool
+	}{
+		{"No client certificate needed", "no", false, true},
+		{"Optional and missing", "optional", false, true},
+		{"Optional and given", "optional", true, true},
+		{"Required and missing", "yes", false, false},
+		{"Required and given", "yes", true, true},
+	}
+
+	log := logger.New(io.Discard, logger.LoggerConfig{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientAuth, err := ParseAuthClients(tt.authClients)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := NewReloader(files, clientAuth, log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			clientFiles := Files{CAFile: files.CAFile}
+			if tt.withCert {
+				clientFiles.CertFile, clientFiles.KeyFile = clientCert, clientKey
+			}
+			client, err := ClientConfig(clientFiles, "localhost", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = handshake(t, r, client)
+			if (err == nil) != tt.ok {
+				t.Errorf("Expected handshake to succeed: %v, got %v", tt.ok, err)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue(t, "server", 2)
+	files := Files{CertFile: certFile, KeyFile: keyFile, CAFile: filepath.Join(ca.dir, "ca.crt")}
+	r, err := NewReloader(files, tls.NoClientCert, logger.New(io.Discard, logger.LoggerConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := ClientConfig(Files{CAFile: files.CAFile}, "localhost", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Renew the certificate in place
+	ca.issue(t, "server", 42)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := handshake(t, r, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.SerialNumber.Int64() != 42 {
+		t.Errorf("Expected 42, got %d", cert.SerialNumber.Int64())
+	}
+
+	// A broken file keeps the previous certificate
+	os.WriteFile(certFile, []byte("garbage"), 0600)
+	if err := r.Reload(); err == nil {
+		t.Errorf("Expected an error")
+	}
+	if cert, err := handshake(t, r, client); err != nil || cert.SerialNumber.Int64() != 42 {
+		t.Errorf("Expected the previous certificate, got %v", err)
+	}
+}
+
+func TestNewReloaderErrors(t *testing.T) {
+	log := logger.New(io.Discard, logger.LoggerConfig{})
+	if _, err := NewReloader(Files{}, tls.NoClientCert, log); err != ErrNoCertificate {
+		t.Errorf("Expected %v, got %v", ErrNoCertificate, err)
+	}
+	if _, err := NewReloader(Files{CertFile: "a", KeyFile: "b"}, tls.RequireAndVerifyClientCert, log); err != ErrNoCA {
+		t.Errorf("Expected %v, got %v", ErrNoCA, err)
+	}
+	if _, err := ParseAuthClients("maybe"); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
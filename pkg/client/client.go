@@ -0,0 +1,157 @@
+// Package client talks to a smolredis server, or any server speaking RESP
+//
+//	c := client.New(client.Options{Addr: "localhost:6380"})
+//	defer c.Close()
+//	if _, err := c.Set(ctx, "greeting", "hello", &client.SetOptions{TTL: time.Minute}); err != nil {
+//		...
+//	}
+//	v, err := c.Get(ctx, "greeting") // client.ErrNil if missing
+//
+// A Client is safe for concurrent use, commands share a bounded pool of connections
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"time"
+)
+
+type Options struct {
+	Addr string // host:port, localhost:6380 by default
+
+	// Open the network connection, a net.Dialer by default
+	// e.g. to go through a proxy or wrap the connection in TLS
+	Dialer func(ctx context.Context, network, addr string) (net.Conn, error)
+	// Speak TLS over the connections made by Dialer when set
+	// ServerName defaults to the host of Addr
+	TLSConfig *tls.Config
+
+	DialTimeout  time.Duration // 5 seconds by default
+	ReadTimeout  time.Duration // Waiting for a reply, 3 seconds by default, -1 to wait forever
+	WriteTimeout time.Duration // Same as ReadTimeout by default
+
+	PoolSize    int           // Maximum number of open connections, 10 by default
+	PoolTimeout time.Duration // Waiting for a connection once they are all in use, ReadTimeout + 1 second by default
+	// An idle connection is closed after this long, 5 minutes by default, -1 to keep it forever
+	IdleTimeout time.Duration
+	// An idle connection is pinged before it is reused after this long, 1 minute by default
+	HealthCheckInterval time.Duration
+}
+
+func (o *Options) setDefaults() {
+	if o.Addr == "" {
+		o.Addr = "localhost:6380"
+	}
+	if o.Dialer == nil {
+		var d net.Dialer
+		o.Dialer = d.DialContext
+	}
+	if o.TLSConfig != nil {
+		o.Dialer = dialTLS(o.Dialer, o.TLSConfig)
+	}
+	if o.DialTimeout == 0 {
+		o.DialTimeout = 5 * time.Second
+	}
+	switch o.ReadTimeout {
+	case -1:
+		o.ReadTimeout = 0
+	case 0:
+		o.ReadTimeout = 3 * time.Second
+	}
+	switch o.WriteTimeout {
+	case -1:
+		o.WriteTimeout = 0
+	case 0:
+		o.WriteTimeout = o.ReadTimeout
+	}
+	if o.PoolSize <= 0 {
+		o.PoolSize = 10
+	}
+	if o.PoolTimeout == 0 {
+		o.PoolTimeout = o.ReadTimeout + time.Second
+	}
+	switch o.IdleTimeout {
+	case -1:
+		o.IdleTimeout = 0
+	case 0:
+		o.IdleTimeout = 5 * time.Minute
+	}
+	if o.HealthCheckInterval == 0 {
+		o.HealthCheckInterval = time.Minute
+	}
+}
+
+// Wrap the connections made by dial in TLS, done with the handshake before they are used
+func dialTLS(dial func(context.Context, string, string) (net.Conn, error), config *tls.Config) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		c := config
+		if c.ServerName == "" {
+			c = config.Clone()
+			c.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tlsConn := tls.Client(conn, c)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
+
+type Client struct {
+	opts Options
+	pool *pool
+}
+
+// Connections are only opened when commands need them
+func New(opts Options) *Client {
+	opts.setDefaults()
+	return &Client{opts: opts, pool: newPool(&opts)}
+}
+
+// Send a command and return its reply decoded as described in readReply
+// An error reply is returned as an *Error
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	replies, err := c.roundTrip(ctx, [][]string{args})
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := replies[0].(*Error); ok {
+		return nil, e
+	}
+	return replies[0], nil
+}
+
+// Run the commands on a single connection, which goes back to the pool unless it broke
+func (c *Client) roundTrip(ctx context.Context, cmds [][]string) ([]any, error) {
+	if len(cmds) == 0 {
+		return nil, nil
+	}
+	for _, args := range cmds {
+		if len(args) == 0 {
+			return nil, errors.New("smolredis: empty command")
+		}
+	}
+	cn, err := c.pool.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	replies, err := cn.roundTrip(ctx, cmds, &c.opts)
+	c.pool.put(cn, err != nil)
+	return replies, err
+}
+
+func (c *Client) PoolStats() PoolStats {
+	return c.pool.Stats()
+}
+
+// Close the pool, commands sent afterwards fail with ErrClosed
+func (c *Client) Close() error {
+	return c.pool.Close()
+}
@@ -0,0 +1,182 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+)
+
+// Minimal server answering each command with whatever reply returns
+func serve(t *testing.T, reply func(args []string) string) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := resp.NewReader(bufio.NewReader(conn))
+				for {
+					cmd, err := readReply(r)
+					if err != nil {
+						return
+					}
+					var args []string
+					for _, arg := range cmd.([]any) {
+						args = append(args, arg.(string))
+					}
+					conn.Write([]byte(reply(args)))
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestCommands(t *testing.T) {
+	var mu sync.Mutex
+	data := map[string]string{}
+	addr := serve(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch strings.ToUpper(args[0]) {
+		case "SET":
+			if len(args) > 3 && args[3] == "NX" {
+				if _, ok := data[args[1]]; ok {
+					return "$-1\r\n"
+				}
+			}
+			data[args[1]] = args[2]
+			return "+OK\r\n"
+		case "GET":
+			v, ok := data[args[1]]
+			if !ok {
+				return "$-1\r\n"
+			}
+			return "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+		case "LPUSH":
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		case "PTTL":
+			return ":-2\r\n"
+		}
+		return "-ERR unknown command\r\n"
+	})
+	c := New(Options{Addr: addr})
+	defer c.Close()
+	ctx := context.Background()
+
+	if _, err := c.Get(ctx, "missing"); !errors.Is(err, ErrNil) {
+		t.Errorf("Expected ErrNil, got %v", err)
+	}
+	if ok, err := c.Set(ctx, "k", "v", nil); !ok || err != nil {
+		t.Errorf("Expected SET to succeed, got %v %v", ok, err)
+	}
+	if ok, err := c.Set(ctx, "k", "w", &SetOptions{NX: true}); ok || err != nil {
+		t.Errorf("Expected SET NX to be refused, got %v %v", ok, err)
+	}
+	if v, err := c.Get(ctx, "k"); v != "v" || err != nil {
+		t.Errorf("Expected v, got %q %v", v, err)
+	}
+	if _, err := c.LPush(ctx, "k", "x"); !IsWrongType(err) {
+		t.Errorf("Expected a WRONGTYPE error, got %v", err)
+	}
+	if ttl, err := c.TTL(ctx, "missing"); ttl != -2 || err != nil {
+		t.Errorf("Expected -2, got %v %v", ttl, err)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	addr := serve(t, func(args []string) string {
+		if args[0] == "FAIL" {
+			return "-ERR failed\r\n"
+		}
+		return "+" + args[0] + "\r\n"
+	})
+	c := New(Options{Addr: addr})
+	defer c.Close()
+
+	p := c.Pipeline()
+	p.Queue("A")
+	p.Queue("FAIL")
+	p.Queue("B")
+	results, err := p.Exec(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].Val != "A" || results[1].Err == nil || results[2].Val != "B" {
+		t.Errorf("Expected A, an error and B, got %+v", results)
+	}
+	if p.Len() != 0 {
+		t.Errorf("Expected an empty pipeline after Exec, got %d commands", p.Len())
+	}
+}
+
+func TestPool(t *testing.T) {
+	release := make(chan struct{})
+	addr := serve(t, func(args []string) string {
+		if args[0] == "BLOCK" {
+			<-release
+		}
+		return "+OK\r\n"
+	})
+	c := New(Options{Addr: addr, PoolSize: 1, PoolTimeout: 50 * time.Millisecond})
+	defer c.Close()
+	ctx := context.Background()
+
+	done := make(chan error)
+	go func() {
+		_, err := c.Do(ctx, "BLOCK")
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	// The only connection is busy
+	if _, err := c.Do(ctx, "PING"); !errors.Is(err, ErrPoolTimeout) {
+		t.Errorf("Expected ErrPoolTimeout, got %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := c.PoolStats()
+	if stats.TotalConns != 1 || stats.Misses != 1 || stats.Hits != 1 || stats.Timeouts != 1 {
+		t.Errorf("Expected a single connection reused once, got %+v", stats)
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	addr := serve(t, func(args []string) string {
+		time.Sleep(time.Second)
+		return "+OK\r\n"
+	})
+	c := New(Options{Addr: addr})
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Do(ctx, "SLOW"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to be exceeded, got %v", err)
+	}
+	// The connection was left with a pending reply, so it must not be reused
+	if stats := c.PoolStats(); stats.TotalConns != 0 {
+		t.Errorf("Expected the connection to be closed, got %+v", stats)
+	}
+}
@@ -0,0 +1,234 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Options of SET, all optional
+// At most one of TTL, ExpireAt and KeepTTL may be set
+type SetOptions struct {
+	NX       bool          // Only set the key if it does not exist
+	XX       bool          // Only set the key if it already exists
+	TTL      time.Duration // Expire the key after this long, with millisecond precision
+	ExpireAt time.Time     // Expire the key at this time
+	KeepTTL  bool          // Keep the expiration the key already had
+}
+
+func (o *SetOptions) args() []string {
+	var args []string
+	if o == nil {
+		return args
+	}
+	if o.NX {
+		args = append(args, "NX")
+	}
+	if o.XX {
+		args = append(args, "XX")
+	}
+	switch {
+	case o.TTL > 0:
+		args = append(args, "PX", strconv.FormatInt(o.TTL.Milliseconds(), 10))
+	case !o.ExpireAt.IsZero():
+		args = append(args, "PXAT", strconv.FormatInt(o.ExpireAt.UnixMilli(), 10))
+	case o.KeepTTL:
+		args = append(args, "KEEPTTL")
+	}
+	return args
+}
+
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+// ErrNil if the key does not exist
+func (c *Client) Get(ctx context.Context, key string) (string, error) {
+	return toString(c.Do(ctx, "GET", key))
+}
+
+// Return false if NX or XX prevented the write
+func (c *Client) Set(ctx context.Context, key, value string, opts *SetOptions) (bool, error) {
+	reply, err := c.Do(ctx, append([]string{"SET", key, value}, opts.args()...)...)
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+// Set the key and return its previous value, ErrNil if it had none
+func (c *Client) SetGet(ctx context.Context, key, value string, opts *SetOptions) (string, error) {
+	return toString(c.Do(ctx, append([]string{"SET", key, value, "GET"}, opts.args()...)...))
+}
+
+// Return the number of keys that existed
+func (c *Client) Del(ctx context.Context, keys ...string) (int64, error) {
+	return toInt(c.Do(ctx, append([]string{"DEL"}, keys...)...))
+}
+
+// Return false if the key does not exist
+func (c *Client) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return toBool(c.Do(ctx, "PEXPIRE", key, strconv.FormatInt(ttl.Milliseconds(), 10)))
+}
+
+func (c *Client) ExpireAt(ctx context.Context, key string, at time.Time) (bool, error) {
+	return toBool(c.Do(ctx, "PEXPIREAT", key, strconv.FormatInt(at.UnixMilli(), 10)))
+}
+
+// Remove the expiration, return false if the key does not exist or had none
+func (c *Client) Persist(ctx context.Context, key string) (bool, error) {
+	return toBool(c.Do(ctx, "PERSIST", key))
+}
+
+// Remaining time to live, with the server's conventions:
+// -1 if the key has no expiration and -2 if it does not exist
+func (c *Client) TTL(ctx context.Context, key string) (time.Duration, error) {
+	n, err := toInt(c.Do(ctx, "PTTL", key))
+	if err != nil || n < 0 {
+		return time.Duration(n), err
+	}
+	return time.Duration(n) * time.Millisecond, nil
+}
+
+// Type of the value stored at key, none if it does not exist
+func (c *Client) Type(ctx context.Context, key string) (string, error) {
+	return toString(c.Do(ctx, "TYPE", key))
+}
+
+// Return the length of the list afterwards
+func (c *Client) LPush(ctx context.Context, key string, values ...string) (int64, error) {
+	return toInt(c.Do(ctx, append([]string{"LPUSH", key}, values...)...))
+}
+
+func (c *Client) RPush(ctx context.Context, key string, values ...string) (int64, error) {
+	return toInt(c.Do(ctx, append([]string{"RPUSH", key}, values...)...))
+}
+
+// ErrNil if the list is empty
+func (c *Client) LPop(ctx context.Context, key string) (string, error) {
+	return toString(c.Do(ctx, "LPOP", key))
+}
+
+func (c *Client) RPop(ctx context.Context, key string) (string, error) {
+	return toString(c.Do(ctx, "RPOP", key))
+}
+
+func (c *Client) LRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	return toStrings(c.Do(ctx, "LRANGE", key, strconv.FormatInt(start, 10), strconv.FormatInt(stop, 10)))
+}
+
+// Return the number of fields added
+func (c *Client) HSet(ctx context.Context, key string, fields map[string]string) (int64, error) {
+	args := []string{"HSET", key}
+	for f, v := range fields {
+		args = append(args, f, v)
+	}
+	return toInt(c.Do(ctx, args...))
+}
+
+// ErrNil if the field does not exist
+func (c *Client) HGet(ctx context.Context, key, field string) (string, error) {
+	return toString(c.Do(ctx, "HGET", key, field))
+}
+
+func (c *Client) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	reply, err := c.Do(ctx, "HGETALL", key)
+	if err != nil {
+		return nil, err
+	}
+	// RESP3 sends a map, RESP2 a flat array of fields and values
+	out := make(map[string]string)
+	switch v := reply.(type) {
+	case map[any]any:
+		for f, val := range v {
+			field, ok1 := f.(string)
+			value, ok2 := val.(string)
+			if !ok1 || !ok2 {
+				return nil, unexpected(reply)
+			}
+			out[field] = value
+		}
+	case []any:
+		pairs, err := toStrings(v, nil)
+		if err != nil || len(pairs)%2 != 0 {
+			return nil, unexpected(reply)
+		}
+		for i := 0; i < len(pairs); i += 2 {
+			out[pairs[i]] = pairs[i+1]
+		}
+	default:
+		return nil, unexpected(reply)
+	}
+	return out, nil
+}
+
+// Return the number of members added
+func (c *Client) SAdd(ctx context.Context, key string, members ...string) (int64, error) {
+	return toInt(c.Do(ctx, append([]string{"SADD", key}, members...)...))
+}
+
+func (c *Client) SMembers(ctx context.Context, key string) ([]string, error) {
+	return toStrings(c.Do(ctx, "SMEMBERS", key))
+}
+
+// Return the number of subscribers that received the message
+func (c *Client) Publish(ctx context.Context, channel, message string) (int64, error) {
+	return toInt(c.Do(ctx, "PUBLISH", channel, message))
+}
+
+func toString(reply any, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	switch v := reply.(type) {
+	case nil:
+		return "", ErrNil
+	case string:
+		return v, nil
+	}
+	return "", unexpected(reply)
+}
+
+func toInt(reply any, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	if n, ok := reply.(int64); ok {
+		return n, nil
+	}
+	return 0, unexpected(reply)
+}
+
+// Integer replies of 0 or 1 standing for false or true
+func toBool(reply any, err error) (bool, error) {
+	n, err := toInt(reply, err)
+	return n == 1, err
+}
+
+func toStrings(reply any, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]any)
+	if !ok {
+		if reply == nil {
+			return nil, nil
+		}
+		return nil, unexpected(reply)
+	}
+	out := make([]string, len(items))
+	for i, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, unexpected(reply)
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+func unexpected(reply any) error {
+	return fmt.Errorf("%w: unexpected reply %T", ErrProtocol, reply)
+}
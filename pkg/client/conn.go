@@ -0,0 +1,76 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"time"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+)
+
+// A single connection to the server, used by one caller at a time
+type conn struct {
+	netConn  net.Conn
+	r        *resp.Reader
+	buf      []byte // Commands waiting to be written, reused between calls
+	lastUsed time.Time
+}
+
+func newConn(netConn net.Conn) *conn {
+	return &conn{netConn: netConn, r: resp.NewReader(bufio.NewReader(netConn)), lastUsed: time.Now()}
+}
+
+// Send every command in one write, then read as many replies
+// Error replies are returned as *Error values, the error is only set
+// when the connection can't be trusted anymore and has to be closed
+func (cn *conn) roundTrip(ctx context.Context, cmds [][]string, timeouts *Options) ([]any, error) {
+	// Give up on the connection as soon as ctx is done, even in the middle of a read
+	stop := context.AfterFunc(ctx, func() { cn.netConn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	cn.buf = cn.buf[:0]
+	for _, args := range cmds {
+		cn.buf = resp.AppendArray(cn.buf, args)
+	}
+	cn.netConn.SetWriteDeadline(deadline(ctx, timeouts.WriteTimeout))
+	if _, err := cn.netConn.Write(cn.buf); err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	cn.netConn.SetReadDeadline(deadline(ctx, timeouts.ReadTimeout))
+	replies := make([]any, 0, len(cmds))
+	for range cmds {
+		reply, err := readReply(cn.r)
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+		replies = append(replies, reply)
+	}
+	cn.lastUsed = time.Now()
+	return replies, nil
+}
+
+func (cn *conn) Close() error {
+	return cn.netConn.Close()
+}
+
+// The earliest of now + timeout and the deadline of ctx, zero if neither applies
+func deadline(ctx context.Context, timeout time.Duration) time.Time {
+	var t time.Time
+	if timeout > 0 {
+		t = time.Now().Add(timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (t.IsZero() || d.Before(t)) {
+		t = d
+	}
+	return t
+}
+
+// Report the cancellation rather than the I/O error it caused
+func contextError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+package client
+
+import "errors"
+
+var (
+	// Returned by the typed helpers when the key or value does not exist
+	ErrNil = errors.New("smolredis: nil")
+	// The pool had no connection to spare within Options.PoolTimeout
+	ErrPoolTimeout = errors.New("smolredis: connection pool timeout")
+	ErrClosed      = errors.New("smolredis: client is closed")
+)
+
+// Error reply sent by the server
+type Error struct {
+	Code    string // First word of the reply, e.g. ERR, WRONGTYPE or OOM
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Code
+	}
+	return e.Code + " " + e.Message
+}
+
+// Whether the server refused the command because the key holds another type
+func IsWrongType(err error) bool {
+	return hasCode(err, "WRONGTYPE")
+}
+
+// Whether the server is out of memory under the noeviction policy
+func IsOOM(err error) bool {
+	return hasCode(err, "OOM")
+}
+
+// Whether the server is a replica refusing writes
+func IsReadOnly(err error) bool {
+	return hasCode(err, "READONLY")
+}
+
+func hasCode(err error, code string) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
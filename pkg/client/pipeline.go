@@ -0,0 +1,55 @@
+package client
+
+import "context"
+
+// Commands queued to be sent in a single write, saving a round trip per command
+// Unlike MULTI/EXEC, other clients' commands may run in between
+//
+//	p := c.Pipeline()
+//	p.Queue("INCR", "hits")
+//	p.Queue("EXPIRE", "hits", "60")
+//	results, err := p.Exec(ctx)
+type Pipeline struct {
+	client *Client
+	cmds   [][]string
+}
+
+// Outcome of one command of a pipeline, Err is set for an error reply
+type Result struct {
+	Val any
+	Err error
+}
+
+func (c *Client) Pipeline() *Pipeline {
+	return &Pipeline{client: c}
+}
+
+func (p *Pipeline) Queue(args ...string) {
+	p.cmds = append(p.cmds, args)
+}
+
+// Number of commands queued
+func (p *Pipeline) Len() int {
+	return len(p.cmds)
+}
+
+// Send the queued commands and return their results in the same order
+// The error is only set when the replies could not be read at all
+// The pipeline is empty afterwards and can be reused
+func (p *Pipeline) Exec(ctx context.Context) ([]Result, error) {
+	cmds := p.cmds
+	p.cmds = nil
+	replies, err := p.client.roundTrip(ctx, cmds)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(replies))
+	for i, reply := range replies {
+		if e, ok := reply.(*Error); ok {
+			results[i].Err = e
+		} else {
+			results[i].Val = reply
+		}
+	}
+	return results, nil
+}
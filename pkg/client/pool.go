@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Bounded set of connections shared by the callers of a Client
+// At most Options.PoolSize connections are open, idle ones are reused most recent first
+type pool struct {
+	opts  *Options
+	slots chan struct{} // One token per connection handed out
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+	stats  PoolStats
+}
+
+// Counters describing how the pool is doing
+type PoolStats struct {
+	Hits     uint64 // Idle connection reused
+	Misses   uint64 // New connection dialed
+	Timeouts uint64 // Caller gave up waiting for a connection
+	Stale    uint64 // Idle connection closed after failing its health check or sitting idle too long
+
+	TotalConns int // Connections open, idle or in use
+	IdleConns  int
+}
+
+func newPool(opts *Options) *pool {
+	return &pool{opts: opts, slots: make(chan struct{}, opts.PoolSize)}
+}
+
+// Take a connection, waiting for one to be released if the pool is full
+func (p *pool) get(ctx context.Context) (*conn, error) {
+	timer := time.NewTimer(p.opts.PoolTimeout)
+	defer timer.Stop()
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		p.mu.Lock()
+		p.stats.Timeouts++
+		p.mu.Unlock()
+		return nil, ErrPoolTimeout
+	}
+
+	for {
+		cn, err := p.popIdle()
+		if err != nil {
+			<-p.slots
+			return nil, err
+		}
+		if cn == nil {
+			break
+		}
+		if p.healthy(ctx, cn) {
+			p.mu.Lock()
+			p.stats.Hits++
+			p.mu.Unlock()
+			return cn, nil
+		}
+		cn.Close()
+		p.mu.Lock()
+		p.stats.Stale++
+		p.stats.TotalConns--
+		p.mu.Unlock()
+	}
+
+	cn, err := p.dial(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return cn, nil
+}
+
+// Give a connection back, closing it if it broke while in use
+func (p *pool) put(cn *conn, broken bool) {
+	defer func() { <-p.slots }()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if broken || p.closed {
+		cn.Close()
+		p.stats.TotalConns--
+		return
+	}
+	p.idle = append(p.idle, cn)
+}
+
+func (p *pool) popIdle() (*conn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrClosed
+	}
+	if len(p.idle) == 0 {
+		return nil, nil
+	}
+	cn := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+	return cn, nil
+}
+
+// A connection left idle for a while may have been closed by the server or a proxy
+// so it is pinged before being handed out again
+func (p *pool) healthy(ctx context.Context, cn *conn) bool {
+	idle := time.Since(cn.lastUsed)
+	if p.opts.IdleTimeout > 0 && idle > p.opts.IdleTimeout {
+		return false
+	}
+	if idle < p.opts.HealthCheckInterval {
+		return true
+	}
+	replies, err := cn.roundTrip(ctx, [][]string{{"PING"}}, p.opts)
+	if err != nil {
+		return false
+	}
+	_, isErr := replies[0].(*Error)
+	return !isErr
+}
+
+func (p *pool) dial(ctx context.Context) (*conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.opts.DialTimeout)
+	defer cancel()
+	netConn, err := p.opts.Dialer(ctx, "tcp", p.opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.stats.Misses++
+	p.stats.TotalConns++
+	p.mu.Unlock()
+	return newConn(netConn), nil
+}
+
+func (p *pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.stats
+	stats.IdleConns = len(p.idle)
+	return stats
+}
+
+// Close the idle connections, the ones in use are closed when given back
+func (p *pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	p.closed = true
+	for _, cn := range p.idle {
+		cn.Close()
+		p.stats.TotalConns--
+	}
+	p.idle = nil
+	return nil
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+)
+
+// Longest bulk string we accept, same as proto-max-bulk-len in Redis
+const MAX_BULK_LEN = resp.MAX_BULK_LEN
+
+var ErrProtocol = errors.New("smolredis: protocol error")
+
+// Out-of-band data pushed by the server in RESP3, e.g. a pub/sub message
+type Push []any
+
+// Read one reply and turn it into a Go value:
+// simple and bulk strings become string, integers int64, arrays []any,
+// null replies nil and error replies *Error
+// RESP3 doubles become float64, booleans bool, big numbers *big.Int,
+// maps map[any]any, sets []any and pushes Push
+// Attributes only describe the reply that follows them so they are dropped
+func readReply(r *resp.Reader) (any, error) {
+	v, err := r.ReadValue()
+	if err != nil {
+		var protoErr *resp.ProtocolError
+		if errors.As(err, &protoErr) {
+			return nil, fmt.Errorf("%w: %s", ErrProtocol, protoErr.Msg)
+		}
+		return nil, err
+	}
+	return toGo(v)
+}
+
+func toGo(v resp.Value) (any, error) {
+	if v.Null {
+		return nil, nil
+	}
+	switch v.Type {
+	case resp.ERROR, resp.BLOB_ERROR:
+		return parseError(v.Str), nil
+	case resp.INTEGER:
+		return v.Int, nil
+	case resp.DOUBLE:
+		return v.Float, nil
+	case resp.BOOLEAN:
+		return v.Bool, nil
+	case resp.BIG_NUMBER:
+		n, ok := new(big.Int).SetString(v.Str, 10)
+		if !ok {
+			return nil, fmt.Errorf("%w: bad big number %q", ErrProtocol, v.Str)
+		}
+		return n, nil
+	case resp.ARRAY, resp.SET, resp.PUSH:
+		items := make([]any, len(v.Array))
+		for i, item := range v.Array {
+			var err error
+			if items[i], err = toGo(item); err != nil {
+				return nil, err
+			}
+		}
+		if v.Type == resp.PUSH {
+			return Push(items), nil
+		}
+		return items, nil
+	case resp.MAP:
+		m := make(map[any]any, len(v.Array)/2)
+		for i := 0; i < len(v.Array); i += 2 {
+			key, err := toGo(v.Array[i])
+			if err != nil {
+				return nil, err
+			}
+			switch key.(type) {
+			case []any, Push, map[any]any:
+				return nil, fmt.Errorf("%w: map key is not a scalar", ErrProtocol)
+			}
+			if m[key], err = toGo(v.Array[i+1]); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	// Simple, bulk and verbatim strings
+	return v.Str, nil
+}
+
+// Split an error reply into its code and message, e.g. WRONGTYPE and the rest
+func parseError(s string) *Error {
+	code, msg, _ := strings.Cut(s, " ")
+	return &Error{Code: code, Message: msg}
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/phamhonganh12062000/smolredis/internal/resp"
+)
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected any
+	}{
+		{"simple string", "+OK\r\n", "OK"},
+		{"error", "-WRONGTYPE Operation against a key\r\n", &Error{Code: "WRONGTYPE", Message: "Operation against a key"}},
+		{"integer", ":-42\r\n", int64(-42)},
+		{"bulk string", "$5\r\nhe\r\no\r\n", "he\r\no"},
+		{"empty bulk string", "$0\r\n\r\n", ""},
+		{"null bulk string", "$-1\r\n", nil},
+		{"array", "*3\r\n:1\r\n$1\r\na\r\n*-1\r\n", []any{int64(1), "a", nil}},
+		{"null array", "*-1\r\n", nil},
+		{"null", "_\r\n", nil},
+		{"boolean", "#t\r\n", true},
+		{"double", ",1.5\r\n", 1.5},
+		{"infinite double", ",-inf\r\n", math.Inf(-1)},
+		{"big number", "(12345678901234567890\r\n", func() any { n, _ := new(big.Int).SetString("12345678901234567890", 10); return n }()},
+		{"bulk error", "!9\r\nERR oops!\r\n", &Error{Code: "ERR", Message: "oops!"}},
+		{"verbatim string", "=7\r\ntxt:abc\r\n", "abc"},
+		{"map", "%1\r\n+key\r\n:1\r\n", map[any]any{"key": int64(1)}},
+		{"set", "~2\r\n+a\r\n+b\r\n", []any{"a", "b"}},
+		{"push", ">2\r\n+message\r\n+hi\r\n", Push{"message", "hi"}},
+		{"attribute then reply", "|1\r\n+ttl\r\n:3\r\n+OK\r\n", "OK"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readReply(resp.NewReader(bufio.NewReader(strings.NewReader(tt.input))))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %#v, got %#v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestReadReplyProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"unknown type", "?\r\n"},
+		{"missing CR", "+OK\n"},
+		{"bad integer", ":abc\r\n"},
+		{"bulk too long", "$999999999999\r\n"},
+		{"bulk without CRLF", "$2\r\nabcd"},
+		{"array key in map", "%1\r\n*0\r\n:1\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readReply(resp.NewReader(bufio.NewReader(strings.NewReader(tt.input))))
+			if !errors.Is(err, ErrProtocol) {
+				t.Errorf("Expected a protocol error, got %v", err)
+			}
+		})
+	}
+}